@@ -0,0 +1,145 @@
+package gazelle
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// processorService is the service the compiler discovers annotation processors with.
+const processorService = "javax.annotation.processing.Processor"
+
+// annotationProcessorPlugins records the java_plugin targets generated for the annotation
+// processors of the repository, by the annotation types they support, so that the libraries
+// using those annotations run them.
+type annotationProcessorPlugins struct {
+	// byAnnotationType maps an annotation type, or a package followed by ".*", to the plugins
+	// supporting it.
+	byAnnotationType map[string][]annotationProcessorPlugin
+}
+
+type annotationProcessorPlugin struct {
+	plugin label.Label
+	// library is the library the processor is compiled in, which can't run it itself.
+	library  label.Label
+	testonly bool
+}
+
+func newAnnotationProcessorPlugins() *annotationProcessorPlugins {
+	return &annotationProcessorPlugins{byAnnotationType: make(map[string][]annotationProcessorPlugin)}
+}
+
+// add records plugin as supporting the annotation types supportedTypes. The "*" type, which
+// processors claiming every round use, is ignored: it would run the plugin for every library.
+func (p *annotationProcessorPlugins) add(plugin annotationProcessorPlugin, supportedTypes *sorted_set.SortedSet[string]) {
+	for _, supportedType := range supportedTypes.SortedSlice() {
+		if supportedType == "*" {
+			continue
+		}
+		p.byAnnotationType[supportedType] = append(p.byAnnotationType[supportedType], plugin)
+	}
+}
+
+// pluginsFor returns the plugins supporting annotation, either by name or by one of its
+// enclosing packages.
+func (p *annotationProcessorPlugins) pluginsFor(annotation types.ClassName) []annotationProcessorPlugin {
+	name := annotation.FullyQualifiedClassName()
+	plugins := append([]annotationProcessorPlugin{}, p.byAnnotationType[name]...)
+	for i := strings.LastIndex(name, "."); i > 0; i = strings.LastIndex(name[:i], ".") {
+		plugins = append(plugins, p.byAnnotationType[name[:i]+".*"]...)
+	}
+	return plugins
+}
+
+// javaPluginName returns the name of the java_plugin running processor from library, following
+// the naming scheme of rules_jvm_external: https://github.com/bazelbuild/rules_jvm_external/pull/1102
+func javaPluginName(library string, processor types.ClassName) string {
+	return library + "__java_plugin__" + strings.NewReplacer(".", "_", "$", "_").Replace(processor.FullyQualifiedClassName())
+}
+
+// annotationProcessors returns the annotation processors declared by pkgs, mapped to the
+// annotation types they support: the classes the parser found implementing Processor, and the
+// classes of pkgs among the processors registered by the service file of the resources of the
+// sourceset, or by a module-info.java.
+func annotationProcessors(registered []string, pkgs []*java.Package) map[string]*sorted_set.SortedSet[string] {
+	processors := make(map[string]*sorted_set.SortedSet[string])
+	registeredProcessors := sorted_set.NewSortedSet(registered)
+	for _, pkg := range pkgs {
+		if pkg.Module != nil {
+			for _, processor := range pkg.Module.Provides.SortedValues(processorService) {
+				registeredProcessors.Add(processor.FullyQualifiedClassName())
+			}
+		}
+	}
+
+	for _, pkg := range pkgs {
+		for processor, supportedTypes := range pkg.AnnotationProcessors {
+			processors[processor] = supportedTypes
+		}
+		for _, processor := range registeredProcessors.SortedSlice() {
+			className, err := types.ParseClassName(processor)
+			if err != nil || className.PackageName() != pkg.Name || !declaresClass(pkg, *className) {
+				continue
+			}
+			if _, ok := processors[processor]; !ok {
+				processors[processor] = sorted_set.NewSortedSet([]string{})
+			}
+		}
+	}
+	return processors
+}
+
+// declaresClass returns whether className is declared by the sources of pkg.
+func declaresClass(pkg *java.Package, className types.ClassName) bool {
+	outer := types.NewClassName(className.PackageName(), className.BareOuterClassName())
+	if pkg.DeclaredClasses.Contains(className) || pkg.DeclaredClasses.Contains(outer) {
+		return true
+	}
+	return pkg.Files.Contains(outer.BareOuterClassName()+".java") || pkg.Files.Contains(outer.BareOuterClassName()+".kt")
+}
+
+// generateAnnotationProcessorPlugins generates a java_plugin for each of processors, depending on
+// the library libraryOf returns for it, and registers the annotation types it supports.
+func (l javaLang) generateAnnotationProcessorPlugins(rel string, cfg *javaconfig.Config, processors map[string]*sorted_set.SortedSet[string], libraryOf func(types.ClassName) string, res *language.GenerateResult) {
+	names := make([]string, 0, len(processors))
+	for processor := range processors {
+		names = append(names, processor)
+	}
+	sort.Strings(names)
+
+	for _, processor := range names {
+		className, err := types.ParseClassName(processor)
+		if err != nil {
+			l.logger.Warn().Err(err).Str("processor", processor).Msg("Not generating a java_plugin for annotation processor")
+			continue
+		}
+		library := libraryOf(*className)
+		if library == "" {
+			continue
+		}
+		name := javaPluginName(library, *className)
+
+		r := rule.NewRule("java_plugin", name)
+		r.SetAttr("processor_class", processor)
+		r.SetAttr("deps", []string{":" + library})
+		if cfg.TestOnly() {
+			r.SetAttr("testonly", true)
+		}
+		r.SetAttr("visibility", libraryVisibility(cfg))
+		res.Gen = append(res.Gen, r)
+		res.Imports = append(res.Imports, types.ResolveInput{})
+
+		l.annotationProcessorPlugins.add(annotationProcessorPlugin{
+			plugin:   label.New("", rel, name),
+			library:  label.New("", rel, library),
+			testonly: cfg.TestOnly(),
+		}, processors[processor])
+	}
+}
@@ -0,0 +1,122 @@
+package gazelle
+
+import (
+	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_multiset"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotationProcessorPluginsFor(t *testing.T) {
+	builder := annotationProcessorPlugin{plugin: label.New("", "processors", "builder")}
+	everything := annotationProcessorPlugin{plugin: label.New("", "processors", "everything")}
+	plugins := newAnnotationProcessorPlugins()
+	plugins.add(builder, sorted_set.NewSortedSet([]string{"com.example.Builder", "com.example.annotations.*"}))
+	plugins.add(everything, sorted_set.NewSortedSet([]string{"*"}))
+
+	for annotation, want := range map[string][]annotationProcessorPlugin{
+		"com.example.Builder":                    {builder},
+		"com.example.annotations.Immutable":      {builder},
+		"com.example.annotations.nested.Default": {builder},
+		"com.example.Other":                      {},
+		"com.example.annotationsExtra.Immutable": {},
+	} {
+		className, err := types.ParseClassName(annotation)
+		require.NoError(t, err)
+		require.ElementsMatch(t, want, plugins.pluginsFor(*className), annotation)
+	}
+}
+
+func TestAnnotationProcessors(t *testing.T) {
+	registered := []string{
+		"com.example.processor.Registered",
+		"com.example.processor.Outer.Nested",
+		"com.example.other.Elsewhere",
+	}
+
+	pkg := types.NewPackageName("com.example.processor")
+	provides := sorted_multiset.NewSortedMultiSetFn[string, types.ClassName](types.ClassNameLess)
+	provides.Add(processorService, types.NewClassName(pkg, "Provided"))
+	javaPkg := &java.Package{
+		Name:            pkg,
+		DeclaredClasses: sorted_set.NewSortedSetFn([]types.ClassName{types.NewClassName(pkg, "Outer")}, types.ClassNameLess),
+		Files:           sorted_set.NewSortedSet([]string{"BuilderProcessor.java", "Outer.java", "Provided.java", "Registered.java", "module-info.java"}),
+		Module:          &java.Module{Name: "com.example.processor", Provides: provides},
+		AnnotationProcessors: map[string]*sorted_set.SortedSet[string]{
+			"com.example.processor.BuilderProcessor": sorted_set.NewSortedSet([]string{"com.example.Builder"}),
+		},
+	}
+
+	processors := annotationProcessors(registered, []*java.Package{javaPkg})
+	supportedTypes := make(map[string][]string)
+	for processor, supported := range processors {
+		supportedTypes[processor] = supported.SortedSlice()
+	}
+	require.Equal(t, map[string][]string{
+		"com.example.processor.BuilderProcessor": {"com.example.Builder"},
+		"com.example.processor.Outer.Nested":     {},
+		"com.example.processor.Provided":         {},
+		"com.example.processor.Registered":       {},
+	}, supportedTypes, "the processors of other packages aren't generated with this one")
+}
+
+func TestInRepoAnnotationProcessorPlugin(t *testing.T) {
+	c, langs, jLang := testJavaLang(t)
+	cfg := c.Exts[languageName].(javaconfig.Configs)[""]
+
+	builder := types.NewClassName(types.NewPackageName("com.example.annotations"), "Builder")
+	f := rule.EmptyFile("BUILD.bazel", "")
+	var res language.GenerateResult
+	for _, lib := range []struct {
+		name        string
+		packages    []string
+		annotations []types.ClassName
+	}{
+		{name: "processor", packages: []string{"com.example.processor"}, annotations: []types.ClassName{builder}},
+		{name: "app", packages: []string{"com.example.app"}, annotations: []types.ClassName{builder}},
+	} {
+		jLang.generateJavaLibrary(generateJavaLibraryArgs{
+			File:        f,
+			LibraryKind: "java_library",
+			Result:      &res,
+			Config:      cfg,
+			Name:        lib.name,
+			Srcs:        []string{"Foo.java"},
+			Packages:    stringsToPackageNames(lib.packages),
+			Imports:     stringsToPackageNames(nil),
+			Exports:     stringsToPackageNames(nil),
+			Annotations: sorted_set.NewSortedSetFn(lib.annotations, types.ClassNameLess),
+		})
+	}
+	jLang.generateAnnotationProcessorPlugins("", cfg, map[string]*sorted_set.SortedSet[string]{
+		"com.example.processor.BuilderProcessor": sorted_set.NewSortedSet([]string{"com.example.annotations.*"}),
+	}, func(types.ClassName) string { return "processor" }, &res)
+
+	plugin := res.Gen[2]
+	require.Equal(t, "java_plugin", plugin.Kind())
+	require.Equal(t, "processor__java_plugin__com_example_processor_BuilderProcessor", plugin.Name())
+	require.Equal(t, "com.example.processor.BuilderProcessor", plugin.AttrString("processor_class"))
+	require.Equal(t, []string{":processor"}, plugin.AttrStrings("deps"))
+
+	mrslv, exts := InitTestResolversAndExtensions(langs)
+	ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+	for _, r := range res.Gen {
+		ix.AddRule(c, r, f)
+	}
+	ix.Finish()
+
+	for i, name := range []string{"processor", "app"} {
+		r := res.Gen[i]
+		mrslv.Resolver(r, "").Resolve(c, ix, testRemoteCache(nil), r, res.Imports[i], label.New("", "", name))
+	}
+	require.Empty(t, res.Gen[0].AttrStrings("plugins"), "a processor can't run in its own library")
+	require.Equal(t, []string{":processor__java_plugin__com_example_processor_BuilderProcessor"}, res.Gen[1].AttrStrings("plugins"))
+}
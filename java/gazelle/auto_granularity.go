@@ -0,0 +1,29 @@
+package gazelle
+
+import (
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/scc"
+	"github.com/rs/zerolog"
+)
+
+// logAutoGranularity logs the grouping chosen for the production packages of a directory in
+// "auto" granularity, once Gazelle has walked them all: the directories built together for
+// each import cycle, and the packages keeping a library of their own.
+func logAutoGranularity(graph *scc.Graph, log zerolog.Logger) {
+	var packages []string
+	cycles := 0
+	for _, group := range graph.Groups() {
+		if len(group.Dirs) == 1 {
+			packages = append(packages, group.Dirs[0])
+			continue
+		}
+		cycles++
+		log.Info().
+			Str("library", group.Name).
+			Strs("cycle", group.Dirs).
+			Msg("java_module_granularity auto: building the directories of an import cycle together")
+	}
+	log.Info().
+		Int("cycles", cycles).
+		Strs("packages", packages).
+		Msg("java_module_granularity auto: using package granularity outside of the import cycles")
+}
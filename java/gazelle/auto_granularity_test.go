@@ -0,0 +1,41 @@
+package gazelle
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/scc"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogAutoGranularity(t *testing.T) {
+	pkg := func(name string, imports ...string) *java.Package {
+		importedPackages := sorted_set.NewSortedSetFn([]types.PackageName{}, types.PackageNameLess)
+		for _, imp := range imports {
+			importedPackages.Add(types.NewPackageName(imp))
+		}
+		return &java.Package{
+			Name:                                   types.NewPackageName(name),
+			ImportedPackagesWithoutSpecificClasses: importedPackages,
+		}
+	}
+
+	graph, err := scc.New(map[string]*java.Package{
+		"src/a":    pkg("com.a", "com.b"),
+		"src/b":    pkg("com.b", "com.a"),
+		"src/solo": pkg("com.solo", "com.a"),
+	})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	logAutoGranularity(graph, zerolog.New(&out))
+	require.Equal(t, []string{
+		`{"level":"info","library":"a","cycle":["src/a","src/b"],"message":"java_module_granularity auto: building the directories of an import cycle together"}`,
+		`{"level":"info","cycles":1,"packages":["src/solo"],"message":"java_module_granularity auto: using package granularity outside of the import cycles"}`,
+	}, strings.Split(strings.TrimSpace(out.String()), "\n"))
+}
@@ -5,13 +5,16 @@ import (
 	"fmt"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/javaparser"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/maven"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
 	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
 	"github.com/bazelbuild/bazel-gazelle/rule"
 	bzl "github.com/bazelbuild/buildtools/build"
 )
@@ -26,6 +29,15 @@ type Configurer struct {
 	annotationToWrapper   annotationToWrapper
 	mavenInstallFile      string
 	mavenIndexFile        string
+	mavenSnapshotFile     string
+	mavenUsageReport      string
+	resolutionTrace       string
+	resolutionTraceTarget string
+	dependencyGraph       string
+	dependencyGraphDot    string
+	repositoryIndex       string
+	// mavenInstalls are the maven.install tags declared in MODULE.bazel.
+	mavenInstalls []maven.Install
 }
 
 func NewConfigurer(lang *javaLang) *Configurer {
@@ -41,10 +53,18 @@ func (jc *Configurer) RegisterFlags(fs *flag.FlagSet, cmd string, c *config.Conf
 	fs.Var(&jc.annotationToWrapper, "java-annotation-to-wrapper", "Mapping of annotations (on test classes) to wrapper rules which should be used around the test rule. Example: com.example.annotations.RequiresNetwork=@some//wrapper:file.bzl=requires_network")
 	fs.StringVar(&jc.mavenInstallFile, "java-maven-install-file", "", "Path of the maven_install.json file. Defaults to \"maven_install.json\".")
 	fs.StringVar(&jc.mavenIndexFile, "maven-index-file", "", "Path of the maven_index.json file. Defaults to \"maven_index.json\".")
+	fs.StringVar(&jc.mavenSnapshotFile, "java-maven-snapshot-file", "", "Path of a binary snapshot of the parsed maven_install.json and maven_index.json files, rebuilt whenever they change. Relative paths are relative to the repository root. Disabled by default.")
+	fs.StringVar(&jc.mavenUsageReport, "java-maven-usage-report", "", "Path of a JSON report, written after resolving, of the pinned Maven artifacts which are used directly, only transitively, or not at all, and of the imported packages with no provider. Relative paths are relative to the repository root. Disabled by default.")
+	fs.StringVar(&jc.resolutionTrace, "java-resolution-trace", "", "Path of a JSON trace, written after resolving, of how each import of each rule was resolved to an entry of its deps or exports, or why it was dropped. Relative paths are relative to the repository root. Disabled by default.")
+	fs.StringVar(&jc.resolutionTraceTarget, "java-resolution-trace-target", "", "Comma-separated labels, or packages ending in \"/...\", of the rules to include in the resolution trace. Defaults to every rule.")
+	fs.StringVar(&jc.dependencyGraph, "java-dependency-graph", "", "Path of a JSON file, written after resolving, of the graph of the resolved rules, with their kind, srcs, packages and classes, and of the labels they depend on, with the reason of each edge. Relative paths are relative to the repository root. Disabled by default.")
+	fs.StringVar(&jc.dependencyGraphDot, "java-dependency-graph-dot", "", "Path of a Graphviz DOT file, written after resolving, of the graph of the resolved rules and the labels they depend on. Relative paths are relative to the repository root. Disabled by default.")
+	fs.StringVar(&jc.repositoryIndex, "java-repository-index", "", "Path of a JSON index, written after resolving, of the libraries of this repository, with the packages and classes they provide, whether they are testonly, and the java_export publishing them, for other repositories to load with the java_repository_index directive. Relative paths are relative to the repository root. Disabled by default.")
 }
 
 func (jc *Configurer) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
 	cfgs := jc.initRootConfig(c)
+	jc.discoverMavenInstalls(c.RepoRoot, cfgs[""])
 	for annotation, kv := range jc.annotationToAttribute {
 		for k, v := range kv {
 			cfgs[""].MapAnnotationToAttribute(annotation, k, v)
@@ -59,24 +79,139 @@ func (jc *Configurer) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
 	if jc.mavenIndexFile != "" {
 		cfgs[""].SetMavenIndexFile(jc.mavenIndexFile)
 	}
+	if jc.mavenInstallFile != "" {
+		jc.checkMavenInstall(c.RepoRoot, cfgs[""], "-java-maven-install-file")
+	}
+	if jc.mavenUsageReport != "" {
+		reportFile := jc.mavenUsageReport
+		if !filepath.IsAbs(reportFile) {
+			reportFile = filepath.Join(c.RepoRoot, reportFile)
+		}
+		jc.lang.mavenReport = newMavenReport(reportFile)
+	}
+	if jc.resolutionTraceTarget != "" && jc.resolutionTrace == "" {
+		return fmt.Errorf("-java-resolution-trace-target requires -java-resolution-trace")
+	}
+	if jc.resolutionTrace != "" {
+		traceFile := jc.resolutionTrace
+		if !filepath.IsAbs(traceFile) {
+			traceFile = filepath.Join(c.RepoRoot, traceFile)
+		}
+		var targets []string
+		for _, target := range strings.Split(jc.resolutionTraceTarget, ",") {
+			if target = strings.TrimSpace(target); target != "" {
+				targets = append(targets, target)
+			}
+		}
+		jc.lang.resolutionTrace = newResolutionTrace(traceFile, targets)
+	}
+	if jc.dependencyGraph != "" || jc.dependencyGraphDot != "" {
+		graphFile, dotFile := jc.dependencyGraph, jc.dependencyGraphDot
+		if graphFile != "" && !filepath.IsAbs(graphFile) {
+			graphFile = filepath.Join(c.RepoRoot, graphFile)
+		}
+		if dotFile != "" && !filepath.IsAbs(dotFile) {
+			dotFile = filepath.Join(c.RepoRoot, dotFile)
+		}
+		jc.lang.dependencyGraph = newDependencyGraph(graphFile, dotFile)
+	}
+	if jc.repositoryIndex != "" {
+		indexFile := jc.repositoryIndex
+		if !filepath.IsAbs(indexFile) {
+			indexFile = filepath.Join(c.RepoRoot, indexFile)
+		}
+		jc.lang.repositoryIndexWriter = newRepositoryIndexWriter(indexFile)
+	}
 	return nil
 }
 
+// discoverMavenInstalls reads the maven.install tags from MODULE.bazel, and defaults the
+// root config to the install named "maven", or to the only install if there is one.
+func (jc *Configurer) discoverMavenInstalls(repoRoot string, cfg *javaconfig.Config) {
+	installs, err := maven.ParseModuleInstalls(repoRoot)
+	if err != nil {
+		jc.lang.logger.Warn().Err(err).Msg("not discovering maven installs from MODULE.bazel")
+		return
+	}
+	jc.mavenInstalls = installs
+
+	install, found := maven.FindInstall(installs, maven.DefaultRepositoryName)
+	if !found {
+		if len(installs) != 1 {
+			return
+		}
+		install = installs[0]
+	}
+	jc.useMavenInstall(cfg, install)
+}
+
+// useMavenInstall points cfg at the repository and lock file declared by install.
+func (jc *Configurer) useMavenInstall(cfg *javaconfig.Config, install maven.Install) {
+	cfg.SetMavenRepositoryName(install.Name)
+	if install.LockFile != "" {
+		cfg.SetMavenInstallFile(install.LockFile)
+	}
+}
+
+// checkMavenInstall warns if cfg's Maven repository or lock file disagrees with the
+// maven.install tags in MODULE.bazel. source names the directive or flag which set them.
+func (jc *Configurer) checkMavenInstall(repoRoot string, cfg *javaconfig.Config, source string) {
+	if len(jc.mavenInstalls) == 0 {
+		return
+	}
+	install, found := maven.FindInstall(jc.mavenInstalls, cfg.MavenRepositoryName())
+	if !found {
+		var names []string
+		for _, install := range jc.mavenInstalls {
+			names = append(names, install.Name)
+		}
+		jc.lang.logger.Warn().
+			Str("source", source).
+			Str("repository", cfg.MavenRepositoryName()).
+			Strs("installs", names).
+			Msg("maven repository name does not match any maven.install in MODULE.bazel")
+		return
+	}
+	if install.LockFile == "" {
+		return
+	}
+	if filepath.Clean(cfg.MavenInstallFile()) != filepath.Join(repoRoot, install.LockFile) {
+		jc.lang.logger.Warn().
+			Str("source", source).
+			Str("repository", install.Name).
+			Str("install_file", cfg.MavenInstallFile()).
+			Str("lock_file", install.LockFile).
+			Msg("maven install file does not match the lock_file of the maven.install in MODULE.bazel")
+	}
+}
+
 func (jc *Configurer) KnownDirectives() []string {
 	return []string{
 		javaconfig.JavaAnnotationProcessorPlugin,
 		javaconfig.JavaAnnotationProcessorExtraImports,
+		javaconfig.JavaAnnotationProcessorGeneratedClass,
+		javaconfig.JavaAnnotationProcessorDiscovery,
+		javaconfig.JavaDefaultVisibility,
+		javaconfig.JavaDependencyRule,
 		javaconfig.JavaExcludeArtifact,
 		javaconfig.JavaExtensionDirective,
+		javaconfig.JavaExtraVisibility,
 		javaconfig.JavaGenerateBinary,
 		javaconfig.JavaGenerateProto,
 		javaconfig.JavaGenerateProtoServices,
 		javaconfig.JavaGenerateResources,
+		javaconfig.JavaInferVisibility,
+		javaconfig.JavaKotlinCompilerPlugin,
 		javaconfig.JavaLibraryNamingConvention,
 		javaconfig.JavaMavenInstallFile,
 		javaconfig.JavaMavenRepositoryName,
 		javaconfig.JavaModuleGranularityDirective,
+		javaconfig.JavaPlatformPackage,
+		javaconfig.JavaRelease,
+		javaconfig.JavaRepositoryIndex,
 		javaconfig.JavaResolveToJavaExports,
+		javaconfig.JavaRuntimeDep,
+		javaconfig.JavaServiceLoaderRuntimeDeps,
 		javaconfig.JavaSourcesetRoot,
 		javaconfig.JavaStripResourcesPrefix,
 		javaconfig.JavaTestFileSuffixes,
@@ -84,6 +219,7 @@ func (jc *Configurer) KnownDirectives() []string {
 		javaconfig.JavaTestMode,
 		javaconfig.JvmKotlinEnabled,
 		javaconfig.JavaTestOnly,
+		javaconfig.JavaUnresolvedImportSeverity,
 		javaconfig.MavenIndexFile,
 	}
 }
@@ -154,6 +290,8 @@ func (jc *Configurer) Configure(c *config.Config, rel string, f *rule.File) {
 
 	// Process directives from BUILD file
 	if f != nil {
+		jc.lang.visibility.addPackage(rel)
+		var setMavenRepositoryName, setMavenInstallFile bool
 		for _, d := range f.Directives {
 			switch d.Key {
 			case javaconfig.JavaExcludeArtifact:
@@ -172,6 +310,7 @@ func (jc *Configurer) Configure(c *config.Config, rel string, f *rule.File) {
 
 			case javaconfig.JavaMavenInstallFile:
 				cfg.SetMavenInstallFile(d.Value)
+				setMavenInstallFile = true
 
 			case javaconfig.MavenIndexFile:
 				cfg.SetMavenIndexFile(d.Value)
@@ -189,6 +328,7 @@ func (jc *Configurer) Configure(c *config.Config, rel string, f *rule.File) {
 
 			case javaconfig.JavaMavenRepositoryName:
 				cfg.SetMavenRepositoryName(d.Value)
+				setMavenRepositoryName = true
 
 			case javaconfig.JavaGenerateProto:
 				switch d.Value {
@@ -248,6 +388,150 @@ func (jc *Configurer) Configure(c *config.Config, rel string, f *rule.File) {
 				)
 				cfg.AddAnnotationProcessorExtraImport(*annotationClassName, *extraImportClassName)
 
+			case javaconfig.JavaAnnotationProcessorGeneratedClass:
+				// Format: # gazelle:java_annotation_processor_generated_class com.google.auto.value.AutoValue AutoValue_{Outer}_{Name}
+				parts := strings.Fields(d.Value)
+				if len(parts) != 2 {
+					jc.lang.logger.Fatal().Msgf("invalid value for directive %q: %s: expected an annotation class-name followed by a generated class-name template",
+						javaconfig.JavaAnnotationProcessorGeneratedClass, d.Value)
+				}
+				annotationClassName, err := types.ParseClassName(parts[0])
+				if err != nil {
+					jc.lang.logger.Fatal().Msgf("invalid value for directive %q: %q: couldn't parse annotation class-name: %v", javaconfig.JavaAnnotationProcessorGeneratedClass, parts[0], err)
+				}
+				if err := validateGeneratedClassTemplate(parts[1]); err != nil {
+					jc.lang.logger.Fatal().Err(err).Msgf("invalid value for directive %q: %s", javaconfig.JavaAnnotationProcessorGeneratedClass, d.Value)
+				}
+				cfg.AddAnnotationProcessorGeneratedClass(*annotationClassName, parts[1])
+
+			case javaconfig.JavaAnnotationProcessorDiscovery:
+				switch d.Value {
+				case "true":
+					cfg.SetAnnotationProcessorDiscovery(true)
+				case "false":
+					cfg.SetAnnotationProcessorDiscovery(false)
+				default:
+					jc.lang.logger.Fatal().Msgf(binaryConfigError, javaconfig.JavaAnnotationProcessorDiscovery, d.Value)
+				}
+
+			case javaconfig.JavaKotlinCompilerPlugin:
+				// Format: # gazelle:java_kotlin_compiler_plugin kotlinx.serialization.Serializable //tools:serialization_plugin
+				parts := strings.Fields(d.Value)
+				if len(parts) != 2 {
+					jc.lang.logger.Fatal().Msgf("invalid value for directive %q: %s: expected an annotation class-name followed by a kt_compiler_plugin label",
+						javaconfig.JavaKotlinCompilerPlugin, d.Value)
+				}
+				annotationClassName, err := types.ParseClassName(parts[0])
+				if err != nil {
+					jc.lang.logger.Fatal().Msgf("invalid value for directive %q: %q: couldn't parse annotation class-name: %v", javaconfig.JavaKotlinCompilerPlugin, parts[0], err)
+				}
+				plugin, err := label.Parse(parts[1])
+				if err != nil {
+					jc.lang.logger.Fatal().Msgf("invalid value for directive %q: %q: couldn't parse kt_compiler_plugin label: %v", javaconfig.JavaKotlinCompilerPlugin, parts[1], err)
+				}
+				// A relative label names a target of the package of the directive.
+				cfg.AddKotlinCompilerPlugin(*annotationClassName, plugin.Abs("", rel))
+
+			case javaconfig.JavaPlatformPackage:
+				// Format: # gazelle:java_platform_package jakarta.servlet [//third_party:servlet_api_neverlink]
+				parts := strings.Fields(d.Value)
+				if len(parts) < 1 || len(parts) > 2 {
+					jc.lang.logger.Fatal().Msgf("invalid value for directive %q: %s: expected a package name, optionally followed by a label",
+						javaconfig.JavaPlatformPackage, d.Value)
+				}
+				var dep string
+				if len(parts) == 2 {
+					l, err := label.Parse(parts[1])
+					if err != nil {
+						jc.lang.logger.Fatal().Err(err).Msgf("invalid label for directive %q: %s", javaconfig.JavaPlatformPackage, d.Value)
+					}
+					// A relative label names a target of the package of the directive.
+					dep = l.Abs("", rel).String()
+				}
+				cfg.AddPlatformPackage(types.NewPackageName(parts[0]), dep)
+
+			case javaconfig.JavaRepositoryIndex:
+				// Format: # gazelle:java_repository_index platform third_party/platform_java_index.json
+				parts := strings.Fields(d.Value)
+				if len(parts) != 2 {
+					jc.lang.logger.Fatal().Msgf("invalid value for directive %q: %s: expected a repository name followed by the path of its index",
+						javaconfig.JavaRepositoryIndex, d.Value)
+				}
+				idx, err := jc.lang.loadRepositoryIndex(strings.TrimPrefix(parts[0], "@"), filepath.Join(c.RepoRoot, parts[1]))
+				if err != nil {
+					jc.lang.logger.Fatal().Err(err).Msgf("failed to load the index for directive %q: %s", javaconfig.JavaRepositoryIndex, d.Value)
+				}
+				cfg.AddRepositoryIndex(idx)
+
+			case javaconfig.JavaRelease:
+				// Format: # gazelle:java_release 17
+				release, err := parseJavaRelease(d.Value)
+				if err != nil {
+					jc.lang.logger.Fatal().Err(err).Msgf("invalid value for directive %q: %s", javaconfig.JavaRelease, d.Value)
+				}
+				cfg.SetRelease(release)
+
+			case javaconfig.JavaUnresolvedImportSeverity:
+				// Format: # gazelle:java_unresolved_import_severity com.legacy.* warn
+				parts := strings.Fields(d.Value)
+				if len(parts) != 2 {
+					jc.lang.logger.Fatal().Msgf("invalid value for directive %q: %s: expected a package glob followed by a severity",
+						javaconfig.JavaUnresolvedImportSeverity, d.Value)
+				}
+				if err := cfg.AddUnresolvedImportSeverity(parts[0], parts[1]); err != nil {
+					jc.lang.logger.Fatal().Err(err).Msgf("invalid value for directive %q: %s", javaconfig.JavaUnresolvedImportSeverity, d.Value)
+				}
+
+			case javaconfig.JavaDefaultVisibility:
+				// Format: # gazelle:java_default_visibility //src:__subpackages__,//tools:__pkg__
+				var visibility []string
+				for _, v := range strings.Split(d.Value, ",") {
+					if v = strings.TrimSpace(v); v == "" {
+						continue
+					}
+					if _, err := label.Parse(v); err != nil {
+						jc.lang.logger.Fatal().Err(err).Msgf("invalid label for directive %q: %s", javaconfig.JavaDefaultVisibility, d.Value)
+					}
+					visibility = append(visibility, v)
+				}
+				cfg.SetDefaultVisibility(visibility)
+
+			case javaconfig.JavaDependencyRule:
+				// Format: # gazelle:java_dependency_rule deny ..domain.. ..infra..
+				parts := strings.Fields(d.Value)
+				if len(parts) != 3 {
+					jc.lang.logger.Fatal().Msgf("invalid value for directive %q: %s: expected allow or deny, followed by the patterns of the importing and imported sides",
+						javaconfig.JavaDependencyRule, d.Value)
+				}
+				if err := cfg.AddDependencyRule(parts[0], parts[1], parts[2]); err != nil {
+					jc.lang.logger.Fatal().Err(err).Msgf("invalid value for directive %q: %s", javaconfig.JavaDependencyRule, d.Value)
+				}
+
+			case javaconfig.JavaRuntimeDep:
+				// Format: # gazelle:java_runtime_dep org.slf4j org.slf4j:slf4j-simple
+				parts := strings.Fields(d.Value)
+				if len(parts) != 2 || !isValidRuntimeDep(parts[1]) {
+					jc.lang.logger.Fatal().Msgf("invalid value for directive %q: %s: expected a package name followed by a versionless Maven coordinate or a label",
+						javaconfig.JavaRuntimeDep, d.Value)
+				}
+				dep := parts[1]
+				if isLabelRuntimeDep(dep) {
+					// A relative label names a target of the package of the directive.
+					l, _ := label.Parse(dep)
+					dep = l.Abs("", rel).String()
+				}
+				cfg.AddRuntimeDep(types.NewPackageName(parts[0]), dep)
+
+			case javaconfig.JavaServiceLoaderRuntimeDeps:
+				switch d.Value {
+				case "true":
+					cfg.SetServiceLoaderRuntimeDeps(true)
+				case "false":
+					cfg.SetServiceLoaderRuntimeDeps(false)
+				default:
+					jc.lang.logger.Fatal().Msgf(binaryConfigError, javaconfig.JavaServiceLoaderRuntimeDeps, d.Value)
+				}
+
 			case javaconfig.JavaResolveToJavaExports:
 				if !cfg.CanSetResolveToJavaExports() {
 					jc.lang.logger.Fatal().
@@ -284,6 +568,23 @@ func (jc *Configurer) Configure(c *config.Config, rel string, f *rule.File) {
 					jc.lang.logger.Fatal().Msgf("invalid value for directive %q: %s: possible values are true/false",
 						javaconfig.JvmKotlinEnabled, d.Value)
 				}
+			case javaconfig.JavaInferVisibility:
+				switch d.Value {
+				case "true":
+					cfg.SetInferVisibility(true)
+				case "false":
+					cfg.SetInferVisibility(false)
+				default:
+					jc.lang.logger.Fatal().Msgf(binaryConfigError, javaconfig.JavaInferVisibility, d.Value)
+				}
+
+			case javaconfig.JavaExtraVisibility:
+				// Format: # gazelle:java_extra_visibility //tools/codegen:__pkg__
+				if _, err := label.Parse(d.Value); err != nil {
+					jc.lang.logger.Fatal().Err(err).Msgf("invalid label for directive %q: %s", javaconfig.JavaExtraVisibility, d.Value)
+				}
+				cfg.AddExtraVisibility(d.Value)
+
 			case javaconfig.JavaTestOnly:
 				switch d.Value {
 				case "true":
@@ -306,6 +607,16 @@ func (jc *Configurer) Configure(c *config.Config, rel string, f *rule.File) {
 				}
 			}
 		}
+
+		if setMavenRepositoryName && !setMavenInstallFile {
+			// Follow the lock file of the install the directive names.
+			if install, found := maven.FindInstall(jc.mavenInstalls, cfg.MavenRepositoryName()); found {
+				jc.useMavenInstall(cfg, install)
+			}
+		}
+		if setMavenRepositoryName || setMavenInstallFile {
+			jc.checkMavenInstall(c.RepoRoot, cfg, f.Path)
+		}
 	}
 
 	if jc.lang.parser == nil {
@@ -317,11 +628,19 @@ func (jc *Configurer) Configure(c *config.Config, rel string, f *rule.File) {
 	}
 
 	if jc.lang.mavenResolver == nil {
-		resolver, err := maven.NewResolver(
+		opts := []maven.ResolverOption{
 			maven.WithInstallFile(cfg.MavenInstallFile()),
 			maven.WithIndexFile(cfg.MavenIndexFile()),
 			maven.WithLogger(jc.lang.logger),
-		)
+		}
+		if jc.mavenSnapshotFile != "" {
+			snapshotFile := jc.mavenSnapshotFile
+			if !filepath.IsAbs(snapshotFile) {
+				snapshotFile = filepath.Join(c.RepoRoot, snapshotFile)
+			}
+			opts = append(opts, maven.WithSnapshotFile(snapshotFile))
+		}
+		resolver, err := maven.NewResolver(opts...)
 		if err != nil {
 			jc.lang.logger.Fatal().Err(err).Msg("error creating Maven resolver")
 		}
@@ -329,6 +648,42 @@ func (jc *Configurer) Configure(c *config.Config, rel string, f *rule.File) {
 	}
 }
 
+// parseJavaRelease parses a Java release number. The legacy "1.8" spelling is accepted for
+// release 8.
+func parseJavaRelease(value string) (int, error) {
+	release, err := strconv.Atoi(strings.TrimPrefix(value, "1."))
+	if err != nil {
+		return 0, fmt.Errorf("expected a Java release number: %w", err)
+	}
+	if release < java.MinRelease {
+		return 0, fmt.Errorf("Java releases before %d are not supported", java.MinRelease)
+	}
+	return release, nil
+}
+
+// isValidRuntimeDep reports whether dep is a label, or a Maven coordinate without a version
+// (group:artifact, optionally followed by a classifier).
+func isValidRuntimeDep(dep string) bool {
+	if isLabelRuntimeDep(dep) {
+		_, err := label.Parse(dep)
+		return err == nil
+	}
+	parts := strings.Split(dep, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func isLabelRuntimeDep(dep string) bool {
+	return strings.HasPrefix(dep, "@") || strings.HasPrefix(dep, "//") || strings.HasPrefix(dep, ":")
+}
+
 func (jc *Configurer) parseTwoClassNamesDirective(
 	directive string,
 	value string,
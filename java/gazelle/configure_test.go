@@ -1,12 +1,20 @@
 package gazelle
 
 import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/javaparser"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
 	"github.com/bazelbuild/bazel-gazelle/config"
 	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
 	"github.com/bazelbuild/bazel-gazelle/testtools"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 )
 
@@ -28,3 +36,75 @@ func TestFlagParsing(t *testing.T) {
 	javaConfig := gazelleConfig.Exts[languageName].(javaconfig.Configs)
 	require.Equal(t, "install_maven.json", javaConfig[""].MavenInstallFile())
 }
+
+func TestMavenInstallDiscovery(t *testing.T) {
+	repoRoot := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(repoRoot, "MODULE.bazel"), []byte(`
+maven = use_extension("@rules_jvm_external//:extensions.bzl", "maven")
+maven.install(
+    name = "deps",
+    lock_file = "//third_party:deps_install.json",
+)
+use_repo(maven, "deps")
+`), 0o644))
+
+	for name, tc := range map[string]struct {
+		args            []string
+		wantInstallFile string
+		wantWarning     string
+	}{
+		"defaults from MODULE.bazel": {
+			wantInstallFile: "third_party/deps_install.json",
+		},
+		"mismatched flag": {
+			args:            []string{"-java-maven-install-file=maven_install.json"},
+			wantInstallFile: "maven_install.json",
+			wantWarning:     "maven install file does not match the lock_file of the maven.install in MODULE.bazel",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var logs bytes.Buffer
+			lang := NewLanguage().(*javaLang)
+			lang.logger = zerolog.New(&logs)
+			configurer := NewConfigurer(lang)
+
+			c := config.New()
+			c.RepoRoot = repoRoot
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			configurer.RegisterFlags(fs, "update", c)
+			require.NoError(t, fs.Parse(tc.args))
+			require.NoError(t, configurer.CheckFlags(fs, c))
+
+			javaConfig := c.Exts[languageName].(javaconfig.Configs)[""]
+			require.Equal(t, "deps", javaConfig.MavenRepositoryName())
+			require.Equal(t, filepath.Join(repoRoot, tc.wantInstallFile), javaConfig.MavenInstallFile())
+			if tc.wantWarning == "" {
+				require.Empty(t, logs.String())
+			} else {
+				require.Contains(t, logs.String(), tc.wantWarning)
+			}
+		})
+	}
+}
+
+func TestRelativeLabelDirectives(t *testing.T) {
+	lang := NewLanguage().(*javaLang)
+	// Configure starts neither the parser nor the Maven resolver when they are set.
+	lang.parser = &javaparser.Runner{}
+	lang.mavenResolver = &testResolver{}
+	configurer := NewConfigurer(lang)
+	c := testtools.NewTestConfig(t, []config.Configurer{configurer}, []language.Language{}, nil)
+
+	f, err := rule.LoadData("third_party/BUILD.bazel", "third_party", []byte(`# gazelle:java_platform_package jakarta.servlet :servlet_api
+# gazelle:java_runtime_dep org.slf4j :slf4j_simple`))
+	require.NoError(t, err)
+	configurer.Configure(c, "", nil)
+	configurer.Configure(c, "third_party", f)
+	configurer.Configure(c, "third_party/web", nil)
+
+	cfg := c.Exts[languageName].(javaconfig.Configs)["third_party/web"]
+	dep, found := cfg.PlatformPackage(types.NewPackageName("jakarta.servlet.http"))
+	require.True(t, found)
+	require.Equal(t, "//third_party:servlet_api", dep)
+	require.Equal(t, []string{"//third_party:slf4j_simple"}, cfg.RuntimeDeps(types.NewPackageName("org.slf4j")).SortedSlice())
+}
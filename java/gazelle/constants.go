@@ -5,3 +5,31 @@ package gazelle
 // for. Note that the Java plugin currently uses package names, not classes, as its importable unit.
 const packagesKey = "_java_packages"
 const classesKey = "_java_classes"
+
+// moduleKey is the name of a private attribute set on the java_library rules whose sources
+// declare a JPMS module, holding the *java.Module.
+const moduleKey = "_java_module"
+
+// servicesKey is the name of a private attribute set on the java_library rules providing
+// implementations of services registered by a META-INF/services file, holding the names of
+// the services. The providers of test libraries are indexed apart, for tests only.
+const servicesKey = "_java_services"
+
+// Prefixes of the import specs under which a library declaring a JPMS module is indexed, by
+// its module name, by the services it provides, also through META-INF/services files, and by
+// the packages it doesn't export, which only tests resolve. A test library is indexed by the
+// services it provides through META-INF/services files under its own prefix.
+const (
+	moduleImportPrefix         = "module:"
+	serviceImportPrefix        = "service:"
+	moduleInternalImportPrefix = "module-internal:"
+	testServiceImportPrefix    = "test-service:"
+)
+
+// dependencyAttrs are the attributes through which a rule depends on a library: the edges of
+// the dependency graph, and what visibility is inferred from.
+var dependencyAttrs = []string{"associates", "deps", "exports", "plugins", "runtime_deps"}
+
+// sharedResourcesLibraryName is the name of the java_library wrapping the resources of a
+// resources root in package granularity, or of a module whose groups share them.
+const sharedResourcesLibraryName = "resources_lib"
@@ -0,0 +1,202 @@
+package gazelle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/rs/zerolog"
+)
+
+// Reasons of the edges which were not added for an import, see resolutionRoute for the others.
+const (
+	graphReasonExisting   = "existing"
+	graphReasonAssociate  = "associate"
+	graphReasonPlugin     = "annotation_processor"
+	graphReasonRuntimeDep = "runtime_dep"
+)
+
+// dependencyGraph records the resolved rules and the labels they depend on, to be written as
+// JSON and Graphviz DOT once resolving is done.
+type dependencyGraph struct {
+	jsonPath string
+	dotPath  string
+	nodes    map[string]*graphNode
+	edges    []graphEdge
+	// resolved maps each rule, attribute and label added for an import to how it was resolved.
+	resolved map[string]graphEdge
+}
+
+type graphNode struct {
+	Label    string   `json:"label"`
+	Kind     string   `json:"kind"`
+	Srcs     []string `json:"srcs"`
+	Packages []string `json:"packages"`
+	Classes  []string `json:"classes"`
+}
+
+type graphEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Attr   string `json:"attr"`
+	Reason string `json:"reason"`
+	// Imports are the imported packages which required the edge, sorted.
+	Imports []string `json:"imports,omitempty"`
+	// Classes are the imported classes the edge was resolved for specifically, sorted.
+	Classes []string `json:"classes,omitempty"`
+}
+
+func newDependencyGraph(jsonPath, dotPath string) *dependencyGraph {
+	return &dependencyGraph{
+		jsonPath: jsonPath,
+		dotPath:  dotPath,
+		nodes:    make(map[string]*graphNode),
+		resolved: make(map[string]graphEdge),
+	}
+}
+
+func graphEdgeKey(from, attr, to string) string {
+	return from + " " + attr + " " + to
+}
+
+// absoluteLabel returns l, relative to the rule from, as an absolute label.
+func absoluteLabel(l label.Label, from label.Label) string {
+	return l.Abs(from.Repo, from.Pkg).String()
+}
+
+// addResolved records that dep was added to attr of the rule from for an import of imp or, if
+// not nil, of class. The edge keeps the route of its first import.
+func (dg *dependencyGraph) addResolved(from label.Label, attr string, dep label.Label, imp types.PackageName, class *types.ClassName, route resolutionRoute) {
+	to := absoluteLabel(dep, from)
+	key := graphEdgeKey(from.String(), attr, to)
+	e, ok := dg.resolved[key]
+	if !ok {
+		e = graphEdge{From: from.String(), To: to, Attr: attr, Reason: string(route)}
+	}
+	e.Imports = insertSorted(e.Imports, imp.Name)
+	if class != nil {
+		e.Classes = insertSorted(e.Classes, class.FullyQualifiedClassName())
+	}
+	dg.resolved[key] = e
+}
+
+// insertSorted inserts v into the sorted slice s, unless it is already there.
+func insertSorted(s []string, v string) []string {
+	i := sort.SearchStrings(s, v)
+	if i < len(s) && s[i] == v {
+		return s
+	}
+	return slices.Insert(s, i, v)
+}
+
+// recordRule records the resolved rule r, labelled from, and its edges.
+func (dg *dependencyGraph) recordRule(from label.Label, r *rule.Rule, packages *sorted_set.SortedSet[types.PackageName], classes []types.ClassName) {
+	node := &graphNode{Label: from.String(), Kind: r.Kind(), Srcs: []string{}, Packages: []string{}, Classes: []string{}}
+	node.Srcs = append(node.Srcs, r.AttrStrings("srcs")...)
+	if packages != nil {
+		for _, pkg := range packages.SortedSlice() {
+			node.Packages = append(node.Packages, pkg.Name)
+		}
+	}
+	for _, class := range classes {
+		node.Classes = append(node.Classes, class.FullyQualifiedClassName())
+	}
+	dg.nodes[node.Label] = node
+
+	for _, attr := range dependencyAttrs {
+		for _, s := range r.AttrStrings(attr) {
+			l, err := label.Parse(s)
+			if err != nil {
+				continue
+			}
+			to := absoluteLabel(l, from)
+			if e, ok := dg.resolved[graphEdgeKey(node.Label, attr, to)]; ok {
+				dg.edges = append(dg.edges, e)
+				continue
+			}
+			dg.edges = append(dg.edges, graphEdge{From: node.Label, To: to, Attr: attr, Reason: defaultGraphReason(attr)})
+		}
+	}
+}
+
+// defaultGraphReason returns the reason of an edge of attr which was not added for an import.
+func defaultGraphReason(attr string) string {
+	switch attr {
+	case "associates":
+		return graphReasonAssociate
+	case "plugins":
+		return graphReasonPlugin
+	case "runtime_deps":
+		return graphReasonRuntimeDep
+	}
+	return graphReasonExisting
+}
+
+func (dg *dependencyGraph) sorted() ([]*graphNode, []graphEdge) {
+	nodes := make([]*graphNode, 0, len(dg.nodes))
+	for _, n := range dg.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Label < nodes[j].Label })
+
+	edges := append([]graphEdge{}, dg.edges...)
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		if edges[i].To != edges[j].To {
+			return edges[i].To < edges[j].To
+		}
+		return edges[i].Attr < edges[j].Attr
+	})
+	return nodes, edges
+}
+
+// write writes the graph to the requested files.
+func (dg *dependencyGraph) write(logger zerolog.Logger) {
+	nodes, edges := dg.sorted()
+	if dg.jsonPath != "" {
+		data, err := json.MarshalIndent(struct {
+			Nodes []*graphNode `json:"nodes"`
+			Edges []graphEdge  `json:"edges"`
+		}{nodes, edges}, "", "  ")
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to encode dependency graph")
+		} else if err := os.WriteFile(dg.jsonPath, append(data, '\n'), 0o644); err != nil {
+			logger.Error().Err(err).Str("path", dg.jsonPath).Msg("failed to write dependency graph")
+		}
+	}
+	if dg.dotPath != "" {
+		if err := os.WriteFile(dg.dotPath, []byte(formatDot(nodes, edges)), 0o644); err != nil {
+			logger.Error().Err(err).Str("path", dg.dotPath).Msg("failed to write dependency graph")
+		}
+	}
+}
+
+// formatDot formats the graph in the Graphviz DOT language. Rules are labelled with their kind,
+// and edges with their attribute and reason; exports are dashed.
+func formatDot(nodes []*graphNode, edges []graphEdge) string {
+	var b strings.Builder
+	b.WriteString("digraph dependencies {\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, n := range nodes {
+		fmt.Fprintf(&b, "  %s [label=%s];\n", strconv.Quote(n.Label), strconv.Quote(n.Label+"\n"+n.Kind))
+	}
+	for _, e := range edges {
+		attrs := "label=" + strconv.Quote(e.Attr+": "+e.Reason)
+		if e.Attr == "exports" {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), attrs)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package gazelle
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	dir := t.TempDir()
+	graphPath := filepath.Join(dir, "graph.json")
+	dotPath := filepath.Join(dir, "graph.dot")
+	c, langs, jLang := testJavaLang(t, "-java-dependency-graph="+graphPath, "-java-dependency-graph-dot="+dotPath)
+	mrslv, exts := InitTestResolversAndExtensions(langs)
+	ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+	require.NotNil(t, jLang.dependencyGraph)
+	jLang.classExportCache["//:lib"] = classExportInfo{classes: []types.ClassName{types.NewClassName(types.NewPackageName("com.example.lib"), "Lib")}}
+
+	const content = `java_library(
+    name = "lib",
+    srcs = ["Lib.java"],
+    _packages = [
+        "com.example.lib",
+        "com.example.lib.util",
+    ],
+)
+
+java_library(
+    name = "app",
+    srcs = ["App.java"],
+    runtime_deps = ["//tools:agent"],
+    _imported_packages = [
+        "com.example.lib",
+        "com.example.lib.util",
+        "com.google.common.primitives",
+    ],
+    _packages = ["com.example.app"],
+)`
+	f, err := rule.LoadData("BUILD.bazel", "", []byte(content))
+	require.NoError(t, err)
+	imports := make([]interface{}, len(f.Rules))
+	for i, r := range f.Rules {
+		setPackagesPrivateAttr(r)
+		imports[i] = convertImportsAttr(r)
+		ix.AddRule(c, r, f)
+	}
+	ix.Finish()
+	for i, r := range f.Rules {
+		mrslv.Resolver(r, "").Resolve(c, ix, testRemoteCache(nil), r, imports[i], label.New("", "", r.Name()))
+	}
+	jLang.dependencyGraph.write(zerolog.Nop())
+
+	data, err := os.ReadFile(graphPath)
+	require.NoError(t, err)
+	var got struct {
+		Nodes []graphNode `json:"nodes"`
+		Edges []graphEdge `json:"edges"`
+	}
+	require.NoError(t, json.Unmarshal(data, &got))
+	require.Equal(t, []graphNode{
+		{Label: "//:app", Kind: "java_library", Srcs: []string{"App.java"}, Packages: []string{"com.example.app"}, Classes: []string{}},
+		{Label: "//:lib", Kind: "java_library", Srcs: []string{"Lib.java"}, Packages: []string{"com.example.lib", "com.example.lib.util"}, Classes: []string{"com.example.lib.Lib"}},
+	}, got.Nodes)
+	require.Equal(t, []graphEdge{
+		{From: "//:app", To: "//:lib", Attr: "deps", Reason: "index", Imports: []string{"com.example.lib", "com.example.lib.util"}},
+		{From: "//:app", To: "//tools:agent", Attr: "runtime_deps", Reason: "runtime_dep"},
+		{From: "//:app", To: "@maven//:com_google_guava_guava", Attr: "deps", Reason: "maven_package", Imports: []string{"com.google.common.primitives"}},
+	}, got.Edges)
+
+	dot, err := os.ReadFile(dotPath)
+	require.NoError(t, err)
+	require.Equal(t, `digraph dependencies {
+  node [shape=box];
+  "//:app" [label="//:app\njava_library"];
+  "//:lib" [label="//:lib\njava_library"];
+  "//:app" -> "//:lib" [label="deps: index"];
+  "//:app" -> "//tools:agent" [label="runtime_deps: runtime_dep"];
+  "//:app" -> "@maven//:com_google_guava_guava" [label="deps: maven_package"];
+}
+`, string(dot))
+}
@@ -0,0 +1,252 @@
+package gazelle
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/scc"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/rs/zerolog"
+)
+
+// fileGroup is a set of source files of one package which compile as a single library in
+// file granularity: a lone file, or files importing each other cyclically.
+type fileGroup struct {
+	// Name is the name of the library, from its first file.
+	Name string
+	// Files are the member files, relative to the package, sorted.
+	Files []string
+}
+
+// fileLibraryName returns the name of the library of a group whose first file is file. The
+// suffix keeps it apart from a java_binary named after the main class of the file.
+func fileLibraryName(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file)) + "-lib"
+}
+
+// filePackage returns the part of pkg parsed from file: its imports and classes, and the
+// metadata of the classes it declares.
+func filePackage(pkg *java.Package, file string) *java.Package {
+	data := pkg.PerFileData[file]
+	filePkg := &java.Package{
+		Name:                                   pkg.Name,
+		ImportedClasses:                        data.ImportedClasses,
+		ExportedClasses:                        data.ExportedClasses,
+		InternalClasses:                        data.InternalClasses,
+		DeclaredClasses:                        data.DeclaredClasses,
+		ImportedPackagesWithoutSpecificClasses: data.ImportedPackagesWithoutSpecificClasses,
+		Files:                                  sorted_set.NewSortedSet([]string{file}),
+		TestPackage:                            pkg.TestPackage,
+		PerClassMetadata:                       make(map[string]java.PerClassMetadata),
+	}
+	outerClassNames := fileOuterClassNames(file, filePkg)
+	for class, metadata := range pkg.PerClassMetadata {
+		className, err := types.ParseClassName(class)
+		if err == nil && className.PackageName() == pkg.Name && outerClassNames.Contains(className.BareOuterClassName()) {
+			filePkg.PerClassMetadata[class] = metadata
+		}
+	}
+	return filePkg
+}
+
+// fileOuterClassNames returns the bare names of the top-level classes of file, whose part of
+// its package is filePkg, including those generated for them by annotation processors.
+func fileOuterClassNames(file string, filePkg *java.Package) *sorted_set.SortedSet[string] {
+	names := sorted_set.NewSortedSet([]string{})
+	base := strings.TrimSuffix(file, filepath.Ext(file))
+	names.Add(base)
+	if strings.HasSuffix(file, ".kt") {
+		// Top level values and functions in Kotlin are accessible from Java under the <filename>Kt class.
+		names.Add(base + "Kt")
+	}
+	if filePkg.DeclaredClasses != nil {
+		for _, cn := range filePkg.DeclaredClasses.SortedSlice() {
+			names.Add(cn.BareOuterClassName())
+		}
+	}
+	for _, cn := range filePkg.GeneratedClasses.SortedSlice() {
+		names.Add(cn.BareOuterClassName())
+	}
+	return names
+}
+
+// groupFiles splits the files of the package pkg, with their parts of it, into the minimal
+// set of groups which can compile as separate libraries. A file depends on the files
+// declaring the classes of pkg it references, and shares a Kotlin module with the files
+// whose `internal` symbols it references, as in the scc package. It returns the groups,
+// sorted by their first file, and the group of each file.
+func groupFiles(pkg types.PackageName, packagesByFile map[string]*java.Package) ([]*fileGroup, map[string]*fileGroup) {
+	files := make([]string, 0, len(packagesByFile))
+	for file := range packagesByFile {
+		files = append(files, file)
+	}
+	files = sorted_set.NewSortedSet(files).SortedSlice()
+
+	classOwners := make(map[string]string)
+	internalOwners := make(map[string][]string)
+	for _, file := range files {
+		filePkg := packagesByFile[file]
+		for _, name := range fileOuterClassNames(file, filePkg).SortedSlice() {
+			if _, ok := classOwners[name]; !ok {
+				classOwners[name] = file
+			}
+		}
+		if filePkg.InternalClasses != nil {
+			for _, cn := range filePkg.InternalClasses.SortedSlice() {
+				fqn := cn.FullyQualifiedClassName()
+				internalOwners[fqn] = append(internalOwners[fqn], file)
+			}
+		}
+	}
+
+	adjacency := make(map[string]*sorted_set.SortedSet[string], len(files))
+	for _, file := range files {
+		adjacency[file] = sorted_set.NewSortedSet([]string{})
+	}
+	addEdge := func(from, to string) {
+		if from != to {
+			adjacency[from].Add(to)
+		}
+	}
+	for _, file := range files {
+		imported := packagesByFile[file].ImportedClasses
+		if imported == nil {
+			continue
+		}
+		for _, cn := range imported.SortedSlice() {
+			if cn.PackageName() == pkg {
+				if owner, ok := classOwners[cn.BareOuterClassName()]; ok {
+					addEdge(file, owner)
+				}
+			}
+			for _, owner := range internalOwners[cn.FullyQualifiedClassName()] {
+				addEdge(file, owner)
+				addEdge(owner, file)
+			}
+		}
+	}
+
+	var groups []*fileGroup
+	groupOf := make(map[string]*fileGroup, len(files))
+	for _, component := range scc.Components(files, adjacency) {
+		group := &fileGroup{Name: fileLibraryName(component[0]), Files: component}
+		groups = append(groups, group)
+		for _, file := range component {
+			groupOf[file] = group
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Files[0] < groups[j].Files[0] })
+	return groups, groupOf
+}
+
+// emitFileProductionLibraries emits a library for each group of the production files of the
+// package, see groupFiles. Each group registers the classes it declares, so imports of the
+// package resolve, at class granularity, to the groups declaring the imported classes. It
+// returns the name of the library declaring each top-level class, for java_binary targets.
+func (l javaLang) emitFileProductionLibraries(args language.GenerateArgs, cfg *javaconfig.Config, javaPkg *java.Package, files []string, resourcesRuntimeDep string, res *language.GenerateResult, log zerolog.Logger) map[string]string {
+	packagesByFile := make(map[string]*java.Package, len(files))
+	for _, file := range files {
+		if file == java.ModuleInfoFile {
+			// A module-info.java can't compile without the packages of its module.
+			log.Warn().Str("file", filepath.Join(args.Rel, file)).Msg("module-info.java is not built in file granularity")
+			continue
+		}
+		filePkg := filePackage(javaPkg, file)
+		filePkg.GeneratedClasses = generatedClasses(cfg, filePkg)
+		packagesByFile[file] = filePkg
+	}
+
+	groups, groupOf := groupFiles(javaPkg.Name, packagesByFile)
+	packages := sorted_set.NewSortedSetFn([]types.PackageName{javaPkg.Name}, types.PackageNameLess)
+	allLocalClassNames := sorted_set.NewSortedSet([]string{})
+	classOwners := make(map[string]*fileGroup)
+	for _, file := range files {
+		filePkg, ok := packagesByFile[file]
+		if !ok {
+			continue
+		}
+		for _, name := range fileOuterClassNames(file, filePkg).SortedSlice() {
+			allLocalClassNames.Add(name)
+			if _, ok := classOwners[name]; !ok {
+				classOwners[name] = groupOf[file]
+			}
+		}
+	}
+
+	libraries := make(map[string]string)
+	for _, group := range groups {
+		imports := sorted_set.NewSortedSetFn([]types.PackageName{}, types.PackageNameLess)
+		importedClasses := sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess)
+		exports := sorted_set.NewSortedSetFn([]types.PackageName{}, types.PackageNameLess)
+		externalExportedClasses := sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess)
+		ownClasses := sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess)
+		annotationProcessorClasses := sorted_set.NewSortedSetFn(nil, types.ClassNameLess)
+		annotationClasses := sorted_set.NewSortedSetFn(nil, types.ClassNameLess)
+		unusedTestImports := sorted_set.NewSortedSetFn([]types.PackageName{}, types.PackageNameLess)
+		unusedTestImportedClasses := sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess)
+		siblingClasses := sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess)
+
+		libraryKind := "java_library"
+		srcs := make([]string, 0, len(group.Files))
+		for _, file := range group.Files {
+			filePkg := packagesByFile[file]
+			if strings.HasSuffix(file, ".kt") {
+				libraryKind = "kt_jvm_library"
+			}
+			srcs = append(srcs, filepath.Join(args.Rel, file))
+			addNonLocalImportsAndExports(imports, importedClasses, exports, externalExportedClasses, filePkg.ImportedClasses, filePkg.ImportedPackagesWithoutSpecificClasses, filePkg.ExportedClasses, javaPkg.Name, allLocalClassNames)
+			l.addAnnotationProcessorClassesAndExtraImports(cfg, filePkg, annotationProcessorClasses, annotationClasses, imports, importedClasses, unusedTestImports, unusedTestImportedClasses)
+
+			for _, name := range fileOuterClassNames(file, filePkg).SortedSlice() {
+				ownClasses.Add(types.NewClassName(javaPkg.Name, name))
+				libraries[name] = group.Name
+			}
+
+			if filePkg.ImportedClasses != nil {
+				for _, cn := range filePkg.ImportedClasses.SortedSlice() {
+					if owner, ok := classOwners[cn.BareOuterClassName()]; ok && cn.PackageName() == javaPkg.Name && owner != group {
+						siblingClasses.Add(types.NewClassName(javaPkg.Name, cn.BareOuterClassName()))
+					}
+				}
+			}
+		}
+
+		// As in package granularity, the package itself is not an import, except for the
+		// classes declared by other groups, which are dependencies on them.
+		imports = imports.Filter(func(p types.PackageName) bool { return p != javaPkg.Name })
+		importedClasses = importedClasses.Filter(func(c types.ClassName) bool { return c.PackageName() != javaPkg.Name })
+		exports = exports.Filter(func(p types.PackageName) bool { return p != javaPkg.Name })
+		externalExportedClasses = externalExportedClasses.Filter(func(c types.ClassName) bool { return c.PackageName() != javaPkg.Name })
+		if siblingClasses.Len() > 0 {
+			imports.Add(javaPkg.Name)
+			importedClasses.AddAll(siblingClasses)
+		}
+
+		log.Debug().Str("library", group.Name).Strs("files", group.Files).Msg("file granularity library")
+		l.generateJavaLibrary(generateJavaLibraryArgs{
+			File:                    args.File,
+			Rel:                     args.Rel,
+			LibraryKind:             libraryKind,
+			Result:                  res,
+			Config:                  cfg,
+			Name:                    group.Name,
+			Srcs:                    srcs,
+			ResourcesRuntimeDep:     resourcesRuntimeDep,
+			Packages:                packages,
+			Imports:                 imports,
+			ImportedClasses:         importedClasses,
+			Exports:                 exports,
+			ExportedClasses:         ownClasses,
+			ExternalExportedClasses: externalExportedClasses,
+			AnnotationProcessors:    annotationProcessorClasses,
+			Annotations:             annotationClasses,
+			TestOnly:                cfg.TestOnly(),
+		})
+	}
+	return libraries
+}
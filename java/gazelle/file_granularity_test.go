@@ -0,0 +1,101 @@
+package gazelle
+
+import (
+	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupFiles(t *testing.T) {
+	classes := func(names ...string) *sorted_set.SortedSet[types.ClassName] {
+		set := sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess)
+		for _, name := range names {
+			cn, err := types.ParseClassName(name)
+			require.NoError(t, err)
+			set.Add(*cn)
+		}
+		return set
+	}
+	file := func(imported, declared, internal []string) *java.Package {
+		return &java.Package{
+			Name:            types.NewPackageName("com.example"),
+			ImportedClasses: classes(imported...),
+			DeclaredClasses: classes(declared...),
+			InternalClasses: classes(internal...),
+		}
+	}
+
+	groups, groupOf := groupFiles(types.NewPackageName("com.example"), map[string]*java.Package{
+		// Api <-> Impl is a cycle; Client only depends on Api.
+		"Api.java":    file([]string{"com.example.Impl", "java.util.List"}, nil, nil),
+		"Impl.java":   file([]string{"com.example.Api"}, nil, nil),
+		"Client.java": file([]string{"com.example.Api", "com.google.common.base.Strings"}, nil, nil),
+		// Helpers.kt declares Helper, which Model.java uses.
+		"Helpers.kt": file(nil, []string{"com.example.Helper"}, nil),
+		"Model.java": file([]string{"com.example.Helper"}, nil, nil),
+		// Uses an internal symbol of Secret.kt, so they share a Kotlin module.
+		"Friend.kt": file([]string{"com.example.Secret"}, nil, nil),
+		"Secret.kt": file(nil, nil, []string{"com.example.Secret"}),
+	})
+
+	var got [][]string
+	for _, g := range groups {
+		got = append(got, g.Files)
+	}
+	require.Equal(t, [][]string{
+		{"Api.java", "Impl.java"},
+		{"Client.java"},
+		{"Friend.kt", "Secret.kt"},
+		{"Helpers.kt"},
+		{"Model.java"},
+	}, got)
+	require.Equal(t, "Api-lib", groupOf["Impl.java"].Name)
+	require.Equal(t, "Friend-lib", groupOf["Secret.kt"].Name)
+}
+
+func TestFilePackage(t *testing.T) {
+	classes := func(names ...string) *sorted_set.SortedSet[types.ClassName] {
+		set := sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess)
+		for _, name := range names {
+			cn, err := types.ParseClassName(name)
+			require.NoError(t, err)
+			set.Add(*cn)
+		}
+		return set
+	}
+	metadata := java.PerClassMetadata{AnnotationClassNames: classes("com.example.annotations.Entity")}
+	pkg := &java.Package{
+		Name: types.NewPackageName("com.example"),
+		PerFileData: map[string]java.FileData{
+			"Api.java": {
+				ImportedClasses: classes("java.util.List"),
+				ExportedClasses: classes("java.util.List"),
+				DeclaredClasses: classes("com.example.Api", "com.example.ApiHelper"),
+			},
+			"Impl.java": {
+				ImportedClasses: classes("com.example.Api"),
+				DeclaredClasses: classes("com.example.Impl"),
+			},
+		},
+		PerClassMetadata: map[string]java.PerClassMetadata{
+			"com.example.Api.Nested": metadata,
+			"com.example.ApiHelper":  metadata,
+			"com.example.Impl":       metadata,
+		},
+		TestPackage: true,
+	}
+
+	filePkg := filePackage(pkg, "Api.java")
+	require.Equal(t, pkg.Name, filePkg.Name)
+	require.Equal(t, []string{"Api.java"}, filePkg.Files.SortedSlice())
+	require.Equal(t, classes("java.util.List").SortedSlice(), filePkg.ImportedClasses.SortedSlice())
+	require.Equal(t, classes("java.util.List").SortedSlice(), filePkg.ExportedClasses.SortedSlice())
+	require.True(t, filePkg.TestPackage)
+	require.Equal(t, map[string]java.PerClassMetadata{
+		"com.example.Api.Nested": metadata,
+		"com.example.ApiHelper":  metadata,
+	}, filePkg.PerClassMetadata)
+}
@@ -44,10 +44,11 @@ func javaFileLess(l, r javaFile) bool {
 	return l.pathRelativeToBazelWorkspaceRoot < r.pathRelativeToBazelWorkspaceRoot
 }
 
-func addAnnotationProcessorClassesAndExtraImports(
+func (l javaLang) addAnnotationProcessorClassesAndExtraImports(
 	cfg *javaconfig.Config,
 	javaPkg *java.Package,
 	annotationProcessorClasses *sorted_set.SortedSet[types.ClassName],
+	annotations *sorted_set.SortedSet[types.ClassName],
 	productionJavaImports *sorted_set.SortedSet[types.PackageName],
 	productionJavaImportedClasses *sorted_set.SortedSet[types.ClassName],
 	testJavaImports *sorted_set.SortedSet[types.PackageName],
@@ -61,7 +62,8 @@ func addAnnotationProcessorClassesAndExtraImports(
 	}
 
 	for _, annotationClass := range javaPkg.AllAnnotations().SortedSlice() {
-		annotationProcessorClasses.AddAll(cfg.GetAnnotationProcessorPluginClasses(annotationClass))
+		annotations.Add(annotationClass)
+		annotationProcessorClasses.AddAll(l.annotationProcessorPluginClasses(cfg, annotationClass))
 		extraImports := cfg.GetAnnotationProcessorExtraImports(annotationClass)
 		if extraImports == nil {
 			continue
@@ -73,6 +75,20 @@ func addAnnotationProcessorClassesAndExtraImports(
 	}
 }
 
+// annotationProcessorPluginClasses returns the processors to run for annotationClass: those
+// configured with java_annotation_processor_plugin, plus (unless discovery is disabled) those the
+// Maven index records as handling the annotation's package.
+func (l javaLang) annotationProcessorPluginClasses(cfg *javaconfig.Config, annotationClass types.ClassName) *sorted_set.SortedSet[types.ClassName] {
+	processors := sorted_set.NewSortedSetFn(nil, types.ClassNameLess)
+	processors.AddAll(cfg.GetAnnotationProcessorPluginClasses(annotationClass))
+	if cfg.AnnotationProcessorDiscovery() && l.mavenResolver != nil {
+		for _, processor := range l.mavenResolver.AnnotationProcessors(annotationClass) {
+			processors.Add(processor)
+		}
+	}
+	return processors
+}
+
 type separateJavaTestReasons struct {
 	attributes map[string]bzl.Expr
 	wrapper    string
@@ -86,6 +102,7 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 
 	cfgs := args.Config.Exts[languageName].(javaconfig.Configs)
 	cfg := cfgs[args.Rel]
+	l.visibility.addGeneratedPackage(args.Rel, args.File)
 
 	var res language.GenerateResult
 	if !cfg.ExtensionEnabled() {
@@ -116,10 +133,12 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 	isResourcesSubdir := strings.Contains(args.Rel, "/resources/") && !isResourcesRoot
 	granularity := cfg.ModuleGranularity()
 	// "module" emits one coarse library for the whole subtree; "scc" emits the minimal set
-	// of fine-grained libraries, collapsing only import cycles and Kotlin internal coupling.
-	// Both aggregate every sub-package at the module root (cache, delete intermediate BUILDs,
-	// emit at the root); they differ only in how the production library/libraries are emitted.
-	aggregateAtRoot := granularity == "module" || granularity == "scc"
+	// of fine-grained libraries, collapsing only import cycles and Kotlin internal coupling,
+	// and "auto" does the same, logging the grouping. They all aggregate every sub-package at
+	// the module root (cache, delete intermediate BUILDs, emit at the root), which is the only
+	// directory generated once Gazelle has walked them all; they differ only in how the
+	// production library/libraries are emitted.
+	aggregateAtRoot := granularity == "module" || granularity == "scc" || granularity == "auto"
 
 	generateResources := cfg.GenerateResources()
 
@@ -160,6 +179,9 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 		if err != nil {
 			log.Fatal().Err(err).Str("package", args.Rel).Msg("Failed to parse package")
 		}
+		javaPkg.GeneratedClasses = generatedClasses(cfg, javaPkg)
+		l.serviceLoaders.addPackage(javaPkg)
+		l.packageCycles.addPackage(args.Rel, javaPkg)
 	}
 
 	// We exclude intra-package imports to avoid self-dependencies.
@@ -179,6 +201,9 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 			likelyLocalClassNames.Add(strings.TrimSuffix(filename, ".java"))
 		}
 	}
+	for _, cn := range javaPkg.GeneratedClasses.SortedSlice() {
+		likelyLocalClassNames.Add(cn.BareOuterClassName())
+	}
 
 	if aggregateAtRoot {
 		if len(srcFilenamesRelativeToPackage) > 0 {
@@ -230,19 +255,44 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 	allPackageNames := sorted_set.NewSortedSetFn([]types.PackageName{}, types.PackageNameLess)
 
 	annotationProcessorClasses := sorted_set.NewSortedSetFn(nil, types.ClassNameLess)
+	annotationClasses := sorted_set.NewSortedSetFn(nil, types.ClassNameLess)
+
+	// The JPMS module declared by a module-info.java among the production sources, if any.
+	var module *java.Module
+	moduleRel := ""
+	// The production packages, which may declare annotation processors.
+	var productionPackages []*java.Package
 
 	if aggregateAtRoot {
 		for mRel, mJavaPkg := range l.javaPackageCache {
 			if !strings.HasPrefix(mRel, args.Rel) {
 				continue
 			}
-			allPackageNames.Add(mJavaPkg.Name)
+			if mJavaPkg.Module != nil && !mJavaPkg.TestPackage {
+				if module != nil {
+					log.Warn().
+						Str("module", mJavaPkg.Module.Name).
+						Str("other module", module.Name).
+						Msg("More than one module-info.java in the sources of a library, using the outermost one")
+				}
+				if module == nil || mRel < moduleRel {
+					module, moduleRel = mJavaPkg.Module, mRel
+				}
+			}
+			if !isModuleInfoOnly(mJavaPkg) {
+				allPackageNames.Add(mJavaPkg.Name)
+			}
 
 			if !mJavaPkg.TestPackage {
+				productionPackages = append(productionPackages, mJavaPkg)
 				addNonLocalImportsAndExports(productionJavaImports, productionJavaImportedClasses, nonLocalJavaExports, nonLocalJavaExternalExportedClasses, mJavaPkg.ImportedClasses, mJavaPkg.ImportedPackagesWithoutSpecificClasses, mJavaPkg.ExportedClasses, mJavaPkg.Name, likelyLocalClassNames)
 				nonLocalJavaExportedClasses.AddAll(mJavaPkg.DeclaredClasses)
+				nonLocalJavaExportedClasses.AddAll(mJavaPkg.GeneratedClasses)
 				for _, f := range mJavaPkg.Files.SortedSlice() {
 					productionJavaFiles.Add(filepath.Join(mRel, f))
+					if f == java.ModuleInfoFile {
+						continue
+					}
 					jf := javaFile{pathRelativeToBazelWorkspaceRoot: filepath.Join(mRel, f), pkg: mJavaPkg.Name}
 					nonLocalJavaExportedClasses.Add(*jf.ClassName())
 				}
@@ -262,11 +312,13 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 					}
 				}
 				testHelperDeclaredClasses.AddAll(mJavaPkg.DeclaredClasses)
+				testHelperDeclaredClasses.AddAll(mJavaPkg.GeneratedClasses)
 			}
-			addAnnotationProcessorClassesAndExtraImports(
+			l.addAnnotationProcessorClassesAndExtraImports(
 				cfg,
 				mJavaPkg,
 				annotationProcessorClasses,
+				annotationClasses,
 				productionJavaImports,
 				productionJavaImportedClasses,
 				testJavaImports,
@@ -274,13 +326,20 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 			)
 		}
 	} else {
-		allPackageNames.Add(javaPkg.Name)
+		if !isModuleInfoOnly(javaPkg) {
+			allPackageNames.Add(javaPkg.Name)
+		}
+		if !javaPkg.TestPackage {
+			module = javaPkg.Module
+		}
 		if javaPkg.TestPackage {
 			// Tests don't get to export things, as things shouldn't depend on them.
 			addNonLocalImportsAndExports(testJavaImports, testJavaImportedClasses, nil, nil, javaPkg.ImportedClasses, javaPkg.ImportedPackagesWithoutSpecificClasses, javaPkg.ExportedClasses, javaPkg.Name, likelyLocalClassNames)
 		} else {
+			productionPackages = append(productionPackages, javaPkg)
 			addNonLocalImportsAndExports(productionJavaImports, productionJavaImportedClasses, nonLocalJavaExports, nonLocalJavaExternalExportedClasses, javaPkg.ImportedClasses, javaPkg.ImportedPackagesWithoutSpecificClasses, javaPkg.ExportedClasses, javaPkg.Name, likelyLocalClassNames)
 			nonLocalJavaExportedClasses.AddAll(javaPkg.DeclaredClasses)
+			nonLocalJavaExportedClasses.AddAll(javaPkg.GeneratedClasses)
 		}
 		allMains.AddAll(javaPkg.Mains)
 		for _, f := range srcFilenamesRelativeToPackage {
@@ -296,17 +355,22 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 				}
 			} else {
 				productionJavaFiles.Add(path)
+				if f == java.ModuleInfoFile {
+					continue
+				}
 				jf := javaFile{pathRelativeToBazelWorkspaceRoot: path, pkg: javaPkg.Name}
 				nonLocalJavaExportedClasses.Add(*jf.ClassName())
 			}
 		}
 		if javaPkg.TestPackage {
 			testHelperDeclaredClasses.AddAll(javaPkg.DeclaredClasses)
+			testHelperDeclaredClasses.AddAll(javaPkg.GeneratedClasses)
 		}
-		addAnnotationProcessorClassesAndExtraImports(
+		l.addAnnotationProcessorClassesAndExtraImports(
 			cfg,
 			javaPkg,
 			annotationProcessorClasses,
+			annotationClasses,
 			productionJavaImports,
 			productionJavaImportedClasses,
 			testJavaImports,
@@ -332,6 +396,9 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 		javaLibraryKind = "kt_jvm_library"
 	}
 
+	// In file granularity, the library of each top-level class of the package.
+	var fileLibraries map[string]string
+
 	// Check if this is a resources root directory and generate a pkg_files target
 	if isResourcesRoot && len(srcFilenamesRelativeToPackage) == 0 {
 		// Collect resource files recursively from this directory and all subdirectories
@@ -385,9 +452,9 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 
 			// In package mode, also generate a java_library wrapper for the resources
 			if !aggregateAtRoot {
-				resourceLib := rule.NewRule(javaLibraryKind, "resources_lib")
+				resourceLib := rule.NewRule(javaLibraryKind, sharedResourcesLibraryName)
 				resourceLib.SetAttr("resources", []string{":resources"})
-				resourceLib.SetAttr("visibility", []string{"//:__subpackages__"})
+				resourceLib.SetAttr("visibility", libraryVisibility(cfg))
 				res.Gen = append(res.Gen, resourceLib)
 				res.Imports = append(res.Imports, types.ResolveInput{})
 			}
@@ -413,31 +480,44 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 					if aggregateAtRoot {
 						// Module mode: reference pkg_files directly as resources
 						resourcesDirectRef = "//" + resourcesPath + ":resources"
+						if (granularity == "scc" || granularity == "auto") && !aggregatesResources(cfgs, resourcesPath) {
+							// The resources root wraps them in its own resources_lib, which the
+							// groups share.
+							resourcesRuntimeDep = "//" + resourcesPath + ":" + sharedResourcesLibraryName
+						}
 					} else {
 						// Package mode: reference resources_lib as runtime_deps
-						resourcesRuntimeDep = "//" + resourcesPath + ":resources_lib"
+						resourcesRuntimeDep = "//" + resourcesPath + ":" + sharedResourcesLibraryName
 					}
 				}
 			}
 		}
 
-		if granularity == "scc" {
-			// SCC mode: the subtree is one Kotlin compilation unit, but `internal` is
+		if granularity == "file" {
+			// File mode: one library per source file, collapsing only files importing each
+			// other cyclically, so that dependents only recompile for the files they use.
+			fileLibraries = l.emitFileProductionLibraries(args, cfg, javaPkg, srcFilenamesRelativeToPackage, resourcesRuntimeDep, &res, log)
+			l.generateAnnotationProcessorPlugins(args.Rel, cfg, annotationProcessors(l.serviceRegistrations(cfg)[processorService], productionPackages), func(processor types.ClassName) string {
+				return fileLibraries[processor.BareOuterClassName()]
+			}, &res)
+		} else if granularity == "scc" || granularity == "auto" {
+			// SCC and auto modes: the subtree is one Kotlin compilation unit, but `internal` is
 			// module-scoped and Bazel forbids target cycles, so we can't always emit one
 			// library per package. Collapse only the packages that must share a module
 			// (import cycles + internal coupling) into the minimal set of targets;
 			// everything else stays its own per-package library.
-			l.emitModuleProductionLibraries(args, cfg, likelyLocalClassNames, resourcesDirectRef, resourcesRuntimeDep, &res, log)
+			l.emitModuleProductionLibraries(args, cfg, javaLibraryKind, likelyLocalClassNames, resourcesDirectRef, resourcesRuntimeDep, &res, log)
 		} else {
 			// "module" (one coarse library for the whole subtree) and "package" (this
 			// single package) both emit exactly one library here.
+			libraryName := cfg.MapLibraryName(filepath.Base(args.Rel))
 			l.generateJavaLibrary(generateJavaLibraryArgs{
 				File:                    args.File,
 				Rel:                     args.Rel,
 				LibraryKind:             javaLibraryKind,
 				Result:                  &res,
 				Config:                  cfg,
-				Name:                    cfg.MapLibraryName(filepath.Base(args.Rel)),
+				Name:                    libraryName,
 				Srcs:                    productionJavaFiles.SortedSlice(),
 				ResourcesDirectRef:      resourcesDirectRef,
 				ResourcesRuntimeDep:     resourcesRuntimeDep,
@@ -448,13 +528,18 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 				ExportedClasses:         nonLocalJavaExportedClasses,
 				ExternalExportedClasses: nonLocalJavaExternalExportedClasses,
 				AnnotationProcessors:    annotationProcessorClasses,
+				Annotations:             annotationClasses,
 				TestOnly:                cfg.TestOnly(),
+				Module:                  module,
 			})
+			l.generateAnnotationProcessorPlugins(args.Rel, cfg, annotationProcessors(l.serviceRegistrations(cfg)[processorService], productionPackages), func(types.ClassName) string {
+				return libraryName
+			}, &res)
 		}
 	}
 
 	if cfg.GenerateBinary() {
-		l.processJavaBinary(args.File, args.Rel, allMains, testHelperJavaFiles, &res, cfg)
+		l.processJavaBinary(args.File, args.Rel, allMains, testHelperJavaFiles, fileLibraries, &res, cfg)
 	}
 
 	// We add special packages to point to testonly libraries which - this accumulates them,
@@ -492,6 +577,7 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 				Exports:              nonLocalJavaExports,
 				ExportedClasses:      testHelperDeclaredClasses,
 				AnnotationProcessors: annotationProcessorClasses,
+				Annotations:          annotationClasses,
 				TestOnly:             true,
 			})
 		}
@@ -505,7 +591,7 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 		case "file":
 			for _, tf := range testJavaFiles.SortedSlice() {
 				separateJavaTestReasons := separateTestJavaFiles[tf]
-				l.generateJavaTest(args.File, args.Rel, cfg.MavenRepositoryName(), tf, aggregateAtRoot, testJavaImportsWithHelpers, testJavaImportedClassesWithHelpers, annotationProcessorClasses, nil, separateJavaTestReasons.wrapper, separateJavaTestReasons.attributes, &res)
+				l.generateJavaTest(args.File, args.Rel, cfg.MavenRepositoryName(), tf, aggregateAtRoot, testJavaImportsWithHelpers, testJavaImportedClassesWithHelpers, annotationProcessorClasses, annotationClasses, nil, separateJavaTestReasons.wrapper, separateJavaTestReasons.attributes, &res)
 			}
 
 		case "suite":
@@ -533,8 +619,10 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 					testJavaImportsWithHelpers,
 					testJavaImportedClassesWithHelpers,
 					annotationProcessorClasses,
+					annotationClasses,
 					cfg.GetCustomJavaTestFileSuffixes(),
 					testHelperJavaFiles.Len() > 0,
+					cfg.DefaultVisibility(),
 					&res,
 				)
 				// Cache the helper classes under the suite's "<suite>-test-lib" label (the helper
@@ -565,7 +653,7 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 					testHelperDep = ptr(testHelperLibname(suiteName))
 				}
 				separateJavaTestReasons := separateTestJavaFiles[src]
-				l.generateJavaTest(args.File, args.Rel, cfg.MavenRepositoryName(), src, aggregateAtRoot, testJavaImportsWithHelpers, testJavaImportedClassesWithHelpers, annotationProcessorClasses, testHelperDep, separateJavaTestReasons.wrapper, separateJavaTestReasons.attributes, &res)
+				l.generateJavaTest(args.File, args.Rel, cfg.MavenRepositoryName(), src, aggregateAtRoot, testJavaImportsWithHelpers, testJavaImportedClassesWithHelpers, annotationProcessorClasses, annotationClasses, testHelperDep, separateJavaTestReasons.wrapper, separateJavaTestReasons.attributes, &res)
 			}
 		}
 	}
@@ -589,7 +677,7 @@ func (l javaLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 // satisfied by another group are left in place for the resolver to turn into `deps`
 // pointing at that group's label, which works because each group registers the packages
 // it owns.
-func (l javaLang) emitModuleProductionLibraries(args language.GenerateArgs, cfg *javaconfig.Config, likelyLocalClassNames *sorted_set.SortedSet[string], resourcesDirectRef, resourcesRuntimeDep string, res *language.GenerateResult, log zerolog.Logger) {
+func (l javaLang) emitModuleProductionLibraries(args language.GenerateArgs, cfg *javaconfig.Config, javaLibraryKind string, likelyLocalClassNames *sorted_set.SortedSet[string], resourcesDirectRef, resourcesRuntimeDep string, res *language.GenerateResult, log zerolog.Logger) {
 	productionPackagesByDir := make(map[string]*java.Package)
 	for mRel, mJavaPkg := range l.javaPackageCache {
 		if !strings.HasPrefix(mRel, args.Rel) || mJavaPkg.TestPackage {
@@ -605,6 +693,25 @@ func (l javaLang) emitModuleProductionLibraries(args language.GenerateArgs, cfg
 	if err != nil {
 		log.Fatal().Err(err).Msg("could not compute module collapse")
 	}
+	if cfg.ModuleGranularity() == "auto" {
+		logAutoGranularity(graph, log)
+	}
+
+	// Module resources go in a single library shared by the groups, as a runtime dependency,
+	// so that they end up in exactly one jar: resourcesRuntimeDep, the resources_lib of the
+	// resources root if it has one, or else one generated here. A lone group holds them itself.
+	var sharedResources *rule.Rule
+	if resourcesDirectRef != "" && len(graph.Groups()) > 1 {
+		if resourcesRuntimeDep == "" {
+			sharedResources = rule.NewRule(javaLibraryKind, sharedResourcesLibraryName)
+			sharedResources.SetAttr("resources", []string{resourcesDirectRef})
+			sharedResources.SetAttr("visibility", libraryVisibility(cfg))
+			resourcesRuntimeDep = ":" + sharedResourcesLibraryName
+		}
+		resourcesDirectRef = ""
+	} else if resourcesDirectRef != "" {
+		resourcesRuntimeDep = ""
+	}
 
 	for _, group := range graph.Groups() {
 		groupFiles := sorted_set.NewSortedSet([]string{})
@@ -617,12 +724,15 @@ func (l javaLang) emitModuleProductionLibraries(args language.GenerateArgs, cfg
 		// which keeps the associate lookup's single-provider invariant intact.
 		ownClasses := sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess)
 		annotationProcessorClasses := sorted_set.NewSortedSetFn(nil, types.ClassNameLess)
+		annotationClasses := sorted_set.NewSortedSetFn(nil, types.ClassNameLess)
 
 		// The module root has no sources of its own, so the rule kind is determined by
 		// the group's files rather than args.RegularFiles.
 		groupLibraryKind := "java_library"
+		var groupPackages []*java.Package
 		for _, dir := range group.Dirs {
 			pkg := productionPackagesByDir[dir]
+			groupPackages = append(groupPackages, pkg)
 			addNonLocalImportsAndExports(imports, importedClasses, exports, externalExportedClasses, pkg.ImportedClasses, pkg.ImportedPackagesWithoutSpecificClasses, pkg.ExportedClasses, pkg.Name, likelyLocalClassNames)
 			for _, f := range pkg.Files.SortedSlice() {
 				if strings.HasSuffix(f, ".kt") {
@@ -633,8 +743,10 @@ func (l javaLang) emitModuleProductionLibraries(args language.GenerateArgs, cfg
 				jf := javaFile{pathRelativeToBazelWorkspaceRoot: path, pkg: pkg.Name}
 				ownClasses.Add(*jf.ClassName())
 			}
+			ownClasses.AddAll(pkg.GeneratedClasses)
 			for _, annotationClass := range pkg.AllAnnotations().SortedSlice() {
-				annotationProcessorClasses.AddAll(cfg.GetAnnotationProcessorPluginClasses(annotationClass))
+				annotationClasses.Add(annotationClass)
+				annotationProcessorClasses.AddAll(l.annotationProcessorPluginClasses(cfg, annotationClass))
 			}
 		}
 
@@ -652,9 +764,6 @@ func (l javaLang) emitModuleProductionLibraries(args language.GenerateArgs, cfg
 			return !group.Packages.Contains(c.PackageName()) || !ownClasses.Contains(c)
 		})
 
-		// NOTE: module resources are attached to every group, bundling them into each
-		// group's jar. Revisit (e.g. a shared resources target) if duplicate resources
-		// cause runtime problems.
 		l.generateJavaLibrary(generateJavaLibraryArgs{
 			File:                    args.File,
 			Rel:                     args.Rel,
@@ -672,8 +781,36 @@ func (l javaLang) emitModuleProductionLibraries(args language.GenerateArgs, cfg
 			ExportedClasses:         ownClasses,
 			ExternalExportedClasses: externalExportedClasses,
 			AnnotationProcessors:    annotationProcessorClasses,
+			Annotations:             annotationClasses,
 			TestOnly:                cfg.TestOnly(),
 		})
+		l.generateAnnotationProcessorPlugins(args.Rel, cfg, annotationProcessors(l.serviceRegistrations(cfg)[processorService], groupPackages), func(types.ClassName) string {
+			return group.Name
+		}, res)
+	}
+
+	if sharedResources != nil {
+		res.Gen = append(res.Gen, sharedResources)
+		res.Imports = append(res.Imports, types.ResolveInput{})
+	}
+}
+
+// aggregatesResources returns whether the resources root at resourcesPath is aggregated into
+// a module, in which case it doesn't wrap its resources in a resources_lib. It may not be
+// configured yet, as it can be walked after the Java sources, so the nearest configured
+// directory is used.
+func aggregatesResources(cfgs javaconfig.Configs, resourcesPath string) bool {
+	for rel := resourcesPath; ; rel = path.Dir(rel) {
+		if rel == "." {
+			rel = ""
+		}
+		if cfg, ok := cfgs[rel]; ok {
+			granularity := cfg.ModuleGranularity()
+			return granularity == "module" || granularity == "scc" || granularity == "auto"
+		}
+		if rel == "" {
+			return false
+		}
 	}
 }
 
@@ -749,7 +886,7 @@ func generateProtoLibraries(l *javaLang, args language.GenerateArgs, log zerolog
 		}
 
 		rjl := rule.NewRule("java_library", jlName)
-		rjl.SetAttr("visibility", []string{"//:__subpackages__"})
+		rjl.SetAttr("visibility", libraryVisibility(cfg))
 		var exports []string
 		if generateServices {
 			exports = append(exports, ":"+jglName)
@@ -915,6 +1052,12 @@ func accumulateJavaFile(cfg *javaconfig.Config, testJavaFiles, testHelperJavaFil
 	}
 }
 
+// isModuleInfoOnly returns whether the only source of pkg is a module-info.java, whose
+// package is then the unnamed one rather than a package of the library.
+func isModuleInfoOnly(pkg *java.Package) bool {
+	return pkg.Module != nil && pkg.Files != nil && pkg.Files.Len() == 1 && pkg.Files.Contains(java.ModuleInfoFile)
+}
+
 // generateJavaLibraryArgs describes a single java_library target to generate. It groups
 // the many per-library attributes that would otherwise be positional arguments,
 // several of which share a type and so are easy to transpose by mistake.
@@ -942,7 +1085,11 @@ type generateJavaLibraryArgs struct {
 	// classes this rule provides.
 	ExternalExportedClasses *sorted_set.SortedSet[types.ClassName]
 	AnnotationProcessors    *sorted_set.SortedSet[types.ClassName]
-	TestOnly                bool
+	// Annotations are the annotation types used by Srcs.
+	Annotations *sorted_set.SortedSet[types.ClassName]
+	TestOnly    bool
+	// Module is the JPMS module declared by a module-info.java among Srcs, if any.
+	Module *java.Module
 }
 
 func (l javaLang) generateJavaLibrary(args generateJavaLibraryArgs) {
@@ -989,13 +1136,19 @@ func (l javaLang) generateJavaLibrary(args generateJavaLibraryArgs) {
 	}
 	// Visibility is independent of testonly: a testonly library still needs to be visible to its
 	// (testonly) consumers in other packages -- e.g. a testFixtures source set depended on by tests.
-	r.SetAttr("visibility", []string{"//:__subpackages__"})
+	r.SetAttr("visibility", libraryVisibility(args.Config))
 
 	resolvablePackages := make([]types.ResolvableJavaPackage, 0, args.Packages.Len())
 	for _, pkg := range args.Packages.SortedSlice() {
 		resolvablePackages = append(resolvablePackages, *types.NewResolvableJavaPackage(pkg, args.TestOnly, false))
 	}
 	r.SetPrivateAttr(packagesKey, resolvablePackages)
+	if args.Module != nil {
+		r.SetPrivateAttr(moduleKey, args.Module)
+	}
+	if services := providedServices(l.serviceRegistrations(args.Config), args.ExportedClasses); len(services) > 0 {
+		r.SetPrivateAttr(servicesKey, services)
+	}
 	if args.ExportedClasses != nil {
 		classes := args.ExportedClasses.SortedSlice()
 		r.SetPrivateAttr(classesKey, classes)
@@ -1030,6 +1183,12 @@ func (l javaLang) generateJavaLibrary(args generateJavaLibraryArgs) {
 		ExportedPackageNames: args.Exports,
 		ExportedClassNames:   exportedClassNames,
 		AnnotationProcessors: args.AnnotationProcessors,
+		Annotations:          args.Annotations,
+	}
+	if args.Module != nil {
+		resolveInput.RequiredModules = args.Module.Requires
+		resolveInput.TransitiveModules = args.Module.RequiresTransitive
+		resolveInput.UsedServices = args.Module.Uses
 	}
 	args.Result.Imports = append(args.Result.Imports, resolveInput)
 
@@ -1038,7 +1197,9 @@ func (l javaLang) generateJavaLibrary(args generateJavaLibraryArgs) {
 	}
 }
 
-func (l javaLang) processJavaBinary(file *rule.File, rel string, allMains *sorted_set.SortedSet[types.ClassName], testHelperJavaFiles *sorted_set.SortedSet[javaFile], res *language.GenerateResult, cfg *javaconfig.Config) {
+// processJavaBinary generates a java_binary for each main class. fileLibraries maps top-level
+// classes to their library in file granularity, and is nil otherwise.
+func (l javaLang) processJavaBinary(file *rule.File, rel string, allMains *sorted_set.SortedSet[types.ClassName], testHelperJavaFiles *sorted_set.SortedSet[javaFile], fileLibraries map[string]string, res *language.GenerateResult, cfg *javaconfig.Config) {
 	var testHelperJavaClasses *sorted_set.SortedSet[types.ClassName]
 	for _, m := range allMains.SortedSlice() {
 		// Lazily populate because java_binaries are pretty rare
@@ -1050,15 +1211,18 @@ func (l javaLang) processJavaBinary(file *rule.File, rel string, allMains *sorte
 		}
 		isTestOnly := false
 		libName := cfg.MapLibraryName(filepath.Base(rel))
+		if fileLibrary, ok := fileLibraries[m.BareOuterClassName()]; ok {
+			libName = fileLibrary
+		}
 		if testHelperJavaClasses.Contains(m) {
 			isTestOnly = true
 			libName = testHelperLibname(libName)
 		}
-		l.generateJavaBinary(file, m, libName, isTestOnly, res)
+		l.generateJavaBinary(file, m, libName, isTestOnly, binaryVisibility(cfg), res)
 	}
 }
 
-func (l javaLang) generateJavaBinary(file *rule.File, m types.ClassName, libName string, testonly bool, res *language.GenerateResult) {
+func (l javaLang) generateJavaBinary(file *rule.File, m types.ClassName, libName string, testonly bool, visibility []string, res *language.GenerateResult) {
 	const ruleKind = "java_binary"
 	name := m.BareOuterClassName()
 	r := rule.NewRule("java_binary", name) // FIXME check collision on name
@@ -1071,14 +1235,14 @@ func (l javaLang) generateJavaBinary(file *rule.File, m types.ClassName, libName
 	runtimeDeps := l.collectRuntimeDeps(ruleKind, name, file)
 	runtimeDeps.Add(label.Label{Name: libName, Relative: true})
 	r.SetAttr("runtime_deps", labelsToStrings(runtimeDeps.SortedSlice()))
-	r.SetAttr("visibility", []string{"//visibility:public"})
+	r.SetAttr("visibility", visibility)
 	res.Gen = append(res.Gen, r)
 	res.Imports = append(res.Imports, types.ResolveInput{
 		PackageNames: sorted_set.NewSortedSetFn([]types.PackageName{m.PackageName()}, types.PackageNameLess),
 	})
 }
 
-func (l javaLang) generateJavaTest(file *rule.File, pathToPackageRelativeToBazelWorkspace string, mavenRepositoryName string, f javaFile, includePackageInName bool, imports *sorted_set.SortedSet[types.PackageName], importedClasses *sorted_set.SortedSet[types.ClassName], annotationProcessorClasses *sorted_set.SortedSet[types.ClassName], depOnTestHelpers *string, wrapper string, extraAttributes map[string]bzl.Expr, res *language.GenerateResult) {
+func (l javaLang) generateJavaTest(file *rule.File, pathToPackageRelativeToBazelWorkspace string, mavenRepositoryName string, f javaFile, includePackageInName bool, imports *sorted_set.SortedSet[types.PackageName], importedClasses *sorted_set.SortedSet[types.ClassName], annotationProcessorClasses *sorted_set.SortedSet[types.ClassName], annotations *sorted_set.SortedSet[types.ClassName], depOnTestHelpers *string, wrapper string, extraAttributes map[string]bzl.Expr, res *language.GenerateResult) {
 	className := f.ClassName()
 	fullyQualifiedTestClass := className.FullyQualifiedClassName()
 	var testName string
@@ -1140,6 +1304,7 @@ func (l javaLang) generateJavaTest(file *rule.File, pathToPackageRelativeToBazel
 		ImportedPackageNames: testImports,
 		ImportedClasses:      importedClasses,
 		AnnotationProcessors: annotationProcessorClasses,
+		Annotations:          annotations,
 	}
 	res.Imports = append(res.Imports, resolveInput)
 }
@@ -1167,7 +1332,7 @@ var junit5RuntimeDeps = []string{
 	"org.junit.platform:junit-platform-reporting",
 }
 
-func (l javaLang) generateJavaTestSuite(file *rule.File, name string, srcs []string, packageNames *sorted_set.SortedSet[types.PackageName], mavenRepositoryName string, imports *sorted_set.SortedSet[types.PackageName], importedClasses *sorted_set.SortedSet[types.ClassName], annotationProcessorClasses *sorted_set.SortedSet[types.ClassName], customTestSuffixes *[]string, hasHelpers bool, res *language.GenerateResult) {
+func (l javaLang) generateJavaTestSuite(file *rule.File, name string, srcs []string, packageNames *sorted_set.SortedSet[types.PackageName], mavenRepositoryName string, imports *sorted_set.SortedSet[types.PackageName], importedClasses *sorted_set.SortedSet[types.ClassName], annotationProcessorClasses *sorted_set.SortedSet[types.ClassName], annotations *sorted_set.SortedSet[types.ClassName], customTestSuffixes *[]string, hasHelpers bool, visibility []string, res *language.GenerateResult) {
 	const ruleKind = "java_test_suite"
 	r := rule.NewRule(ruleKind, name)
 	r.SetAttr("srcs", srcs)
@@ -1202,6 +1367,11 @@ func (l javaLang) generateJavaTestSuite(file *rule.File, name string, srcs []str
 		r.SetAttr("test_suffixes", *customTestSuffixes)
 	}
 
+	// The suite passes its visibility on to the "<suite>-test-lib" helper library.
+	if hasHelpers && visibility != nil {
+		r.SetAttr("visibility", visibility)
+	}
+
 	res.Gen = append(res.Gen, r)
 	suiteImports := imports.Clone()
 	suiteImports.AddAll(packageNames)
@@ -1210,6 +1380,7 @@ func (l javaLang) generateJavaTestSuite(file *rule.File, name string, srcs []str
 		ImportedPackageNames: suiteImports,
 		ImportedClasses:      importedClasses,
 		AnnotationProcessors: annotationProcessorClasses,
+		Annotations:          annotations,
 	}
 	res.Imports = append(res.Imports, resolveInput)
 }
@@ -1270,6 +1441,22 @@ func labelsToStrings(labels []label.Label) []string {
 	return out
 }
 
+// libraryVisibility returns the visibility of the libraries generated in a package.
+func libraryVisibility(cfg *javaconfig.Config) []string {
+	if visibility := cfg.DefaultVisibility(); visibility != nil {
+		return visibility
+	}
+	return []string{"//:__subpackages__"}
+}
+
+// binaryVisibility returns the visibility of the binaries generated in a package.
+func binaryVisibility(cfg *javaconfig.Config) []string {
+	if visibility := cfg.DefaultVisibility(); visibility != nil {
+		return visibility
+	}
+	return []string{"//visibility:public"}
+}
+
 func testHelperLibname(targetName string) string {
 	return targetName + "-test-lib"
 }
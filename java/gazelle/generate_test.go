@@ -3,6 +3,7 @@ package gazelle
 import (
 	"testing"
 
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
 	"github.com/bazelbuild/bazel-gazelle/language"
@@ -160,7 +161,7 @@ func TestSingleJavaTestFile(t *testing.T) {
 			var res language.GenerateResult
 
 			l := newTestJavaLang(t)
-			l.generateJavaTest(nil, "", "maven", f, tc.includePackageInName, stringsToPackageNames(tc.importedPackages), nil, nil, nil, tc.wrapper, nil, &res)
+			l.generateJavaTest(nil, "", "maven", f, tc.includePackageInName, stringsToPackageNames(tc.importedPackages), nil, nil, nil, nil, tc.wrapper, nil, &res)
 
 			require.Len(t, res.Gen, 1, "want 1 generated rule")
 
@@ -253,7 +254,7 @@ func TestSuite(t *testing.T) {
 			var res language.GenerateResult
 
 			l := newTestJavaLang(t)
-			l.generateJavaTestSuite(nil, "blah", []string{src}, stringsToPackageNames([]string{pkg}), "maven", stringsToPackageNames(tc.importedPackages), nil, nil, nil, false, &res)
+			l.generateJavaTestSuite(nil, "blah", []string{src}, stringsToPackageNames([]string{pkg}), "maven", stringsToPackageNames(tc.importedPackages), nil, nil, nil, nil, false, nil, &res)
 
 			require.Len(t, res.Gen, 1, "want 1 generated rule")
 
@@ -324,6 +325,45 @@ func TestAddNonLocalImports(t *testing.T) {
 	}
 }
 
+type annotationProcessorMavenResolver struct {
+	testResolver
+	processors map[string][]types.ClassName
+}
+
+func (r *annotationProcessorMavenResolver) AnnotationProcessors(annotationClass types.ClassName) []types.ClassName {
+	return r.processors[annotationClass.PackageName().Name]
+}
+
+func TestAnnotationProcessorPluginClasses(t *testing.T) {
+	mustParse := func(s string) types.ClassName {
+		name, err := types.ParseClassName(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *name
+	}
+	annotation := mustParse("com.google.auto.value.AutoValue")
+	discovered := mustParse("com.google.auto.value.processor.AutoValueProcessor")
+	configured := mustParse("com.example.CustomAutoValueProcessor")
+
+	l := newTestJavaLang(t)
+	l.mavenResolver = &annotationProcessorMavenResolver{
+		processors: map[string][]types.ClassName{
+			"com.google.auto.value": {discovered},
+		},
+	}
+
+	cfg := javaconfig.New("/tmp/repo")
+	cfg.AddAnnotationProcessorPlugin(annotation, configured)
+
+	got := l.annotationProcessorPluginClasses(cfg, annotation).SortedSlice()
+	require.Equal(t, []types.ClassName{configured, discovered}, got)
+
+	cfg.SetAnnotationProcessorDiscovery(false)
+	got = l.annotationProcessorPluginClasses(cfg, annotation).SortedSlice()
+	require.Equal(t, []types.ClassName{configured}, got)
+}
+
 func newTestJavaLang(t *testing.T) javaLang {
 	t.Helper()
 	return javaLang{
@@ -395,3 +435,38 @@ func TestProtoOuterClassName(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultVisibility(t *testing.T) {
+	parent := javaconfig.New("/tmp")
+	require.Equal(t, []string{"//:__subpackages__"}, libraryVisibility(parent))
+	require.Equal(t, []string{"//visibility:public"}, binaryVisibility(parent))
+
+	child := parent.NewChild()
+	child.SetDefaultVisibility([]string{"//src:__subpackages__", "//tools:__pkg__"})
+	require.Equal(t, []string{"//src:__subpackages__", "//tools:__pkg__"}, libraryVisibility(child))
+	require.Equal(t, []string{"//src:__subpackages__", "//tools:__pkg__"}, binaryVisibility(child))
+	require.Equal(t, child.DefaultVisibility(), child.NewChild().DefaultVisibility(), "sub-packages inherit it")
+	require.Nil(t, parent.DefaultVisibility())
+
+	var res language.GenerateResult
+	l := newTestJavaLang(t)
+	l.generateJavaTestSuite(nil, "helpers", []string{"FooTest.java"}, stringsToPackageNames([]string{"com.example"}), "maven", stringsToPackageNames(nil), nil, nil, nil, nil, true, child.DefaultVisibility(), &res)
+	l.generateJavaTestSuite(nil, "no-helpers", []string{"BarTest.java"}, stringsToPackageNames([]string{"com.example"}), "maven", stringsToPackageNames(nil), nil, nil, nil, nil, false, child.DefaultVisibility(), &res)
+	require.Equal(t, []string{"//src:__subpackages__", "//tools:__pkg__"}, res.Gen[0].AttrStrings("visibility"))
+	require.Nil(t, res.Gen[1].Attr("visibility"), "without helpers the suite's visibility is unused")
+
+	child.SetDefaultVisibility(nil)
+	require.Equal(t, []string{"//:__subpackages__"}, libraryVisibility(child))
+}
+
+func TestAggregatesResources(t *testing.T) {
+	root := javaconfig.New("/tmp")
+	scc := root.NewChild()
+	require.NoError(t, scc.SetModuleGranularity("scc"))
+	cfgs := javaconfig.Configs{"": root, "src/main/java/com/example": scc}
+	require.False(t, aggregatesResources(cfgs, "src/main/resources"))
+	require.True(t, aggregatesResources(cfgs, "src/main/java/com/example/resources"))
+
+	cfgs["src/main"] = scc
+	require.True(t, aggregatesResources(cfgs, "src/main/resources"))
+}
@@ -0,0 +1,78 @@
+package gazelle
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+)
+
+var generatedClassTemplatePlaceholder = regexp.MustCompile(`\{[^}]*\}`)
+
+// validateGeneratedClassTemplate returns an error unless template names a class with {Name}
+// and, optionally, {Outer}, see javaconfig.JavaAnnotationProcessorGeneratedClass.
+func validateGeneratedClassTemplate(template string) error {
+	if !strings.Contains(template, "{Name}") {
+		return fmt.Errorf("template %q doesn't contain {Name}", template)
+	}
+	for _, placeholder := range generatedClassTemplatePlaceholder.FindAllString(template, -1) {
+		if placeholder != "{Name}" && placeholder != "{Outer}" {
+			return fmt.Errorf("template %q contains unknown placeholder %s, expected {Name} or {Outer}", template, placeholder)
+		}
+	}
+	for _, r := range generatedClassTemplatePlaceholder.ReplaceAllString(template, "") {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '$' {
+			return fmt.Errorf("template %q isn't a simple class name", template)
+		}
+	}
+	return nil
+}
+
+// expandGeneratedClassTemplate returns the simple name of the class generated for class
+// following template. As the classes generated for top-level classes have no enclosing
+// names, an empty {Outer} drops a "_" next to it: AutoValue_{Outer}_{Name} is AutoValue_Foo
+// for com.example.Foo, and AutoValue_Foo_Bar for com.example.Foo.Bar.
+func expandGeneratedClassTemplate(template string, class types.ClassName) string {
+	pkg := class.PackageName()
+	nested := class.FullyQualifiedClassName()
+	if pkg.Name != "" {
+		nested = strings.TrimPrefix(nested, pkg.Name+".")
+	}
+	names := strings.Split(nested, ".")
+	outer := strings.Join(names[:len(names)-1], "_")
+	if outer == "" {
+		template = strings.NewReplacer("{Outer}_", "", "_{Outer}", "").Replace(template)
+	}
+	return strings.NewReplacer("{Outer}", outer, "{Name}", names[len(names)-1]).Replace(template)
+}
+
+// generatedClasses returns the classes which annotation processors generate for the classes
+// of pkg, following the java_annotation_processor_generated_class directives. They are
+// top-level classes of pkg, provided by the library of the annotated classes.
+func generatedClasses(cfg *javaconfig.Config, pkg *java.Package) *sorted_set.SortedSet[types.ClassName] {
+	generated := sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess)
+	for annotated, metadata := range pkg.PerClassMetadata {
+		if metadata.AnnotationClassNames == nil {
+			continue
+		}
+		class, err := types.ParseClassName(annotated)
+		if err != nil {
+			continue
+		}
+		for _, annotation := range metadata.AnnotationClassNames.SortedSlice() {
+			templates := cfg.GetAnnotationProcessorGeneratedClasses(annotation)
+			if templates == nil {
+				continue
+			}
+			for _, template := range templates.SortedSlice() {
+				generated.Add(types.NewClassName(pkg.Name, expandGeneratedClassTemplate(template, *class)))
+			}
+		}
+	}
+	return generated
+}
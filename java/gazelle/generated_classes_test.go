@@ -0,0 +1,72 @@
+package gazelle
+
+import (
+	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandGeneratedClassTemplate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		template string
+		class    string
+		want     string
+	}{
+		"top-level class":           {template: "AutoValue_{Outer}_{Name}", class: "com.example.Foo", want: "AutoValue_Foo"},
+		"nested class":              {template: "AutoValue_{Outer}_{Name}", class: "com.example.Foo.Bar", want: "AutoValue_Foo_Bar"},
+		"doubly nested class":       {template: "AutoValue_{Outer}_{Name}", class: "com.example.Foo.Bar.Baz", want: "AutoValue_Foo_Bar_Baz"},
+		"prefix":                    {template: "Dagger{Name}", class: "com.example.AppComponent", want: "DaggerAppComponent"},
+		"suffix":                    {template: "{Name}Impl", class: "com.example.FooMapper", want: "FooMapperImpl"},
+		"outer before the name":     {template: "{Outer}_{Name}Builder", class: "com.example.Foo", want: "FooBuilder"},
+		"nested without outer":      {template: "Immutable{Name}", class: "com.example.Foo.Bar", want: "ImmutableBar"},
+		"class of the root package": {template: "AutoValue_{Outer}_{Name}", class: "Foo", want: "AutoValue_Foo"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			class, err := types.ParseClassName(tc.class)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, expandGeneratedClassTemplate(tc.template, *class))
+		})
+	}
+}
+
+func TestValidateGeneratedClassTemplate(t *testing.T) {
+	require.NoError(t, validateGeneratedClassTemplate("AutoValue_{Outer}_{Name}"))
+	require.NoError(t, validateGeneratedClassTemplate("{Name}$Builder"))
+	for template, want := range map[string]string{
+		"AutoValue_{Outer}":  "doesn't contain {Name}",
+		"{Package}.{Name}":   "unknown placeholder {Package}",
+		"com.example.{Name}": "isn't a simple class name",
+	} {
+		err := validateGeneratedClassTemplate(template)
+		require.Error(t, err, template)
+		require.Contains(t, err.Error(), want)
+	}
+}
+
+func TestGeneratedClasses(t *testing.T) {
+	autoValue := types.NewClassName(types.NewPackageName("com.google.auto.value"), "AutoValue")
+	cfg := javaconfig.New("/tmp/repo")
+	cfg.AddAnnotationProcessorGeneratedClass(autoValue, "AutoValue_{Outer}_{Name}")
+
+	pkg := types.NewPackageName("com.example")
+	annotated := func(annotations ...types.ClassName) java.PerClassMetadata {
+		return java.PerClassMetadata{AnnotationClassNames: sorted_set.NewSortedSetFn(annotations, types.ClassNameLess)}
+	}
+	javaPkg := &java.Package{
+		Name: pkg,
+		PerClassMetadata: map[string]java.PerClassMetadata{
+			"com.example.Foo":       annotated(autoValue),
+			"com.example.Outer.Bar": annotated(autoValue),
+			"com.example.Plain":     annotated(types.NewClassName(types.NewPackageName("java.lang"), "Deprecated")),
+		},
+	}
+
+	require.Equal(t, []types.ClassName{
+		types.NewClassName(pkg, "AutoValue_Foo"),
+		types.NewClassName(pkg, "AutoValue_Outer_Bar"),
+	}, generatedClasses(cfg, javaPkg).SortedSlice())
+}
@@ -1,13 +1,16 @@
 package javaconfig
 
 import (
+	"errors"
 	"fmt"
 	"path"
 	"path/filepath"
 	"strings"
 
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/repository_index"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
 	bzl "github.com/bazelbuild/buildtools/build"
 )
 
@@ -16,6 +19,32 @@ const (
 	// Can be repeated.
 	JavaExcludeArtifact = "java_exclude_artifact"
 
+	// JavaDefaultVisibility sets the visibility of the libraries, binaries and test helper
+	// libraries generated in this directory and its sub-packages, as a comma-separated list of
+	// labels. An empty value restores the defaults, which are "//:__subpackages__" for
+	// libraries, "//visibility:public" for binaries, and unset for test helper libraries.
+	// Libraries exported by a java_export keep the narrower visibility derived from it.
+	// Example: # gazelle:java_default_visibility //src:__subpackages__,//tools:__pkg__
+	JavaDefaultVisibility = "java_default_visibility"
+
+	// JavaDependencyRule allows or denies dependencies, like architecture tests do, but when
+	// resolving so that a denied dependency is never generated. A rule is "allow" or "deny",
+	// followed by a pattern for the importing side and one for the imported side. A pattern is
+	// either a label pattern ("//src/domain/...", "@maven//..." or "//src/app:app"), matched
+	// against the importing rule or the dependency, or a package pattern, matched against the
+	// packages of the importing rule or the imported package. In package patterns, ".." matches
+	// any number of packages, as in ArchUnit, and "*" matches within a single package name.
+	// Can be repeated; the last matching rule wins, and sub-packages inherit them. Dependencies
+	// no rule matches are allowed.
+	// Example: # gazelle:java_dependency_rule deny ..domain.. ..infra..
+	JavaDependencyRule = "java_dependency_rule"
+
+	// JavaExtraVisibility adds a visibility label, e.g. "//tools/codegen:__pkg__", to the
+	// visibility inferred for generated libraries, for consumers the resolver does not see.
+	// Can be repeated. Inherited by sub-packages.
+	// Example: # gazelle:java_extra_visibility //tools/codegen:__pkg__
+	JavaExtraVisibility = "java_extra_visibility"
+
 	// JavaExtensionDirective represents the directive that controls whether
 	// this Java extension is enabled or not. Sub-packages inherit this value.
 	// Can be either "enabled" or "disabled". Defaults to "enabled".
@@ -23,7 +52,8 @@ const (
 
 	// JavaMavenInstallFile represents the directive that controls where the
 	// maven_install.json file is located.
-	// Defaults to "maven_install.json".
+	// Defaults to "maven_install.json", or to the lock_file of the maven.install
+	// declared in MODULE.bazel.
 	JavaMavenInstallFile = "java_maven_install_file"
 
 	// MavenIndexFile represents the directive that controls where the index
@@ -34,7 +64,9 @@ const (
 	// JavaModuleGranularityDirective represents the directive that controls whether
 	// this Java module has a module granularity (Gradle) or a package
 	// granularity (bazel).
-	// Can be either "package" or "module". Defaults to "package".
+	// Can be "package", "module", "scc", "auto", which builds like "scc" and logs the
+	// directories collapsed for import cycles, or "file", which builds a target per
+	// source file. Defaults to "package".
 	JavaModuleGranularityDirective = "java_module_granularity"
 
 	// JavaTestFileSuffixes indicates within a test directory which files are test classes vs utility classes,
@@ -57,7 +89,7 @@ const (
 	JavaGenerateProtoServices = "java_generate_proto_services"
 
 	// JavaMavenRepositoryName tells the code generator what the repository name that contains all maven dependencies is.
-	// Defaults to "maven"
+	// Defaults to "maven", or to the name of the maven.install declared in MODULE.bazel.
 	JavaMavenRepositoryName = "java_maven_repository_name"
 
 	// JavaAnnotationProcessorPlugin tells the code generator about specific java_plugin targets needed to process
@@ -69,6 +101,76 @@ const (
 	// generate code that imports classes not present in the original source.
 	JavaAnnotationProcessorExtraImports = "java_annotation_processor_extra_imports"
 
+	// JavaAnnotationProcessorGeneratedClass tells the code generator about the classes an annotation
+	// processor generates for the classes carrying an annotation, so that imports of them resolve to
+	// the library of the annotated class. The name of the generated class is a template where
+	// {Name} is the simple name of the annotated class and {Outer} the names of the classes
+	// enclosing it, joined with "_".
+	// Format: # gazelle:java_annotation_processor_generated_class com.google.auto.value.AutoValue AutoValue_{Outer}_{Name}
+	JavaAnnotationProcessorGeneratedClass = "java_annotation_processor_generated_class"
+
+	// JavaAnnotationProcessorDiscovery tells the code generator whether to add the annotation processors
+	// which the Maven index records as handling an annotation, in addition to those configured with
+	// java_annotation_processor_plugin. Discovered processors use the java_plugin targets that
+	// rules_jvm_external generates for them.
+	// Can be either "true" or "false". Defaults to "true".
+	JavaAnnotationProcessorDiscovery = "java_annotation_processor_discovery"
+
+	// JavaKotlinCompilerPlugin tells the resolver to add a kt_compiler_plugin to the plugins of the
+	// generated Kotlin libraries and tests whose sources use an annotation, such as the
+	// kotlinx.serialization plugin for @Serializable. The plugin runs on the Kotlin sources, which
+	// java_annotation_processor_plugin processors don't.
+	// Can be repeated.
+	// Format: # gazelle:java_kotlin_compiler_plugin kotlinx.serialization.Serializable //tools:serialization_plugin
+	JavaKotlinCompilerPlugin = "java_kotlin_compiler_plugin"
+
+	// JavaRuntimeDep adds a runtime dependency to every generated library, binary and test which
+	// imports a package, or one of its sub-packages. This covers dependencies which frameworks
+	// load reflectively, such as SLF4J bindings, JDBC drivers and JUnit engines.
+	// The dependency is either a versionless Maven coordinate, resolved through the Maven
+	// resolver, or a Bazel label.
+	// Can be repeated.
+	// Example: # gazelle:java_runtime_dep org.slf4j org.slf4j:slf4j-simple
+	JavaRuntimeDep = "java_runtime_dep"
+
+	// JavaServiceLoaderRuntimeDeps tells the resolver to add to binaries and tests the libraries
+	// of the repository registering, in a META-INF/services file or a module-info.java, a provider
+	// of a service which their sources, or those of the libraries they transitively import, load
+	// with ServiceLoader.load(Service.class).
+	// Can be either "true" or "false". Defaults to "false".
+	JavaServiceLoaderRuntimeDeps = "java_service_loader_runtime_deps"
+
+	// JavaPlatformPackage declares a package, and its sub-packages, as provided by the platform
+	// the code runs on (e.g. the Android SDK, or the APIs of a Jakarta EE application server)
+	// rather than by a dependency. Imports of it are not resolved, like the standard library.
+	// An optional label, typically of a neverlink target, is added as a dependency instead.
+	// Can be repeated. Inherited by sub-packages.
+	// Example: # gazelle:java_platform_package jakarta.servlet //third_party:servlet_api_neverlink
+	JavaPlatformPackage = "java_platform_package"
+
+	// JavaRepositoryIndex loads the index of the Java rules of another repository, written by
+	// its -java-repository-index flag, to resolve imports of the packages it provides to labels
+	// of that repository. It is consulted after the rules of this repository, and before Maven.
+	// The path is relative to the repository root. Can be repeated, the indexes being consulted
+	// in order. Inherited by sub-packages.
+	// Example: # gazelle:java_repository_index platform third_party/platform_java_index.json
+	JavaRepositoryIndex = "java_repository_index"
+
+	// JavaRelease sets the Java release the code is compiled against, e.g. "8", "11" or "21".
+	// Imports are treated as part of the standard library according to that release, so that
+	// packages the JDK no longer provides (such as javax.xml.bind from release 11) are resolved
+	// to dependencies, and imports of JDK-internal packages (such as sun.*) log a warning.
+	// Defaults to unset, which uses a fixed list of standard library packages.
+	JavaRelease = "java_release"
+
+	// JavaUnresolvedImportSeverity sets how imports of packages matching a glob are reported when
+	// no dependency provides them: "error" fails the run, "warn" only reports them, and "ignore"
+	// drops them silently. In the glob, `*` matches any sequence of characters, including dots.
+	// Can be repeated; the last matching directive wins, and sub-packages inherit them.
+	// Defaults to "error" for every package.
+	// Example: # gazelle:java_unresolved_import_severity com.legacy.* warn
+	JavaUnresolvedImportSeverity = "java_unresolved_import_severity"
+
 	// JavaResolveToJavaExports tells the code generator to favour resolving dependencies to java_exports where possible.
 	// If enabled, generated libraries will try to depend on java_exports targets that export a given package, instead of the underlying library.
 	// This allows monorepos to closely match a traditional Gradle/Maven model where subprojects are published in jars.
@@ -106,6 +208,12 @@ const (
 	// Can be either "true" or "false". Defaults to "false".
 	JavaTestOnly = "java_testonly"
 
+	// JavaInferVisibility tells the resolver to set the visibility of generated libraries to the
+	// smallest set of "__pkg__" and "__subpackages__" entries covering the packages which depend
+	// on them, instead of "//:__subpackages__". Entries marked "# keep" are preserved.
+	// Can be either "true" or "false". Defaults to "false". Inherited by sub-packages.
+	JavaInferVisibility = "java_infer_visibility"
+
 	// JavaLibraryNamingConvention controls the naming of java_library and kt_jvm_library targets.
 	// The value is a template string where {dirname} is replaced with the leaf directory name.
 	// Defaults to "" (unset), which preserves the current behavior of using the directory name.
@@ -118,6 +226,13 @@ const (
 	JavaTestSuiteNamingConvention = "java_test_suite_naming_convention"
 )
 
+// Severities of unresolved imports, see JavaUnresolvedImportSeverity.
+const (
+	SeverityError  = "error"
+	SeverityWarn   = "warn"
+	SeverityIgnore = "ignore"
+)
+
 // Configs is an extension of map[string]*Config. It provides finding methods
 // on top of the mapping.
 type Configs map[string]*Config
@@ -137,6 +252,22 @@ func (c *Config) NewChild() *Config {
 	for key, value := range c.annotationProcessorExtraImports {
 		annotationProcessorExtraImports[key] = value.Clone()
 	}
+	annotationProcessorGeneratedClasses := make(map[string]*sorted_set.SortedSet[string])
+	for key, value := range c.annotationProcessorGeneratedClasses {
+		annotationProcessorGeneratedClasses[key] = value.Clone()
+	}
+	kotlinCompilerPlugins := make(map[string]*sorted_set.SortedSet[label.Label])
+	for key, value := range c.kotlinCompilerPlugins {
+		kotlinCompilerPlugins[key] = value.Clone()
+	}
+	platformPackages := make(map[string]string)
+	for key, value := range c.platformPackages {
+		platformPackages[key] = value
+	}
+	runtimeDeps := make(map[string]*sorted_set.SortedSet[string])
+	for key, value := range c.runtimeDeps {
+		runtimeDeps[key] = value.Clone()
+	}
 	return &Config{
 		parent:                 c,
 		extensionEnabled:       c.extensionEnabled,
@@ -159,6 +290,19 @@ func (c *Config) NewChild() *Config {
 		mavenRepositoryName:    c.mavenRepositoryName,
 		annotationProcessorFullQualifiedClassToPluginClass: annotationProcessorFullQualifiedClassToPluginClass,
 		annotationProcessorExtraImports:                    annotationProcessorExtraImports,
+		annotationProcessorGeneratedClasses:                annotationProcessorGeneratedClasses,
+		annotationProcessorDiscovery:                       c.annotationProcessorDiscovery,
+		kotlinCompilerPlugins:                              kotlinCompilerPlugins,
+		runtimeDeps:                                        runtimeDeps,
+		serviceLoaderRuntimeDeps:                           c.serviceLoaderRuntimeDeps,
+		platformPackages:                                   platformPackages,
+		repositoryIndexes:                                  append([]*repository_index.Index(nil), c.repositoryIndexes...),
+		unresolvedImportSeverities:                         append([]unresolvedImportSeverity(nil), c.unresolvedImportSeverities...),
+		dependencyRules:                                    append([]DependencyRule(nil), c.dependencyRules...),
+		inferVisibility:                                    c.inferVisibility,
+		defaultVisibility:                                  c.defaultVisibility,
+		extraVisibility:                                    append([]string(nil), c.extraVisibility...),
+		release:                                            c.release,
 		libraryNamingConvention:                            c.libraryNamingConvention,
 		testSuiteNamingConvention:                          c.testSuiteNamingConvention,
 		testOnly:                                           c.testOnly,
@@ -199,6 +343,19 @@ type Config struct {
 	mavenRepositoryName                                string
 	annotationProcessorFullQualifiedClassToPluginClass map[string]*sorted_set.SortedSet[types.ClassName]
 	annotationProcessorExtraImports                    map[string]*sorted_set.SortedSet[types.ClassName]
+	annotationProcessorGeneratedClasses                map[string]*sorted_set.SortedSet[string]
+	annotationProcessorDiscovery                       bool
+	kotlinCompilerPlugins                              map[string]*sorted_set.SortedSet[label.Label]
+	runtimeDeps                                        map[string]*sorted_set.SortedSet[string]
+	serviceLoaderRuntimeDeps                           bool
+	platformPackages                                   map[string]string
+	repositoryIndexes                                  []*repository_index.Index
+	unresolvedImportSeverities                         []unresolvedImportSeverity
+	dependencyRules                                    []DependencyRule
+	inferVisibility                                    bool
+	defaultVisibility                                  []string
+	extraVisibility                                    []string
+	release                                            int
 	sourcesetRoot                                      string
 	stripResourcesPrefix                               string
 	libraryNamingConvention                            string
@@ -206,6 +363,27 @@ type Config struct {
 	testOnly                                           bool
 }
 
+type unresolvedImportSeverity struct {
+	pattern  string
+	severity string
+}
+
+// DependencyRule is a java_dependency_rule directive.
+type DependencyRule struct {
+	Allow bool
+	// From and To are the patterns of the importing and imported sides.
+	From string
+	To   string
+}
+
+func (r DependencyRule) String() string {
+	action := "deny"
+	if r.Allow {
+		action = "allow"
+	}
+	return fmt.Sprintf("%s %s %s", action, r.From, r.To)
+}
+
 type LoadInfo struct {
 	From   string
 	Symbol string
@@ -234,6 +412,13 @@ func New(repoRoot string) *Config {
 		mavenRepositoryName:    "maven",
 		annotationProcessorFullQualifiedClassToPluginClass: make(map[string]*sorted_set.SortedSet[types.ClassName]),
 		annotationProcessorExtraImports:                    make(map[string]*sorted_set.SortedSet[types.ClassName]),
+		annotationProcessorGeneratedClasses:                make(map[string]*sorted_set.SortedSet[string]),
+		annotationProcessorDiscovery:                       true,
+		kotlinCompilerPlugins:                              make(map[string]*sorted_set.SortedSet[label.Label]),
+		runtimeDeps:                                        make(map[string]*sorted_set.SortedSet[string]),
+		serviceLoaderRuntimeDeps:                           false,
+		platformPackages:                                   make(map[string]string),
+		release:                                            0,
 		sourcesetRoot:                                      "",
 		stripResourcesPrefix:                               "",
 		libraryNamingConvention:                            "{dirname}",
@@ -296,6 +481,40 @@ func (c *Config) SetKotlinEnabled(enabled bool) {
 	c.kotlinEnabled = enabled
 }
 
+// DefaultVisibility returns the visibility of generated targets set by java_default_visibility,
+// or nil if unset.
+func (c *Config) DefaultVisibility() []string {
+	return c.defaultVisibility
+}
+
+// SetDefaultVisibility sets the visibility of generated targets, or restores the defaults
+// if visibility is empty.
+func (c *Config) SetDefaultVisibility(visibility []string) {
+	if len(visibility) == 0 {
+		visibility = nil
+	}
+	c.defaultVisibility = visibility
+}
+
+// InferVisibility returns whether the visibility of generated libraries is inferred from the
+// packages depending on them.
+func (c *Config) InferVisibility() bool {
+	return c.inferVisibility
+}
+
+func (c *Config) SetInferVisibility(infer bool) {
+	c.inferVisibility = infer
+}
+
+// ExtraVisibility returns the visibility labels added to inferred visibilities.
+func (c *Config) ExtraVisibility() []string {
+	return c.extraVisibility
+}
+
+func (c *Config) AddExtraVisibility(visibility string) {
+	c.extraVisibility = append(c.extraVisibility, visibility)
+}
+
 func (c *Config) TestOnly() bool {
 	return c.testOnly
 }
@@ -333,14 +552,14 @@ func (c Config) ModuleGranularity() string {
 }
 
 func (c *Config) SetModuleGranularity(granularity string) error {
-	if granularity != "module" && granularity != "package" && granularity != "scc" {
-		return fmt.Errorf("%s: possible values are module/package/scc", granularity)
+	if granularity != "module" && granularity != "package" && granularity != "scc" && granularity != "auto" && granularity != "file" {
+		return fmt.Errorf("%s: possible values are module/package/scc/auto/file", granularity)
 	}
 
-	// Both "module" (one coarse target) and "scc" (minimal fine-grained targets) aggregate
-	// the whole subtree at the topmost directory that enables them.
-	if granularity == "module" || granularity == "scc" {
-		if c.parent == nil || c.parent.moduleGranularity == "package" {
+	// "module" (one coarse target), "scc" (minimal fine-grained targets) and "auto" (as "scc",
+	// logging the grouping) aggregate the whole subtree at the topmost directory that enables them.
+	if granularity == "module" || granularity == "scc" || granularity == "auto" {
+		if c.parent == nil || c.parent.moduleGranularity == "package" || c.parent.moduleGranularity == "file" {
 			c.isModuleRoot = true
 		}
 	}
@@ -457,6 +676,272 @@ func (c *Config) AddAnnotationProcessorExtraImport(annotationClass types.ClassNa
 	c.annotationProcessorExtraImports[fullyQualifiedAnnotationClass].Add(extraImport)
 }
 
+// GetAnnotationProcessorGeneratedClasses returns the templates of the names of the classes
+// generated for the classes annotated with annotationClass, see JavaAnnotationProcessorGeneratedClass.
+func (c *Config) GetAnnotationProcessorGeneratedClasses(annotationClass types.ClassName) *sorted_set.SortedSet[string] {
+	return c.annotationProcessorGeneratedClasses[annotationClass.FullyQualifiedClassName()]
+}
+
+func (c *Config) AddAnnotationProcessorGeneratedClass(annotationClass types.ClassName, template string) {
+	fullyQualifiedAnnotationClass := annotationClass.FullyQualifiedClassName()
+	if _, ok := c.annotationProcessorGeneratedClasses[fullyQualifiedAnnotationClass]; !ok {
+		c.annotationProcessorGeneratedClasses[fullyQualifiedAnnotationClass] = sorted_set.NewSortedSet([]string{})
+	}
+	c.annotationProcessorGeneratedClasses[fullyQualifiedAnnotationClass].Add(template)
+}
+
+func (c *Config) AnnotationProcessorDiscovery() bool {
+	return c.annotationProcessorDiscovery
+}
+
+func (c *Config) SetAnnotationProcessorDiscovery(enabled bool) {
+	c.annotationProcessorDiscovery = enabled
+}
+
+// AddPlatformPackage records that the platform provides pkg and its sub-packages. dep is the
+// label to depend on for it, or "" for none.
+func (c *Config) AddPlatformPackage(pkg types.PackageName, dep string) {
+	c.platformPackages[pkg.Name] = dep
+}
+
+// PlatformPackage returns whether the platform provides pkg, and the label to depend on for
+// it, or "" for none. The most specific declared package wins.
+func (c *Config) PlatformPackage(pkg types.PackageName) (string, bool) {
+	var dep, match string
+	found := false
+	for prefix, prefixDep := range c.platformPackages {
+		if types.PackageNamesHasPrefix(pkg, types.NewPackageName(prefix)) && (!found || len(prefix) > len(match)) {
+			dep, match, found = prefixDep, prefix, true
+		}
+	}
+	return dep, found
+}
+
+// AddRepositoryIndex adds the index of another repository to consult when resolving imports.
+func (c *Config) AddRepositoryIndex(idx *repository_index.Index) {
+	c.repositoryIndexes = append(c.repositoryIndexes, idx)
+}
+
+// RepositoryIndexes returns the indexes of other repositories, in the order to consult them.
+func (c *Config) RepositoryIndexes() []*repository_index.Index {
+	return c.repositoryIndexes
+}
+
+// AddUnresolvedImportSeverity sets the severity of unresolved imports of packages matching
+// pattern, taking precedence over the severities added before it.
+func (c *Config) AddUnresolvedImportSeverity(pattern, severity string) error {
+	switch severity {
+	case SeverityError, SeverityWarn, SeverityIgnore:
+	default:
+		return fmt.Errorf("invalid severity %q: possible values are %s, %s and %s", severity, SeverityError, SeverityWarn, SeverityIgnore)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	if strings.Contains(pattern, "/") {
+		return errors.New("patterns match package names, which can't contain '/'")
+	}
+	c.unresolvedImportSeverities = append(c.unresolvedImportSeverities, unresolvedImportSeverity{pattern: pattern, severity: severity})
+	return nil
+}
+
+// UnresolvedImportSeverity returns the severity of an unresolved import of pkg.
+func (c *Config) UnresolvedImportSeverity(pkg types.PackageName) string {
+	for i := len(c.unresolvedImportSeverities) - 1; i >= 0; i-- {
+		// Package names contain no '/', so '*' matches across dots.
+		if matched, _ := path.Match(c.unresolvedImportSeverities[i].pattern, pkg.Name); matched {
+			return c.unresolvedImportSeverities[i].severity
+		}
+	}
+	return SeverityError
+}
+
+// AddDependencyRule allows or denies the dependencies from rules matching from on those
+// matching to, taking precedence over the rules added before it. action is "allow" or "deny".
+func (c *Config) AddDependencyRule(action, from, to string) error {
+	var allow bool
+	switch action {
+	case "allow":
+		allow = true
+	case "deny":
+		allow = false
+	default:
+		return fmt.Errorf("invalid action %q: possible values are allow and deny", action)
+	}
+	for _, pattern := range []string{from, to} {
+		if err := validateDependencyPattern(pattern); err != nil {
+			return err
+		}
+	}
+	c.dependencyRules = append(c.dependencyRules, DependencyRule{Allow: allow, From: from, To: to})
+	return nil
+}
+
+// MatchingDependencyRule returns the last dependency rule matching the dependency of the rule
+// from, declaring the package fromPkg, on dep for an import of imp, if any.
+func (c *Config) MatchingDependencyRule(from label.Label, fromPkg types.PackageName, dep label.Label, imp types.PackageName) (DependencyRule, bool) {
+	for i := len(c.dependencyRules) - 1; i >= 0; i-- {
+		r := c.dependencyRules[i]
+		if matchesDependencyPattern(r.From, from, fromPkg) && matchesDependencyPattern(r.To, dep, imp) {
+			return r, true
+		}
+	}
+	return DependencyRule{}, false
+}
+
+func isLabelPattern(pattern string) bool {
+	return strings.HasPrefix(pattern, "//") || strings.HasPrefix(pattern, "@")
+}
+
+func validateDependencyPattern(pattern string) error {
+	if isLabelPattern(pattern) {
+		if base, found := strings.CutSuffix(pattern, "..."); found {
+			if !strings.HasSuffix(base, "//") && !strings.HasSuffix(base, "/") {
+				return fmt.Errorf("invalid label pattern %q: \"...\" must follow a '/'", pattern)
+			}
+			return nil
+		}
+		if _, err := label.Parse(pattern); err != nil {
+			return fmt.Errorf("invalid label pattern %q: %w", pattern, err)
+		}
+		return nil
+	}
+	if strings.Contains(pattern, "...") {
+		return fmt.Errorf("invalid package pattern %q: use \"..\" to match any number of packages", pattern)
+	}
+	for _, segment := range packagePatternSegments(pattern) {
+		if _, err := path.Match(segment, ""); err != nil || strings.Contains(segment, "/") {
+			return fmt.Errorf("invalid package pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// matchesDependencyPattern matches a label pattern against l, or a package pattern against pkg.
+func matchesDependencyPattern(pattern string, l label.Label, pkg types.PackageName) bool {
+	if isLabelPattern(pattern) {
+		return MatchesLabelPattern(pattern, l)
+	}
+	var names []string
+	if pkg.Name != "" {
+		names = strings.Split(pkg.Name, ".")
+	}
+	return matchesPackageSegments(packagePatternSegments(pattern), names)
+}
+
+// MatchesLabelPattern returns whether l is matched by pattern, which is either a label or a
+// package followed by "/..." to match every rule in it and below. Patterns without a repository
+// only match labels of the main repository.
+func MatchesLabelPattern(pattern string, l label.Label) bool {
+	if base, found := strings.CutSuffix(pattern, "..."); found {
+		repo, pkg, _ := strings.Cut(base, "//")
+		repo = strings.TrimPrefix(repo, "@")
+		pkg = strings.TrimSuffix(pkg, "/")
+		if repo != l.Repo {
+			return false
+		}
+		return pkg == "" || l.Pkg == pkg || strings.HasPrefix(l.Pkg, pkg+"/")
+	}
+	want, err := label.Parse(pattern)
+	if err != nil {
+		return false
+	}
+	return want.Repo == l.Repo && want.Pkg == l.Pkg && want.Name == l.Name
+}
+
+// packagePatternSegments splits a package pattern into package names, with "**" standing for
+// each "..", e.g. "..domain.." becomes ["**", "domain", "**"].
+func packagePatternSegments(pattern string) []string {
+	var segments []string
+	for i, part := range strings.Split(pattern, "..") {
+		if i > 0 {
+			segments = append(segments, "**")
+		}
+		for _, name := range strings.Split(part, ".") {
+			if name != "" {
+				segments = append(segments, name)
+			}
+		}
+	}
+	return segments
+}
+
+func matchesPackageSegments(segments, names []string) bool {
+	if len(segments) == 0 {
+		return len(names) == 0
+	}
+	if segments[0] == "**" {
+		for i := 0; i <= len(names); i++ {
+			if matchesPackageSegments(segments[1:], names[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(names) == 0 {
+		return false
+	}
+	if matched, _ := path.Match(segments[0], names[0]); !matched {
+		return false
+	}
+	return matchesPackageSegments(segments[1:], names[1:])
+}
+
+// Release returns the Java release the code is compiled against, or 0 if unset.
+func (c *Config) Release() int {
+	return c.release
+}
+
+func (c *Config) SetRelease(release int) {
+	c.release = release
+}
+
+// AddKotlinCompilerPlugin records that the Kotlin sources using annotationClass need plugin.
+func (c *Config) AddKotlinCompilerPlugin(annotationClass types.ClassName, plugin label.Label) {
+	fullyQualifiedAnnotationClass := annotationClass.FullyQualifiedClassName()
+	if _, ok := c.kotlinCompilerPlugins[fullyQualifiedAnnotationClass]; !ok {
+		c.kotlinCompilerPlugins[fullyQualifiedAnnotationClass] = sorted_set.NewSortedSetFn[label.Label](nil, sorted_set.LabelLess)
+	}
+	c.kotlinCompilerPlugins[fullyQualifiedAnnotationClass].Add(plugin)
+}
+
+// KotlinCompilerPlugins returns the Kotlin compiler plugins needed by the sources using annotationClass.
+func (c *Config) KotlinCompilerPlugins(annotationClass types.ClassName) *sorted_set.SortedSet[label.Label] {
+	return c.kotlinCompilerPlugins[annotationClass.FullyQualifiedClassName()]
+}
+
+// AddRuntimeDep records that targets importing pkg, or one of its sub-packages, need dep at runtime.
+func (c *Config) AddRuntimeDep(pkg types.PackageName, dep string) {
+	if _, ok := c.runtimeDeps[pkg.Name]; !ok {
+		c.runtimeDeps[pkg.Name] = sorted_set.NewSortedSet([]string{})
+	}
+	c.runtimeDeps[pkg.Name].Add(dep)
+}
+
+// RuntimeDeps returns the runtime dependencies needed by a target importing pkg.
+func (c *Config) RuntimeDeps(pkg types.PackageName) *sorted_set.SortedSet[string] {
+	out := sorted_set.NewSortedSet([]string{})
+	for prefix, deps := range c.runtimeDeps {
+		if pkg.Name == prefix || strings.HasPrefix(pkg.Name, prefix+".") {
+			out.AddAll(deps)
+		}
+	}
+	return out
+}
+
+func (c *Config) ServiceLoaderRuntimeDeps() bool {
+	return c.serviceLoaderRuntimeDeps
+}
+
+func (c *Config) SetServiceLoaderRuntimeDeps(enabled bool) {
+	c.serviceLoaderRuntimeDeps = enabled
+}
+
+// RepoRoot returns the absolute path of the root of the repository.
+func (c *Config) RepoRoot() string {
+	return c.repoRoot
+}
+
 func (c *Config) ResolveToJavaExports() bool {
 	return c.resolveToJavaExports.Value()
 }
@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
 )
 
 func TestDefaultTestSuffixes(t *testing.T) {
@@ -149,3 +151,178 @@ func TestGenerateProtoInheritsToChild(t *testing.T) {
 		t.Fatalf("child did not inherit generateProto=false from parent; got true")
 	}
 }
+
+func TestAutoGranularity(t *testing.T) {
+	root := javaconfig.New("/tmp")
+	if err := root.SetModuleGranularity("auto"); err != nil {
+		t.Fatal(err)
+	}
+	if !root.IsModuleRoot() {
+		t.Errorf("root: want an auto granularity module root")
+	}
+
+	child := root.NewChild()
+	if child.ModuleGranularity() != "auto" || child.IsModuleRoot() {
+		t.Errorf("child: want auto granularity, as part of the module of its parent")
+	}
+
+	// A directive of a subdirectory doesn't start a module of its own.
+	if err := child.SetModuleGranularity("scc"); err != nil {
+		t.Fatal(err)
+	}
+	if child.IsModuleRoot() {
+		t.Errorf("child: want to be part of the auto module of its parent")
+	}
+
+	if err := root.SetModuleGranularity("automatic"); err == nil {
+		t.Errorf("want an error for an unknown granularity")
+	}
+}
+
+func TestPlatformPackage(t *testing.T) {
+	parent := javaconfig.New("/tmp")
+	parent.AddPlatformPackage(types.NewPackageName("jakarta"), "")
+	child := parent.NewChild()
+	child.AddPlatformPackage(types.NewPackageName("jakarta.servlet"), "//third_party:servlet_api_neverlink")
+
+	for pkg, want := range map[string]struct {
+		dep   string
+		found bool
+	}{
+		"jakarta.servlet.http": {"//third_party:servlet_api_neverlink", true},
+		"jakarta.inject":       {"", true},
+		"jakartaee.other":      {"", false},
+	} {
+		t.Run(pkg, func(t *testing.T) {
+			dep, found := child.PlatformPackage(types.NewPackageName(pkg))
+			if dep != want.dep || found != want.found {
+				t.Fatalf("want (%q, %v) got (%q, %v)", want.dep, want.found, dep, found)
+			}
+		})
+	}
+
+	if _, found := parent.PlatformPackage(types.NewPackageName("jakarta.servlet")); !found {
+		t.Fatalf("parent should still treat jakarta.servlet as a platform package")
+	}
+	if dep, _ := parent.PlatformPackage(types.NewPackageName("jakarta.servlet")); dep != "" {
+		t.Fatalf("child directive leaked into parent: %q", dep)
+	}
+}
+
+func TestUnresolvedImportSeverity(t *testing.T) {
+	parent := javaconfig.New("/tmp")
+	if err := parent.AddUnresolvedImportSeverity("com.legacy.*", javaconfig.SeverityWarn); err != nil {
+		t.Fatal(err)
+	}
+	child := parent.NewChild()
+	if err := child.AddUnresolvedImportSeverity("com.legacy.gone*", javaconfig.SeverityIgnore); err != nil {
+		t.Fatal(err)
+	}
+
+	for pkg, want := range map[string]string{
+		"com.legacy.util":      javaconfig.SeverityWarn,
+		"com.legacy.util.deep": javaconfig.SeverityWarn,
+		"com.legacy.gone":      javaconfig.SeverityIgnore,
+		"com.legacy":           javaconfig.SeverityError,
+		"com.example":          javaconfig.SeverityError,
+	} {
+		t.Run(pkg, func(t *testing.T) {
+			if got := child.UnresolvedImportSeverity(types.NewPackageName(pkg)); got != want {
+				t.Fatalf("want %v got %v", want, got)
+			}
+		})
+	}
+
+	if got := parent.UnresolvedImportSeverity(types.NewPackageName("com.legacy.gone")); got != javaconfig.SeverityWarn {
+		t.Fatalf("child directive leaked into parent: %v", got)
+	}
+	if err := parent.AddUnresolvedImportSeverity("com.*", "fatal"); err == nil {
+		t.Fatalf("expected an error for an unknown severity")
+	}
+	if err := parent.AddUnresolvedImportSeverity("com.[", javaconfig.SeverityWarn); err == nil {
+		t.Fatalf("expected an error for a malformed pattern")
+	}
+}
+
+func TestMatchingDependencyRule(t *testing.T) {
+	parent := javaconfig.New("/tmp")
+	if err := parent.AddDependencyRule("deny", "..domain..", "..infra.."); err != nil {
+		t.Fatal(err)
+	}
+	child := parent.NewChild()
+	if err := child.AddDependencyRule("allow", "//src/domain/...", "com.example.infra.api"); err != nil {
+		t.Fatal(err)
+	}
+	if err := child.AddDependencyRule("deny", "com.example.*", "@maven//..."); err != nil {
+		t.Fatal(err)
+	}
+
+	domain := label.New("", "src/domain/orders", "orders")
+	other := label.New("", "src/other", "other")
+	infra := label.New("", "src/infra", "infra")
+	guava := label.New("maven", "", "com_google_guava_guava")
+	for _, tc := range []struct {
+		name    string
+		from    label.Label
+		fromPkg string
+		dep     label.Label
+		imp     string
+		want    string
+	}{
+		{"denied", other, "com.example.domain.orders", infra, "com.example.infra.db", "deny ..domain.. ..infra.."},
+		{"leading dots match no packages", other, "domain", infra, "infra", "deny ..domain.. ..infra.."},
+		{"allowed by a later rule", domain, "com.example.domain.orders", infra, "com.example.infra.api", "allow //src/domain/... com.example.infra.api"},
+		{"label pattern", other, "com.example.app", guava, "com.google.common.base", "deny com.example.* @maven//..."},
+		{"star matches one package", other, "com.example.app.deep", guava, "com.google.common.base", ""},
+		{"no match", other, "com.example.domainx", infra, "com.example.infra", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, found := child.MatchingDependencyRule(tc.from, types.NewPackageName(tc.fromPkg), tc.dep, types.NewPackageName(tc.imp))
+			if tc.want == "" {
+				if found {
+					t.Fatalf("want no rule, got %v", rule)
+				}
+				return
+			}
+			if !found || rule.String() != tc.want {
+				t.Fatalf("want %v got %v (found %v)", tc.want, rule, found)
+			}
+		})
+	}
+
+	if _, found := parent.MatchingDependencyRule(other, types.NewPackageName("com.example.app"), guava, types.NewPackageName("com.google.common.base")); found {
+		t.Fatalf("child directive leaked into parent")
+	}
+	for _, rule := range [][3]string{
+		{"forbid", "..domain..", "..infra.."},
+		{"deny", "com...domain", "..infra.."},
+		{"deny", "//src/domain:a:b", "..infra.."},
+		{"deny", "//src/domain...", "..infra.."},
+		{"deny", "com.[", "..infra.."},
+	} {
+		if err := parent.AddDependencyRule(rule[0], rule[1], rule[2]); err == nil {
+			t.Errorf("expected an error for %v", rule)
+		}
+	}
+}
+
+func TestMatchesLabelPattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		l       label.Label
+		want    bool
+	}{
+		{"//src/app", label.New("", "src/app", "app"), true},
+		{"//src/app:app", label.New("", "src/app", "test"), false},
+		{"//src/...", label.New("", "src/app", "app"), true},
+		{"//src/app/...", label.New("", "src/application", "app"), false},
+		{"//...", label.New("", "", "root"), true},
+		{"//...", label.New("maven", "", "com_google_guava_guava"), false},
+		{"@maven//...", label.New("maven", "", "com_google_guava_guava"), true},
+		{"@maven//:com_google_guava_guava", label.New("maven", "", "com_google_guava_guava"), true},
+	} {
+		if got := javaconfig.MatchesLabelPattern(tc.pattern, tc.l); got != tc.want {
+			t.Errorf("%s matching %s: want %v got %v", tc.pattern, tc.l, tc.want, got)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/javaparser"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/logconfig"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/maven"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/repository_index"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_multiset"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
 	"github.com/bazelbuild/bazel-gazelle/config"
@@ -47,6 +48,42 @@ type javaLang struct {
 	// `associates` (Kotlin friends), so module-wide `internal` survives the fine-grained split.
 	kotlinLibraries map[string]bool
 
+	// unresolvedImports collects the imports no dependency provides, reported once resolving is done.
+	unresolvedImports *unresolvedImports
+
+	// mavenReport records how Maven artifacts are used, if a usage report was requested.
+	mavenReport *mavenReport
+
+	// visibility collects the packages depending on each generated library, to infer its visibility.
+	visibility *visibilityInference
+
+	// packageCycles collects the dependencies between rules in package granularity, to report their cycles.
+	packageCycles *packageCycles
+
+	// resolutionTrace records why each dependency was added, if a resolution trace was requested.
+	resolutionTrace *resolutionTrace
+
+	// dependencyGraph records the resolved rules and their edges, if a dependency graph was requested.
+	dependencyGraph *dependencyGraph
+
+	// repositoryIndexes are the indexes of other repositories loaded by java_repository_index
+	// directives, keyed by repository and path.
+	repositoryIndexes map[string]*repository_index.Index
+
+	// annotationProcessorPlugins records the java_plugin targets generated for the annotation
+	// processors of the repository.
+	annotationProcessorPlugins *annotationProcessorPlugins
+
+	// serviceRegistrationsCache caches the services registered by the META-INF/services
+	// directories, by path.
+	serviceRegistrationsCache map[string]map[string][]string
+
+	// serviceLoaders records the services loaded by the parsed packages.
+	serviceLoaders *serviceLoaders
+
+	// repositoryIndexWriter collects the libraries of this repository, if an index was requested.
+	repositoryIndexWriter *repositoryIndexWriter
+
 	// hasHadErrors triggers the extension to fail at destroy time.
 	//
 	// this is used to return != 0 when some errors during the generation were
@@ -83,12 +120,20 @@ func NewLanguage() language.Language {
 	logger.Debug().Msg("creating java language")
 
 	l := javaLang{
-		logger:           logger,
-		javaLogLevel:     javaLevel,
-		javaPackageCache: make(map[string]*java.Package),
-		javaExportIndex:  java_export_index.NewJavaExportIndex(languageName, logger),
-		classExportCache: make(map[string]classExportInfo),
-		kotlinLibraries:  make(map[string]bool),
+		logger:            logger,
+		javaLogLevel:      javaLevel,
+		javaPackageCache:  make(map[string]*java.Package),
+		javaExportIndex:   java_export_index.NewJavaExportIndex(languageName, logger),
+		classExportCache:  make(map[string]classExportInfo),
+		kotlinLibraries:   make(map[string]bool),
+		unresolvedImports: newUnresolvedImports(),
+		visibility:        newVisibilityInference(),
+		packageCycles:     newPackageCycles(),
+		repositoryIndexes: make(map[string]*repository_index.Index),
+
+		annotationProcessorPlugins: newAnnotationProcessorPlugins(),
+		serviceRegistrationsCache:  make(map[string]map[string][]string),
+		serviceLoaders:             newServiceLoaders(),
 	}
 
 	l.logger = l.logger.Hook(shutdownServerOnFatalLogHook{
@@ -140,6 +185,16 @@ var javaLibraryKind = rule.KindInfo{
 	},
 }
 
+var javaPluginKind = rule.KindInfo{
+	NonEmptyAttrs: map[string]bool{
+		"deps": true,
+	},
+	MergeableAttrs: map[string]bool{"processor_class": true},
+	ResolveAttrs: map[string]bool{
+		"deps": true,
+	},
+}
+
 var javaExportKind = rule.KindInfo{
 	NonEmptyAttrs: map[string]bool{
 		"deps":         true,
@@ -167,6 +222,7 @@ var kotlinLibraryKind = rule.KindInfo{
 		"associates":   true,
 		"deps":         true,
 		"exports":      true,
+		"plugins":      true,
 		"runtime_deps": true,
 	},
 }
@@ -176,6 +232,7 @@ func (l javaLang) Kinds() map[string]rule.KindInfo {
 		"java_binary":        kindWithRuntimeDeps,
 		"java_junit5_test":   kindWithRuntimeDeps,
 		"java_library":       javaLibraryKind,
+		"java_plugin":        javaPluginKind,
 		"java_export":        javaExportKind,
 		"java_test":          kindWithRuntimeDeps,
 		"java_test_suite":    kindWithRuntimeDeps,
@@ -204,6 +261,7 @@ var baseJavaLoads = []rule.LoadInfo{
 		Symbols: []string{
 			"java_binary",
 			"java_library",
+			"java_plugin",
 			"java_proto_library",
 			"java_test",
 		},
@@ -281,6 +339,22 @@ func (l javaLang) DoneGeneratingRules() {
 }
 
 func (l javaLang) AfterResolvingDeps(_ context.Context) {
+	partial := l.visibility.partialRun()
+	if l.mavenReport != nil {
+		l.mavenReport.write(l.mavenResolver, partial, l.logger)
+	}
+	if l.resolutionTrace != nil {
+		l.resolutionTrace.write(l.logger)
+	}
+	if l.dependencyGraph != nil {
+		l.dependencyGraph.write(l.logger)
+	}
+	if l.repositoryIndexWriter != nil {
+		l.repositoryIndexWriter.write(l, partial)
+	}
+	l.visibility.apply(l.logger)
+	l.packageCycles.report(l.logger)
+	l.unresolvedImports.log(l.logger)
 	if l.hasHadErrors {
 		l.logger.Fatal().Msg("the java extension encountered errors that will create invalid build files")
 	}
@@ -0,0 +1,195 @@
+package gazelle
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/maven"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/rs/zerolog"
+)
+
+// mavenReportAttrs are the attributes through which a rule can use a Maven artifact.
+var mavenReportAttrs = []string{"deps", "exports", "runtime_deps", "plugins"}
+
+// mavenReport records which Maven artifacts the resolved rules use, and which imported
+// packages have no provider at all, to report the pinned artifacts nothing needs.
+type mavenReport struct {
+	path string
+	// usedBy maps the label of a Maven artifact to the rules using it.
+	usedBy map[string]*sorted_set.SortedSet[string]
+	// repositories are the Maven repository names the resolved rules were configured with.
+	repositories *sorted_set.SortedSet[string]
+	// unresolved maps an imported package without a provider to the rules importing it.
+	unresolved map[string]*sorted_set.SortedSet[string]
+}
+
+func newMavenReport(path string) *mavenReport {
+	return &mavenReport{
+		path:         path,
+		usedBy:       make(map[string]*sorted_set.SortedSet[string]),
+		repositories: sorted_set.NewSortedSet([]string{}),
+		unresolved:   make(map[string]*sorted_set.SortedSet[string]),
+	}
+}
+
+// recordRule records the Maven artifacts a resolved rule uses.
+func (mr *mavenReport) recordRule(mavenRepositoryName string, from label.Label, r *rule.Rule) {
+	mr.repositories.Add(mavenRepositoryName)
+	for _, attr := range mavenReportAttrs {
+		for _, s := range r.AttrStrings(attr) {
+			l, err := label.Parse(s)
+			if err != nil || l.Repo != mavenRepositoryName {
+				continue
+			}
+			// A plugin uses the artifact it is generated from.
+			l.Name, _, _ = strings.Cut(l.Name, "__java_plugin__")
+			addToSetMap(mr.usedBy, l.String(), from.String())
+		}
+	}
+}
+
+// recordUnresolved records an import for which no provider was found.
+func (mr *mavenReport) recordUnresolved(pkg types.PackageName, from label.Label) {
+	addToSetMap(mr.unresolved, pkg.Name, from.String())
+}
+
+func addToSetMap(m map[string]*sorted_set.SortedSet[string], key, value string) {
+	if _, ok := m[key]; !ok {
+		m[key] = sorted_set.NewSortedSet([]string{})
+	}
+	m[key].Add(value)
+}
+
+type mavenReportJSON struct {
+	// Direct artifacts are used by at least one rule.
+	Direct []mavenReportArtifact `json:"direct"`
+	// TransitiveOnly artifacts are used by no rule, but are dependencies of direct ones.
+	TransitiveOnly []mavenReportArtifact `json:"transitive_only"`
+	// Unused artifacts are neither used by a rule nor needed by a direct artifact.
+	Unused []string `json:"unused"`
+	// UnresolvedPackages are imported packages which no target provides.
+	UnresolvedPackages []mavenReportPackage `json:"unresolved_packages"`
+}
+
+type mavenReportArtifact struct {
+	Artifact string `json:"artifact"`
+	// UsedBy are the rules using a direct artifact.
+	UsedBy []string `json:"used_by,omitempty"`
+	// RequiredBy are the direct artifacts which transitively depend on a transitive-only one.
+	RequiredBy []string `json:"required_by,omitempty"`
+}
+
+type mavenReportPackage struct {
+	Package    string   `json:"package"`
+	ImportedBy []string `json:"imported_by"`
+}
+
+// build classifies every pinned artifact as direct, transitive-only or unused.
+func (mr *mavenReport) build(pinned []maven.PinnedArtifact) mavenReportJSON {
+	report := mavenReportJSON{
+		Direct:             []mavenReportArtifact{},
+		TransitiveOnly:     []mavenReportArtifact{},
+		Unused:             []string{},
+		UnresolvedPackages: []mavenReportPackage{},
+	}
+
+	dependencies := make(map[string][]string, len(pinned))
+	requiredBy := make(map[string]*sorted_set.SortedSet[string])
+	var direct []string
+	for _, p := range pinned {
+		dependencies[p.Artifact] = p.Dependencies
+		usedBy := sorted_set.NewSortedSet([]string{})
+		for _, repository := range mr.repositories.SortedSlice() {
+			usedBy.AddAll(mr.usedBy[maven.LabelFromArtifact(repository, p.Artifact).String()])
+		}
+		if usedBy.Len() > 0 {
+			direct = append(direct, p.Artifact)
+			requiredBy[p.Artifact] = nil
+			report.Direct = append(report.Direct, mavenReportArtifact{Artifact: p.Artifact, UsedBy: usedBy.SortedSlice()})
+		}
+	}
+
+	// Walk the dependency graph from every direct artifact, remembering which direct
+	// artifacts reach each transitive one.
+	for _, root := range direct {
+		seen := map[string]bool{root: true}
+		queue := append([]string{}, dependencies[root]...)
+		for len(queue) > 0 {
+			artifact := queue[0]
+			queue = queue[1:]
+			if seen[artifact] {
+				continue
+			}
+			seen[artifact] = true
+			if set, isDirect := requiredBy[artifact]; !isDirect || set != nil {
+				addToSetMap(requiredBy, artifact, root)
+			}
+			queue = append(queue, dependencies[artifact]...)
+		}
+	}
+
+	for _, p := range pinned {
+		set, found := requiredBy[p.Artifact]
+		switch {
+		case found && set == nil:
+			// Direct.
+		case found:
+			report.TransitiveOnly = append(report.TransitiveOnly, mavenReportArtifact{Artifact: p.Artifact, RequiredBy: set.SortedSlice()})
+		default:
+			report.Unused = append(report.Unused, p.Artifact)
+		}
+	}
+
+	pkgs := make([]string, 0, len(mr.unresolved))
+	for pkg := range mr.unresolved {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		report.UnresolvedPackages = append(report.UnresolvedPackages, mavenReportPackage{Package: pkg, ImportedBy: mr.unresolved[pkg].SortedSlice()})
+	}
+	return report
+}
+
+// write writes the JSON report and logs a human-readable summary of it. Only the rules of the
+// packages generated in this run are known, so in a partial run, artifacts used elsewhere would
+// be reported as unused: no report is written then.
+func (mr *mavenReport) write(resolver maven.Resolver, partial bool, logger zerolog.Logger) {
+	if partial {
+		logger.Warn().
+			Str("path", mr.path).
+			Msg("Not writing the maven usage report: some packages of the repository were not generated in this run")
+		return
+	}
+	report := mr.build(resolver.PinnedArtifacts())
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to encode maven usage report")
+		return
+	}
+	if err := os.WriteFile(mr.path, append(data, '\n'), 0o644); err != nil {
+		logger.Error().Err(err).Str("path", mr.path).Msg("failed to write maven usage report")
+		return
+	}
+
+	logger.Info().
+		Str("path", mr.path).
+		Int("direct", len(report.Direct)).
+		Int("transitive_only", len(report.TransitiveOnly)).
+		Int("unused", len(report.Unused)).
+		Int("unresolved_packages", len(report.UnresolvedPackages)).
+		Msg("Wrote maven usage report")
+	for _, artifact := range report.Unused {
+		logger.Info().Str("artifact", artifact).Msg("Pinned artifact is not used by any target")
+	}
+	for _, pkg := range report.UnresolvedPackages {
+		logger.Info().Str("package", pkg.Package).Strs("imported_by", pkg.ImportedBy).Msg("Imported package has no provider")
+	}
+}
@@ -0,0 +1,78 @@
+package gazelle
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/maven"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+type pinnedArtifactsResolver struct {
+	testResolver
+	pinned []maven.PinnedArtifact
+}
+
+func (r *pinnedArtifactsResolver) PinnedArtifacts() []maven.PinnedArtifact {
+	return r.pinned
+}
+
+func TestMavenReport(t *testing.T) {
+	report := newMavenReport(filepath.Join(t.TempDir(), "report.json"))
+
+	lib := rule.NewRule("java_library", "lib")
+	lib.SetAttr("deps", []string{"//other:lib", "@maven//:com_google_guava_guava"})
+	lib.SetAttr("plugins", []string{"@maven//:com_google_auto_value_auto_value__java_plugin__com_google_auto_value_processor_AutoValueProcessor"})
+	report.recordRule("maven", label.New("", "src/lib", "lib"), lib)
+
+	test := rule.NewRule("java_test", "test")
+	test.SetAttr("deps", []string{":lib", "@maven//:com_google_guava_guava", "@other//:com_example_ignored"})
+	report.recordRule("maven", label.New("", "src/lib", "test"), test)
+
+	report.recordUnresolved(types.NewPackageName("com.example.missing"), label.New("", "src/lib", "lib"))
+
+	resolver := &pinnedArtifactsResolver{pinned: []maven.PinnedArtifact{
+		{Artifact: "com.google.auto.value:auto-value", Dependencies: []string{}},
+		{Artifact: "com.google.code.findbugs:jsr305", Dependencies: []string{}},
+		{Artifact: "com.google.errorprone:error_prone_annotations", Dependencies: []string{}},
+		{Artifact: "com.google.guava:failureaccess", Dependencies: []string{}},
+		{Artifact: "com.google.guava:guava", Dependencies: []string{"com.google.guava:failureaccess", "com.google.code.findbugs:jsr305"}},
+		{Artifact: "junit:junit", Dependencies: []string{"org.hamcrest:hamcrest-core"}},
+		{Artifact: "org.hamcrest:hamcrest-core", Dependencies: []string{}},
+	}}
+	report.write(resolver, true, zerolog.Nop())
+	_, err := os.Stat(report.path)
+	require.True(t, os.IsNotExist(err), "no report should be written in a partial run")
+
+	report.write(resolver, false, zerolog.Nop())
+
+	data, err := os.ReadFile(report.path)
+	require.NoError(t, err)
+	var got mavenReportJSON
+	require.NoError(t, json.Unmarshal(data, &got))
+
+	require.Equal(t, mavenReportJSON{
+		Direct: []mavenReportArtifact{
+			{Artifact: "com.google.auto.value:auto-value", UsedBy: []string{"//src/lib"}},
+			{Artifact: "com.google.guava:guava", UsedBy: []string{"//src/lib", "//src/lib:test"}},
+		},
+		TransitiveOnly: []mavenReportArtifact{
+			{Artifact: "com.google.code.findbugs:jsr305", RequiredBy: []string{"com.google.guava:guava"}},
+			{Artifact: "com.google.guava:failureaccess", RequiredBy: []string{"com.google.guava:guava"}},
+		},
+		Unused: []string{
+			"com.google.errorprone:error_prone_annotations",
+			"junit:junit",
+			"org.hamcrest:hamcrest-core",
+		},
+		UnresolvedPackages: []mavenReportPackage{
+			{Package: "com.example.missing", ImportedBy: []string{"//src/lib"}},
+		},
+	}, got)
+}
@@ -0,0 +1,158 @@
+package gazelle
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/scc"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/rs/zerolog"
+)
+
+// packageCycles collects the dependencies between the rules of this repository resolved in
+// package granularity, to report the cycles between them once every rule has been resolved,
+// rather than leaving Bazel to fail on them.
+type packageCycles struct {
+	// files maps the parsed source files, relative to the repository root, to their imports.
+	files map[string]java.FileData
+	// srcs maps each rule with a dependency to its source files, relative to the repository root.
+	srcs map[string][]string
+	// edges maps each rule to the rules of this repository it depends on, and those to the
+	// imported classes, or packages, which required the dependency.
+	edges map[string]map[string]*sorted_set.SortedSet[string]
+}
+
+func newPackageCycles() *packageCycles {
+	return &packageCycles{
+		files: make(map[string]java.FileData),
+		srcs:  make(map[string][]string),
+		edges: make(map[string]map[string]*sorted_set.SortedSet[string]),
+	}
+}
+
+// addPackage records the imports of the files of pkg, parsed from the directory rel.
+func (pc *packageCycles) addPackage(rel string, pkg *java.Package) {
+	for file, data := range pkg.PerFileData {
+		pc.files[path.Join(rel, file)] = data
+	}
+}
+
+// addEdge records that the rule r, labelled from, depends on dep, in this repository, because of
+// an import of imp or, if not nil, of class.
+func (pc *packageCycles) addEdge(from label.Label, r *rule.Rule, dep label.Label, imp types.PackageName, class *types.ClassName) {
+	dep = dep.Abs(from.Repo, from.Pkg)
+	fromKey := label.New("", from.Pkg, from.Name).String()
+	depKey := label.New("", dep.Pkg, dep.Name).String()
+	if fromKey == depKey {
+		return
+	}
+
+	deps, ok := pc.edges[fromKey]
+	if !ok {
+		deps = make(map[string]*sorted_set.SortedSet[string])
+		pc.edges[fromKey] = deps
+		var srcs []string
+		for _, src := range r.AttrStrings("srcs") {
+			srcs = append(srcs, path.Join(from.Pkg, src))
+		}
+		pc.srcs[fromKey] = srcs
+	}
+	imported := imp.Name
+	if class != nil {
+		imported = class.FullyQualifiedClassName()
+	}
+	addToSetMap(deps, depKey, imported)
+}
+
+// report logs every strongly-connected set of rules, each of which holds one or more cycles,
+// with the imports creating the dependencies between them.
+func (pc *packageCycles) report(logger zerolog.Logger) {
+	if len(pc.edges) == 0 {
+		return
+	}
+	nodes := sorted_set.NewSortedSet([]string{})
+	adjacency := make(map[string]*sorted_set.SortedSet[string], len(pc.edges))
+	for from, deps := range pc.edges {
+		nodes.Add(from)
+		adjacency[from] = sorted_set.NewSortedSet([]string{})
+		for dep := range deps {
+			nodes.Add(dep)
+			adjacency[from].Add(dep)
+		}
+	}
+
+	components := scc.Components(nodes.SortedSlice(), adjacency)
+	sort.Slice(components, func(i, j int) bool { return components[i][0] < components[j][0] })
+	for _, component := range components {
+		if len(component) < 2 {
+			continue
+		}
+		members := make(map[string]bool, len(component))
+		var packages []string
+		for _, key := range component {
+			members[key] = true
+			l, _ := label.Parse(key)
+			packages = append(packages, l.Pkg)
+		}
+
+		var edges []string
+		for _, from := range component {
+			deps := pc.edges[from]
+			for _, dep := range adjacency[from].SortedSlice() {
+				if !members[dep] {
+					continue
+				}
+				for _, imported := range deps[dep].SortedSlice() {
+					edge := fmt.Sprintf("%s -> %s: %s", from, dep, imported)
+					if files := pc.importingFiles(pc.srcs[from], imported); len(files) > 0 {
+						edge += " in " + strings.Join(files, ", ")
+					}
+					edges = append(edges, edge)
+				}
+			}
+		}
+
+		root := commonDir(packages)
+		logger.Warn().
+			Strs("rules", component).
+			Strs("edges", edges).
+			Str("suggested scc root", "//"+root).
+			Msgf("Dependency cycle between packages, which Bazel will reject: remove one of the edges, or add `# gazelle:java_module_granularity scc` to %s to build the packages of the cycle together", path.Join(root, "BUILD.bazel"))
+	}
+}
+
+// importingFiles returns the files of srcs, relative to the repository root, with an import of
+// imported, a class or a package.
+func (pc *packageCycles) importingFiles(srcs []string, imported string) []string {
+	var files []string
+	for _, src := range srcs {
+		if imports, ok := pc.files[src]; ok && imports.Imports(imported) {
+			files = append(files, src)
+		}
+	}
+	return files
+}
+
+// commonDir returns the innermost directory containing every one of packages.
+func commonDir(packages []string) string {
+	dirs := enclosingDirs(packages[0])
+	for _, dir := range dirs {
+		covers := true
+		for _, pkg := range packages[1:] {
+			if !isUnderDir(pkg, dir) {
+				covers = false
+				break
+			}
+		}
+		if covers {
+			return dir
+		}
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package gazelle
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageCycles(t *testing.T) {
+	c, langs, jLang := testJavaLang(t)
+	mrslv, exts := InitTestResolversAndExtensions(langs)
+	ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+	jLang.packageCycles.addPackage("", &java.Package{
+		PerFileData: map[string]java.FileData{
+			"A.java":      testFileData(t, []string{"com.example.b.B"}),
+			"AUtil.java":  testFileData(t, []string{"java.util.List"}, "com.example.b"),
+			"B.java":      testFileData(t, []string{"com.example.a.A"}),
+			"Client.java": testFileData(t, []string{"com.example.a.A"}),
+		},
+	})
+
+	const content = `java_library(
+    name = "a",
+    srcs = [
+        "A.java",
+        "AUtil.java",
+    ],
+    _imported_packages = ["com.example.b"],
+    _packages = ["com.example.a"],
+)
+
+java_library(
+    name = "b",
+    srcs = ["B.java"],
+    _imported_packages = ["com.example.a"],
+    _packages = ["com.example.b"],
+)
+
+java_library(
+    name = "client",
+    srcs = ["Client.java"],
+    _imported_packages = ["com.example.a"],
+    _packages = ["com.example.client"],
+)`
+	f, err := rule.LoadData("BUILD.bazel", "", []byte(content))
+	require.NoError(t, err)
+	imports := make([]interface{}, len(f.Rules))
+	for i, r := range f.Rules {
+		setPackagesPrivateAttr(r)
+		imports[i] = convertImportsAttr(r)
+		ix.AddRule(c, r, f)
+	}
+	ix.Finish()
+	for i, r := range f.Rules {
+		mrslv.Resolver(r, "").Resolve(c, ix, testRemoteCache(nil), r, imports[i], label.New("", "", r.Name()))
+	}
+
+	var out bytes.Buffer
+	jLang.packageCycles.report(zerolog.New(&out))
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+	require.Equal(t, []interface{}{"//:a", "//:b"}, got["rules"])
+	require.Equal(t, []interface{}{
+		"//:a -> //:b: com.example.b in A.java, AUtil.java",
+		"//:b -> //:a: com.example.a in B.java",
+	}, got["edges"])
+	require.Equal(t, "//", got["suggested scc root"])
+}
+
+// testFileData returns the data of a file importing classes, and every class of packages.
+func testFileData(t *testing.T, classes []string, packages ...string) java.FileData {
+	imports := java.FileData{
+		ImportedClasses:                        sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess),
+		ImportedPackagesWithoutSpecificClasses: sorted_set.NewSortedSetFn([]types.PackageName{}, types.PackageNameLess),
+	}
+	for _, class := range classes {
+		className, err := types.ParseClassName(class)
+		require.NoError(t, err)
+		imports.ImportedClasses.Add(*className)
+	}
+	for _, pkg := range packages {
+		imports.ImportedPackagesWithoutSpecificClasses.Add(types.NewPackageName(pkg))
+	}
+	return imports
+}
+
+func TestCommonDir(t *testing.T) {
+	for _, tc := range []struct {
+		packages []string
+		want     string
+	}{
+		{[]string{"src/a/x", "src/a/y"}, "src/a"},
+		{[]string{"src/a", "src/a/y"}, "src/a"},
+		{[]string{"src/ab", "src/a"}, "src"},
+		{[]string{"src/a", "lib/b"}, ""},
+	} {
+		if got := commonDir(tc.packages); got != tc.want {
+			t.Errorf("commonDir(%v): want %q, got %q", tc.packages, tc.want, got)
+		}
+	}
+}
@@ -158,3 +158,81 @@ func IsStdlib(imp types.PackageName) bool {
 	}
 	return false
 }
+
+// releasePackage is a JDK package whose presence depends on the Java release.
+type releasePackage struct {
+	pkg types.PackageName
+	// exact entries only cover pkg itself, not its sub-packages.
+	exact bool
+	// since is the first release providing the package, or 0 if every release does.
+	since int
+	// removedIn is the first release no longer providing the package, or 0 if none.
+	removedIn int
+}
+
+// releasePackages refine stdlibPrefixes for a specific release: the most specific entry of
+// either list matching an import decides whether it is part of the standard library, with
+// releasePackages winning ties.
+var releasePackages = []releasePackage{
+	// Java EE and CORBA modules, removed by JEP 320.
+	{pkg: types.NewPackageName("com.sun.corba"), removedIn: 11},
+	{pkg: types.NewPackageName("com.sun.xml.internal.bind"), removedIn: 11},
+	{pkg: types.NewPackageName("com.sun.xml.internal.ws"), removedIn: 11},
+	{pkg: types.NewPackageName("javax.activation"), removedIn: 11},
+	{pkg: types.NewPackageName("javax.activity"), removedIn: 11},
+	{pkg: types.NewPackageName("javax.annotation"), exact: true, removedIn: 11},
+	{pkg: types.NewPackageName("javax.annotation.security"), removedIn: 11},
+	{pkg: types.NewPackageName("javax.jws"), removedIn: 11},
+	{pkg: types.NewPackageName("javax.rmi"), removedIn: 11},
+	{pkg: types.NewPackageName("javax.transaction"), removedIn: 11},
+	{pkg: types.NewPackageName("javax.xml.bind"), removedIn: 11},
+	{pkg: types.NewPackageName("javax.xml.soap"), removedIn: 11},
+	{pkg: types.NewPackageName("javax.xml.ws"), removedIn: 11},
+	{pkg: types.NewPackageName("org.omg"), removedIn: 11},
+	// Nashorn, removed by JEP 372.
+	{pkg: types.NewPackageName("jdk.nashorn"), removedIn: 15},
+	// RMI activation, removed by JEP 407.
+	{pkg: types.NewPackageName("java.rmi.activation"), removedIn: 17},
+
+	{pkg: types.NewPackageName("java.net.http"), since: 11},
+	{pkg: types.NewPackageName("java.lang.runtime"), since: 16},
+	{pkg: types.NewPackageName("java.util.random"), since: 17},
+	{pkg: types.NewPackageName("java.lang.foreign"), since: 22},
+	{pkg: types.NewPackageName("java.lang.classfile"), since: 24},
+}
+
+// MinRelease is the oldest Java release IsStdlibForRelease knows about.
+const MinRelease = 8
+
+// IsStdlibForRelease returns whether the standard library of the given Java release provides
+// imp. A release of 0 means no particular release, which is the same as IsStdlib.
+func IsStdlibForRelease(imp types.PackageName, release int) bool {
+	if release == 0 {
+		return IsStdlib(imp)
+	}
+
+	matchLen := -1
+	isStdlib := false
+	for _, prefix := range stdlibPrefixes {
+		if types.PackageNamesHasPrefix(imp, prefix) && len(prefix.Name) > matchLen {
+			matchLen = len(prefix.Name)
+			isStdlib = true
+		}
+	}
+	for _, p := range releasePackages {
+		matches := imp == p.pkg || (!p.exact && types.PackageNamesHasPrefix(imp, p.pkg))
+		if matches && len(p.pkg.Name) >= matchLen {
+			matchLen = len(p.pkg.Name)
+			isStdlib = release >= p.since && (p.removedIn == 0 || release < p.removedIn)
+		}
+	}
+	return isStdlib
+}
+
+// IsJDKInternal returns whether imp is an internal package of the JDK, which is not part
+// of its supported API.
+func IsJDKInternal(imp types.PackageName) bool {
+	return types.PackageNamesHasPrefix(imp, types.NewPackageName("sun")) ||
+		types.PackageNamesHasPrefix(imp, types.NewPackageName("jdk.internal")) ||
+		(types.PackageNamesHasPrefix(imp, types.NewPackageName("com.sun")) && strings.Contains(imp.Name+".", ".internal."))
+}
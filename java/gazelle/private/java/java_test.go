@@ -1,7 +1,11 @@
 package java
 
 import (
+	"fmt"
 	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
 )
 
 func TestIsTestPackage(t *testing.T) {
@@ -31,3 +35,115 @@ func TestIsTestPackage(t *testing.T) {
 		})
 	}
 }
+
+func TestIsStdlibForRelease(t *testing.T) {
+	tests := []struct {
+		pkg     string
+		release int
+		want    bool
+	}{
+		{"java.util", 8, true},
+		{"java.util", 21, true},
+		{"javax.xml.bind", 8, true},
+		{"javax.xml.bind", 11, false},
+		{"javax.xml.bind.annotation", 11, false},
+		{"javax.xml.parsers", 11, true},
+		{"javax.annotation", 8, true},
+		{"javax.annotation", 11, false},
+		{"javax.annotation.processing", 11, true},
+		{"javax.annotation.security", 17, false},
+		{"javax.transaction", 11, false},
+		{"javax.transaction.xa", 11, true},
+		{"javax.rmi.ssl", 17, true},
+		{"org.omg.CORBA", 8, true},
+		{"org.omg.CORBA", 17, false},
+		{"jdk.nashorn.api.scripting", 11, true},
+		{"jdk.nashorn.api.scripting", 17, false},
+		{"java.rmi.activation", 11, true},
+		{"java.rmi.activation", 17, false},
+		{"java.net.http", 8, false},
+		{"java.net.http", 11, true},
+		{"java.lang.foreign", 21, false},
+		{"java.lang.foreign", 22, true},
+		{"com.google.common", 17, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%s@%d", tc.pkg, tc.release), func(t *testing.T) {
+			if got := IsStdlibForRelease(types.NewPackageName(tc.pkg), tc.release); got != tc.want {
+				t.Errorf("IsStdlibForRelease() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// Without a release, the fixed list applies.
+	if !IsStdlibForRelease(types.NewPackageName("javax.xml.bind"), 0) {
+		t.Errorf("IsStdlibForRelease() without a release should match IsStdlib")
+	}
+}
+
+func TestIsJDKInternal(t *testing.T) {
+	tests := map[string]bool{
+		"sun.misc":                               true,
+		"jdk.internal.misc":                      true,
+		"com.sun.org.apache.xerces.internal.dom": true,
+		"com.sun.net.httpserver":                 false,
+		"jdk.jfr":                                false,
+		"java.util":                              false,
+		"sunny.day":                              false,
+	}
+
+	for pkg, want := range tests {
+		t.Run(pkg, func(t *testing.T) {
+			if got := IsJDKInternal(types.NewPackageName(pkg)); got != want {
+				t.Errorf("IsJDKInternal() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestIsPlatformModule(t *testing.T) {
+	tests := map[string]bool{
+		"java.base":        true,
+		"java.sql":         true,
+		"jdk.httpserver":   true,
+		"javafx.base":      false,
+		"jdkx":             false,
+		"com.google.guava": false,
+	}
+
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := IsPlatformModule(name); got != want {
+				t.Errorf("IsPlatformModule() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFileDataImports(t *testing.T) {
+	imports := FileData{
+		ImportedClasses: sorted_set.NewSortedSetFn([]types.ClassName{
+			types.NewClassName(types.NewPackageName("com.example.a"), "A"),
+			types.NewClassName(types.NewPackageName("com.example.b"), "B"),
+		}, types.ClassNameLess),
+		ImportedPackagesWithoutSpecificClasses: sorted_set.NewSortedSetFn([]types.PackageName{
+			types.NewPackageName("com.example.c"),
+		}, types.PackageNameLess),
+	}
+	for name, want := range map[string]bool{
+		"com.example.a.A":     true,
+		"com.example.a":       true,
+		"com.example.c":       true,
+		"com.example.c.C":     false,
+		"com.example.a.AB":    false,
+		"com.example.ab":      false,
+		"com.example":         false,
+		"com.example.d.D":     false,
+		"com.example.b.B.Sub": false,
+	} {
+		if got := imports.Imports(name); got != want {
+			t.Errorf("Imports(%q): want %v, got %v", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package java
+
+import (
+	"strings"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_multiset"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+)
+
+// ModuleInfoFile is the name of the file declaring a JPMS module.
+const ModuleInfoFile = "module-info.java"
+
+// Module is a JPMS module, as declared by a module-info.java file.
+type Module struct {
+	Name string
+	// Open is whether the module is declared `open`, opening all its packages to reflection.
+	Open bool
+
+	// Requires are the names of the required modules, including the transitive and static ones.
+	Requires *sorted_set.SortedSet[string]
+	// RequiresTransitive are the names of the modules required with `requires transitive`.
+	RequiresTransitive *sorted_set.SortedSet[string]
+	// RequiresStatic are the names of the modules required with `requires static`.
+	RequiresStatic *sorted_set.SortedSet[string]
+
+	// Exports are the exported packages, whether to all modules or to some.
+	Exports *sorted_set.SortedSet[types.PackageName]
+	// Opens are the packages opened to reflection, whether to all modules or to some.
+	Opens *sorted_set.SortedSet[types.PackageName]
+
+	// Uses are the services the module loads with a ServiceLoader.
+	Uses *sorted_set.SortedSet[types.ClassName]
+	// Provides maps the fully qualified names of the services the module provides to their
+	// implementations.
+	Provides *sorted_multiset.SortedMultiSet[string, types.ClassName]
+}
+
+// IsPlatformModule returns whether name is a module of the Java platform, such as java.sql
+// or jdk.httpserver, which needs no dependency.
+func IsPlatformModule(name string) bool {
+	return strings.HasPrefix(name, "java.") || strings.HasPrefix(name, "jdk.")
+}
@@ -1,6 +1,8 @@
 package java
 
 import (
+	"strings"
+
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_multiset"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
@@ -16,6 +18,25 @@ type Package struct {
 	ImportedPackagesWithoutSpecificClasses *sorted_set.SortedSet[types.PackageName]
 	Mains                                  *sorted_set.SortedSet[types.ClassName]
 
+	// GeneratedClasses are the classes annotation processors generate for the classes of the
+	// package, as configured with java_annotation_processor_generated_class.
+	GeneratedClasses *sorted_set.SortedSet[types.ClassName]
+
+	// Module is the module declared by a module-info.java file among the files, if any.
+	Module *Module
+
+	// AnnotationProcessors maps the fully qualified names of the annotation processors declared
+	// in the package to the annotation types they declare to support with
+	// @SupportedAnnotationTypes.
+	AnnotationProcessors map[string]*sorted_set.SortedSet[string]
+
+	// LoadedServices are the services the package loads with ServiceLoader.load(Service.class).
+	LoadedServices *sorted_set.SortedSet[types.ClassName]
+
+	// PerFileData maps each of the files, relative to the directory they were parsed from, to
+	// its part of the package.
+	PerFileData map[string]FileData
+
 	// Especially useful for module mode
 	Files       *sorted_set.SortedSet[string]
 	TestPackage bool
@@ -37,6 +58,31 @@ func (p *Package) AllAnnotations() *sorted_set.SortedSet[types.ClassName] {
 	return annotations
 }
 
+// FileData is the part of a Package parsed from one of its files.
+type FileData struct {
+	ImportedClasses                        *sorted_set.SortedSet[types.ClassName]
+	ImportedPackagesWithoutSpecificClasses *sorted_set.SortedSet[types.PackageName]
+	ExportedClasses                        *sorted_set.SortedSet[types.ClassName]
+	InternalClasses                        *sorted_set.SortedSet[types.ClassName]
+	DeclaredClasses                        *sorted_set.SortedSet[types.ClassName]
+}
+
+// Imports returns whether the file imports name, either a class, or a class nested in it, or a
+// package, of which it imports some class or every class.
+func (f FileData) Imports(name string) bool {
+	if f.ImportedPackagesWithoutSpecificClasses.Contains(types.NewPackageName(name)) {
+		return true
+	}
+	for _, class := range f.ImportedClasses.SortedSlice() {
+		pkg := class.PackageName().Name
+		fqcn := class.FullyQualifiedClassName()
+		if pkg == name || fqcn == name || (len(name) > len(pkg) && strings.HasPrefix(fqcn, name+".")) {
+			return true
+		}
+	}
+	return false
+}
+
 type PerClassMetadata struct {
 	AnnotationClassNames       *sorted_set.SortedSet[types.ClassName]
 	MethodAnnotationClassNames *sorted_multiset.SortedMultiSet[string, types.ClassName]
@@ -3,6 +3,7 @@ package javaparser
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"time"
 
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
@@ -147,6 +148,59 @@ func (r Runner) ParsePackage(ctx context.Context, in *ParsePackageRequest) (*jav
 		mains.Add(types.NewClassName(packageName, main))
 	}
 
+	var module *java.Module
+	if m := resp.GetModule(); m != nil {
+		if module, err = convertModule(m); err != nil {
+			return nil, err
+		}
+	}
+
+	annotationProcessors := make(map[string]*sorted_set.SortedSet[string])
+	for processor, metadata := range resp.GetAnnotationProcessors() {
+		if _, err := types.ParseClassName(processor); err != nil {
+			return nil, fmt.Errorf("failed to parse annotation processor %q: %w", processor, err)
+		}
+		annotationProcessors[processor] = sorted_set.NewSortedSet(metadata.GetSupportedAnnotationTypes())
+	}
+
+	loadedServices := sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess)
+	for _, service := range resp.GetLoadedServices() {
+		className, err := types.ParseClassName(service)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse loaded service %q: %w", service, err)
+		}
+		loadedServices.Add(*className)
+	}
+
+	perFileData := make(map[string]java.FileData, len(in.Files))
+	for _, file := range in.Files {
+		perFileData[file] = newFileData()
+	}
+	for file, fileResp := range resp.GetPerFileData() {
+		data := newFileData()
+		for _, c := range []struct {
+			names []string
+			set   *sorted_set.SortedSet[types.ClassName]
+		}{
+			{fileResp.GetImportedClasses(), data.ImportedClasses},
+			{fileResp.GetExportedClasses(), data.ExportedClasses},
+			{fileResp.GetInternalClasses(), data.InternalClasses},
+			{fileResp.GetDeclaredClasses(), data.DeclaredClasses},
+		} {
+			for _, name := range c.names {
+				className, err := types.ParseClassName(name)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse class %q of %s: %w", name, file, err)
+				}
+				c.set.Add(*className)
+			}
+		}
+		for _, pkg := range fileResp.GetImportedPackagesWithoutSpecificClasses() {
+			data.ImportedPackagesWithoutSpecificClasses.Add(types.NewPackageName(pkg))
+		}
+		perFileData[filepath.ToSlash(file)] = data
+	}
+
 	return &java.Package{
 		Name:                                   packageName,
 		ImportedClasses:                        importedClasses,
@@ -155,8 +209,68 @@ func (r Runner) ParsePackage(ctx context.Context, in *ParsePackageRequest) (*jav
 		DeclaredClasses:                        declaredClasses,
 		ImportedPackagesWithoutSpecificClasses: importedPackages,
 		Mains:                                  mains,
+		Module:                                 module,
+		AnnotationProcessors:                   annotationProcessors,
+		LoadedServices:                         loadedServices,
+		PerFileData:                            perFileData,
 		Files:                                  sorted_set.NewSortedSet(in.Files),
 		TestPackage:                            java.IsTestPackage(in.Rel),
 		PerClassMetadata:                       perClassMetadata,
 	}, nil
 }
+
+func newFileData() java.FileData {
+	return java.FileData{
+		ImportedClasses:                        sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess),
+		ImportedPackagesWithoutSpecificClasses: sorted_set.NewSortedSetFn([]types.PackageName{}, types.PackageNameLess),
+		ExportedClasses:                        sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess),
+		InternalClasses:                        sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess),
+		DeclaredClasses:                        sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess),
+	}
+}
+
+func convertModule(m *pb.ModuleDeclaration) (*java.Module, error) {
+	module := &java.Module{
+		Name:               m.GetName(),
+		Open:               m.GetOpen(),
+		Requires:           sorted_set.NewSortedSet([]string{}),
+		RequiresTransitive: sorted_set.NewSortedSet([]string{}),
+		RequiresStatic:     sorted_set.NewSortedSet([]string{}),
+		Exports:            sorted_set.NewSortedSetFn([]types.PackageName{}, types.PackageNameLess),
+		Opens:              sorted_set.NewSortedSetFn([]types.PackageName{}, types.PackageNameLess),
+		Uses:               sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess),
+		Provides:           sorted_multiset.NewSortedMultiSetFn[string, types.ClassName](types.ClassNameLess),
+	}
+	for _, requires := range m.GetRequires() {
+		module.Requires.Add(requires.GetName())
+		if requires.GetIsTransitive() {
+			module.RequiresTransitive.Add(requires.GetName())
+		}
+		if requires.GetIsStatic() {
+			module.RequiresStatic.Add(requires.GetName())
+		}
+	}
+	for _, pkg := range m.GetExports() {
+		module.Exports.Add(types.NewPackageName(pkg))
+	}
+	for _, pkg := range m.GetOpens() {
+		module.Opens.Add(types.NewPackageName(pkg))
+	}
+	for _, service := range m.GetUses() {
+		className, err := types.ParseClassName(service)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse used service %q of module %s: %w", service, m.GetName(), err)
+		}
+		module.Uses.Add(*className)
+	}
+	for _, provides := range m.GetProvides() {
+		for _, implementation := range provides.GetImplementations() {
+			className, err := types.ParseClassName(implementation)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse implementation %q of service %s in module %s: %w", implementation, provides.GetService(), m.GetName(), err)
+			}
+			module.Provides.Add(provides.GetService(), *className)
+		}
+	}
+	return module, nil
+}
@@ -11,6 +11,9 @@ type lockFile interface {
 	GetDependencyCoordinates(name string) string
 	ListDependencyPackages(name string) []string
 	ListDependencyClasses(name string) []string
+	// ListDependencyDependencies returns the direct dependencies of name, in the same
+	// form as ListDependencies.
+	ListDependencyDependencies(name string) []string
 }
 
 type versionnedConfigFile struct {
@@ -67,6 +70,15 @@ func (f *lockFileV1) ListDependencyClasses(name string) []string {
 	return nil
 }
 
+func (f *lockFileV1) ListDependencyDependencies(name string) []string {
+	for _, dep := range f.DependencyTree.Dependencies {
+		if dep.Coord == name {
+			return dep.DirectDependencies
+		}
+	}
+	return nil
+}
+
 type lockFileV2 struct {
 	AutogeneratedFileDoNotModifyThisFileManually string                         `json:"__AUTOGENERATED_FILE_DO_NOT_MODIFY_THIS_FILE_MANUALLY"`
 	InputArtifactsHash                           json.RawMessage                `json:"__INPUT_ARTIFACTS_HASH"`
@@ -98,15 +110,29 @@ func (f *lockFileV2) ListDependencyClasses(name string) []string {
 	return nil
 }
 
+func (f *lockFileV2) ListDependencyDependencies(name string) []string {
+	return f.Dependencies[name]
+}
+
 type lockFileV2_Artifact struct {
 	Shasums map[string]string `json:"shasums"`
 	Version string            `json:"version"`
 }
 
 type IndexFile struct {
-	Version  int                            `json:"version"`
-	Classes  map[string]map[string][]string `json:"split_package_classes"`
-	Packages map[string][]string            `json:"packages"`
+	Version              int                                  `json:"version"`
+	Classes              map[string]map[string][]string       `json:"split_package_classes"`
+	Packages             map[string][]string                  `json:"packages"`
+	AnnotationProcessors map[string]IndexAnnotationProcessors `json:"annotation_processors"`
+}
+
+// IndexAnnotationProcessors describes the annotation processors an artifact registers
+// in META-INF/services/javax.annotation.processing.Processor.
+type IndexAnnotationProcessors struct {
+	// Processors are the fully qualified processor classes listed in the service file.
+	Processors []string `json:"processors"`
+	// AnnotationPackages are the packages of the annotations those processors handle.
+	AnnotationPackages []string `json:"annotation_packages"`
 }
 
 func loadIndex(filename string) (*IndexFile, error) {
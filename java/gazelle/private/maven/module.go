@@ -0,0 +1,178 @@
+package maven
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+// DefaultRepositoryName is the repository name rules_jvm_external uses for a
+// maven.install tag without a name.
+const DefaultRepositoryName = "maven"
+
+// Install describes the maven.install tags of the rules_jvm_external module extension
+// which share one repository name, as declared in MODULE.bazel.
+type Install struct {
+	// Name is the name of the repository holding the artifacts.
+	Name string
+	// LockFile is the path of the lock file relative to the repository root, or "" if
+	// the install is not pinned or its lock file lives in another repository.
+	LockFile string
+}
+
+// ParseModuleInstalls returns the maven.install tags declared in the MODULE.bazel file
+// at repoRoot, and in the files it includes, sorted by name. Tags sharing a name are
+// merged, as rules_jvm_external does. It returns nil if there is no MODULE.bazel file.
+func ParseModuleInstalls(repoRoot string) ([]Install, error) {
+	byName := make(map[string]*Install)
+	if err := parseModuleFile(repoRoot, "MODULE.bazel", byName, make(map[string]bool)); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var installs []Install
+	for _, install := range byName {
+		installs = append(installs, *install)
+	}
+	sort.Slice(installs, func(i, j int) bool { return installs[i].Name < installs[j].Name })
+	return installs, nil
+}
+
+// FindInstall returns the install named name.
+func FindInstall(installs []Install, name string) (Install, bool) {
+	for _, install := range installs {
+		if install.Name == name {
+			return install, true
+		}
+	}
+	return Install{}, false
+}
+
+func parseModuleFile(repoRoot, rel string, byName map[string]*Install, seen map[string]bool) error {
+	if seen[rel] {
+		return nil
+	}
+	seen[rel] = true
+
+	filename := filepath.Join(repoRoot, filepath.FromSlash(rel))
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	f, err := bzl.ParseModule(filename, data)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	// Names bound to the rules_jvm_external maven extension. Each file has its own
+	// scope, so bindings don't cross include() boundaries.
+	extensions := make(map[string]bool)
+	for _, stmt := range f.Stmt {
+		switch stmt := stmt.(type) {
+		case *bzl.AssignExpr:
+			lhs, ok := stmt.LHS.(*bzl.Ident)
+			if !ok {
+				continue
+			}
+			if call, ok := stmt.RHS.(*bzl.CallExpr); ok && isMavenExtension(call) {
+				extensions[lhs.Name] = true
+			}
+
+		case *bzl.CallExpr:
+			if ident, ok := stmt.X.(*bzl.Ident); ok && ident.Name == "include" && len(stmt.List) == 1 {
+				if included, ok := mainRepoPath(stringValue(stmt.List[0])); ok {
+					if err := parseModuleFile(repoRoot, included, byName, seen); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			dot, ok := stmt.X.(*bzl.DotExpr)
+			if !ok || dot.Name != "install" {
+				continue
+			}
+			if ident, ok := dot.X.(*bzl.Ident); !ok || !extensions[ident.Name] {
+				continue
+			}
+			recordInstall(stmt, byName)
+		}
+	}
+	return nil
+}
+
+func isMavenExtension(call *bzl.CallExpr) bool {
+	ident, ok := call.X.(*bzl.Ident)
+	if !ok || ident.Name != "use_extension" || len(call.List) < 2 {
+		return false
+	}
+	bzlFile := stringValue(call.List[0])
+	return strings.Contains(bzlFile, "rules_jvm_external") &&
+		strings.HasSuffix(bzlFile, ":extensions.bzl") &&
+		stringValue(call.List[1]) == "maven"
+}
+
+func recordInstall(call *bzl.CallExpr, byName map[string]*Install) {
+	name := DefaultRepositoryName
+	var lockFile string
+	for _, arg := range call.List {
+		assign, ok := arg.(*bzl.AssignExpr)
+		if !ok {
+			continue
+		}
+		key, ok := assign.LHS.(*bzl.Ident)
+		if !ok {
+			continue
+		}
+		switch key.Name {
+		case "name":
+			if v := stringValue(assign.RHS); v != "" {
+				name = v
+			}
+		case "lock_file":
+			lockFile, _ = mainRepoPath(stringValue(assign.RHS))
+		}
+	}
+
+	install, ok := byName[name]
+	if !ok {
+		install = &Install{Name: name}
+		byName[name] = install
+	}
+	if lockFile != "" {
+		install.LockFile = lockFile
+	}
+}
+
+func stringValue(expr bzl.Expr) string {
+	if s, ok := expr.(*bzl.StringExpr); ok {
+		return s.Value
+	}
+	return ""
+}
+
+// mainRepoPath converts a label of a file in the main repository to a slash-separated
+// path relative to the repository root. Labels in other repositories are rejected, as
+// their files are not in the source tree.
+func mainRepoPath(l string) (string, bool) {
+	if l == "" {
+		return "", false
+	}
+	l = strings.TrimPrefix(strings.TrimPrefix(l, "@@"), "@")
+	if strings.HasPrefix(l, "//") {
+		l = strings.TrimPrefix(l, "//")
+	} else if strings.Contains(l, "//") {
+		return "", false
+	}
+	pkg, name, found := strings.Cut(l, ":")
+	if !found {
+		return pkg, true
+	}
+	return path.Join(pkg, name), true
+}
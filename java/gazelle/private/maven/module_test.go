@@ -0,0 +1,29 @@
+package maven
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseModuleInstalls(t *testing.T) {
+	installs, err := ParseModuleInstalls("testdata/module")
+	require.NoError(t, err)
+	require.Equal(t, []Install{
+		{Name: "external"},
+		{Name: "maven", LockFile: "maven_install.json"},
+		{Name: "tools", LockFile: "third_party/tools_install.json"},
+	}, installs)
+
+	install, found := FindInstall(installs, "tools")
+	require.True(t, found)
+	require.Equal(t, "tools", install.Name)
+	_, found = FindInstall(installs, "not_rules_jvm_external")
+	require.False(t, found)
+}
+
+func TestParseModuleInstallsWithoutModuleFile(t *testing.T) {
+	installs, err := ParseModuleInstalls(t.TempDir())
+	require.NoError(t, err)
+	require.Nil(t, installs)
+}
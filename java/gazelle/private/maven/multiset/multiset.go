@@ -36,3 +36,19 @@ func (m *StringMultiSet) Get(key string) (map[string]struct{}, bool) {
 	v, ok := m.data[key]
 	return v, ok
 }
+
+// Entries returns a copy of every key and its values.
+func (m *StringMultiSet) Entries() map[string]map[string]struct{} {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	out := make(map[string]map[string]struct{}, len(m.data))
+	for key, values := range m.data {
+		copied := newStringSet()
+		for v := range values {
+			copied[v] = struct{}{}
+		}
+		out[key] = copied
+	}
+	return out
+}
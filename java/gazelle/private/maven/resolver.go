@@ -32,9 +32,37 @@ func (e *MultipleExternalImportsError) Error() string {
 	return fmt.Sprintf("multiple external imports found for %s; %v", e.PackageName, e.PossiblePackages)
 }
 
+// NotPinnedError represents the error when an artifact is not pinned in the lock file.
+type NotPinnedError struct {
+	Artifact string
+}
+
+func (e *NotPinnedError) Error() string {
+	return fmt.Sprintf("artifact %s is not pinned in the lock file", e.Artifact)
+}
+
 type Resolver interface {
 	Resolve(pkg types.PackageName, excludedArtifacts map[string]struct{}, mavenRepositoryName string) (label.Label, error)
 	ResolveClass(className types.ClassName, excludedArtifacts map[string]struct{}, mavenRepositoryName string) (label.Label, error)
+	// AnnotationProcessors returns the annotation processors that the index records as
+	// handling annotations in the package of annotationClass, sorted by class name.
+	AnnotationProcessors(annotationClass types.ClassName) []types.ClassName
+	// ClassesWithSimpleName returns the fully qualified names of the indexed classes whose
+	// simple name is simpleName, sorted.
+	ClassesWithSimpleName(simpleName string) []string
+	// ResolveArtifact returns the label of a pinned artifact, given as a versionless
+	// coordinate, or label.NoLabel if it is excluded.
+	ResolveArtifact(artifact string, excludedArtifacts map[string]struct{}, mavenRepositoryName string) (label.Label, error)
+	// PinnedArtifacts returns every artifact pinned in the lock file, sorted.
+	PinnedArtifacts() []PinnedArtifact
+}
+
+// PinnedArtifact is an artifact pinned in the lock file.
+type PinnedArtifact struct {
+	// Artifact is the value passed to the `artifact()` macro, e.g. "com.google.guava:guava".
+	Artifact string
+	// Dependencies are the artifacts this one directly depends on, sorted.
+	Dependencies []string
 }
 
 // resolver finds Maven provided packages by reading the maven_install.json
@@ -42,16 +70,27 @@ type Resolver interface {
 type resolver struct {
 	data       *multiset.StringMultiSet
 	classIndex map[string]string
-	logger     zerolog.Logger
+	// annotationProcessors maps an annotation package to the processor classes which
+	// handle it.
+	annotationProcessors *multiset.StringMultiSet
+	// dependencies maps each pinned artifact to its direct dependencies.
+	dependencies map[string]map[string]struct{}
+	// snapshot, when loaded, replaces the maps above.
+	snapshot *snapshot
+	// simpleNames maps the simple name of each indexed class to its fully qualified names.
+	// It is only built once needed, as it is only used to report unresolved imports.
+	simpleNames map[string][]string
+	logger      zerolog.Logger
 }
 
 // ResolverOption configures a resolver.
 type ResolverOption func(*resolverConfig)
 
 type resolverConfig struct {
-	installFile string
-	indexFile   string
-	logger      zerolog.Logger
+	installFile  string
+	indexFile    string
+	snapshotFile string
+	logger       zerolog.Logger
 }
 
 // WithInstallFile sets the path to the maven_install.json lock file.
@@ -68,6 +107,15 @@ func WithIndexFile(path string) ResolverOption {
 	}
 }
 
+// WithSnapshotFile sets the path of a binary snapshot of the resolver's tables. If the
+// snapshot was built from the current lock file and index file it is loaded instead of
+// parsing them; otherwise it is rebuilt.
+func WithSnapshotFile(path string) ResolverOption {
+	return func(c *resolverConfig) {
+		c.snapshotFile = path
+	}
+}
+
 // WithLogger sets the logger for the resolver.
 func WithLogger(logger zerolog.Logger) ResolverOption {
 	return func(c *resolverConfig) {
@@ -83,12 +131,52 @@ func NewResolver(opts ...ResolverOption) (Resolver, error) {
 		opt(cfg)
 	}
 
-	r := resolver{
-		data:       multiset.NewStringMultiSet(),
-		classIndex: make(map[string]string),
-		logger:     cfg.logger.With().Str("_c", "maven-resolver").Logger(),
+	r := &resolver{
+		data:                 multiset.NewStringMultiSet(),
+		classIndex:           make(map[string]string),
+		annotationProcessors: multiset.NewStringMultiSet(),
+		dependencies:         make(map[string]map[string]struct{}),
+		logger:               cfg.logger.With().Str("_c", "maven-resolver").Logger(),
 	}
 
+	if cfg.snapshotFile == "" || cfg.installFile == "" {
+		if _, err := r.seed(cfg); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+
+	key, err := computeSnapshotKey(cfg.installFile, cfg.indexFile)
+	if err != nil {
+		// Let seeding report the unreadable input.
+		if _, err := r.seed(cfg); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	if s, err := loadSnapshot(cfg.snapshotFile, key); err == nil {
+		r.logger.Debug().Str("snapshot", cfg.snapshotFile).Msg("loaded maven resolver snapshot")
+		r.snapshot = s
+		return r, nil
+	} else if !os.IsNotExist(err) {
+		r.logger.Debug().Err(err).Str("snapshot", cfg.snapshotFile).Msg("rebuilding maven resolver snapshot")
+	}
+
+	seeded, err := r.seed(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if seeded {
+		if err := writeSnapshot(cfg.snapshotFile, key, r); err != nil {
+			r.logger.Warn().Err(err).Str("snapshot", cfg.snapshotFile).Msg("failed to write maven resolver snapshot")
+		}
+	}
+	return r, nil
+}
+
+// seed populates the resolver's maps from the lock file and index file. It reports
+// whether both could be loaded, in which case the result may be snapshotted.
+func (r *resolver) seed(cfg *resolverConfig) (bool, error) {
 	var c lockFile
 	var lockFileErr error
 	if cfg.installFile != "" {
@@ -112,12 +200,12 @@ func NewResolver(opts ...ResolverOption) (Resolver, error) {
 			} else {
 				r.logger.Warn().Msg("no maven install file or index file configured")
 			}
-			return &r, nil
+			return false, nil
 		}
 		// We have an index but no lock file - we can't proceed without the lock file
 		// since we need it for dependency coordinates
 		r.logger.Warn().Msg("index file available but lock file required for dependency coordinates")
-		return &r, nil
+		return false, nil
 	}
 
 	dependencies := c.ListDependencies()
@@ -129,7 +217,7 @@ func NewResolver(opts ...ResolverOption) (Resolver, error) {
 	for _, depName := range dependencies {
 		coords, err := ParseCoordinate(c.GetDependencyCoordinates(depName))
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse coordinate %v: %w", coords, err)
+			return false, fmt.Errorf("failed to parse coordinate %v: %w", coords, err)
 		}
 		for _, pkg := range c.ListDependencyPackages(depName) {
 			r.data.Add(pkg, coords.ArtifactString())
@@ -137,6 +225,15 @@ func NewResolver(opts ...ResolverOption) (Resolver, error) {
 		for _, class := range c.ListDependencyClasses(depName) {
 			r.classIndex[class] = coords.ArtifactString()
 		}
+		deps := make(map[string]struct{})
+		for _, dep := range c.ListDependencyDependencies(depName) {
+			depCoords, err := ParseCoordinate(c.GetDependencyCoordinates(dep))
+			if err != nil {
+				return false, fmt.Errorf("failed to parse coordinate %v: %w", dep, err)
+			}
+			deps[depCoords.ArtifactString()] = struct{}{}
+		}
+		r.dependencies[coords.ArtifactString()] = deps
 	}
 
 	// Seed package and class data from the index. Every index key is an artifact's
@@ -156,19 +253,23 @@ func NewResolver(opts ...ResolverOption) (Resolver, error) {
 		}
 	}
 
-	return &r, nil
+	// A snapshot must not hide a broken index file on later runs.
+	return indexFileErr == nil || os.IsNotExist(indexFileErr), nil
 }
 
 // indexKeys returns the union of the index's package and class keys, ordered
 // most-qualified first so plain coordinates seed last.
 func indexKeys(index *IndexFile) []string {
-	seen := make(map[string]struct{}, len(index.Classes)+len(index.Packages))
+	seen := make(map[string]struct{}, len(index.Classes)+len(index.Packages)+len(index.AnnotationProcessors))
 	for key := range index.Classes {
 		seen[key] = struct{}{}
 	}
 	for key := range index.Packages {
 		seen[key] = struct{}{}
 	}
+	for key := range index.AnnotationProcessors {
+		seen[key] = struct{}{}
+	}
 	keys := make([]string, 0, len(seen))
 	for key := range seen {
 		keys = append(keys, key)
@@ -209,16 +310,27 @@ func (r *resolver) seedIndexKey(index *IndexFile, key, artifactString string) {
 			r.data.Add(pkg, artifactString)
 		}
 	}
+	// Use annotation_processors section to discover the processors an artifact ships.
+	// Processor classes are also recorded in the class index so that the plugin can be
+	// attributed to this artifact even when its package is split.
+	if processors, ok := index.AnnotationProcessors[key]; ok {
+		for _, processor := range processors.Processors {
+			r.classIndex[processor] = artifactString
+			for _, pkg := range processors.AnnotationPackages {
+				r.annotationProcessors.Add(pkg, processor)
+			}
+		}
+	}
 }
 
 func (r *resolver) Resolve(pkg types.PackageName, excludedArtifacts map[string]struct{}, mavenRepositoryName string) (label.Label, error) {
-	v, found := r.data.Get(pkg.Name)
-	if !found {
+	v := r.packageArtifacts(pkg.Name)
+	if len(v) == 0 {
 		return label.NoLabel, &NoExternalImportsError{PackageName: pkg.Name}
 	}
 
 	var filtered []string
-	for k := range v {
+	for _, k := range v {
 		if _, excluded := excludedArtifacts[LabelFromArtifact(mavenRepositoryName, k).String()]; excluded {
 			continue
 		}
@@ -247,12 +359,12 @@ func (r *resolver) Resolve(pkg types.PackageName, excludedArtifacts map[string]s
 }
 
 func (r *resolver) ResolveClass(className types.ClassName, excludedArtifacts map[string]struct{}, mavenRepositoryName string) (label.Label, error) {
-	artifact, found := r.classIndex[className.FullyQualifiedClassName()]
+	artifact, found := r.classArtifact(className.FullyQualifiedClassName())
 	if !found {
 		// rules_jvm_external intentionally indexes only top-level classes. A named nested
 		// class is compiled into the same artifact as its outer class, so use the indexed
 		// outer class as its owner when the full source-level name has no entry.
-		artifact, found = r.classIndex[className.FullyQualifiedOuterClassName()]
+		artifact, found = r.classArtifact(className.FullyQualifiedOuterClassName())
 	}
 	if !found {
 		return label.NoLabel, nil
@@ -265,6 +377,103 @@ func (r *resolver) ResolveClass(className types.ClassName, excludedArtifacts map
 	return LabelFromArtifact(mavenRepositoryName, artifact), nil
 }
 
+func (r *resolver) ClassesWithSimpleName(simpleName string) []string {
+	if r.simpleNames == nil {
+		r.simpleNames = make(map[string][]string)
+		r.forEachClass(func(fqcn string) {
+			name := fqcn[strings.LastIndex(fqcn, ".")+1:]
+			r.simpleNames[name] = append(r.simpleNames[name], fqcn)
+		})
+		for _, classes := range r.simpleNames {
+			sort.Strings(classes)
+		}
+	}
+	return r.simpleNames[simpleName]
+}
+
+func (r *resolver) forEachClass(f func(fqcn string)) {
+	if r.snapshot != nil {
+		r.snapshot.forEachClass(f)
+		return
+	}
+	for fqcn := range r.classIndex {
+		f(fqcn)
+	}
+}
+
+func (r *resolver) ResolveArtifact(artifact string, excludedArtifacts map[string]struct{}, mavenRepositoryName string) (label.Label, error) {
+	if !r.isPinned(artifact) {
+		return label.NoLabel, &NotPinnedError{Artifact: artifact}
+	}
+
+	l := LabelFromArtifact(mavenRepositoryName, artifact)
+	if _, excluded := excludedArtifacts[l.String()]; excluded {
+		return label.NoLabel, nil
+	}
+	return l, nil
+}
+
+func (r *resolver) AnnotationProcessors(annotationClass types.ClassName) []types.ClassName {
+	var out []types.ClassName
+	for _, processor := range r.annotationProcessorsForPackage(annotationClass.PackageName().Name) {
+		className, err := types.ParseClassName(processor)
+		if err != nil {
+			r.logger.Warn().Err(err).Str("processor", processor).Msg("ignoring malformed annotation processor class name in maven index")
+			continue
+		}
+		out = append(out, *className)
+	}
+	return out
+}
+
+// packageArtifacts returns the artifacts providing pkg, sorted.
+func (r *resolver) packageArtifacts(pkg string) []string {
+	if r.snapshot != nil {
+		return r.snapshot.packageArtifacts(pkg)
+	}
+	v, _ := r.data.Get(pkg)
+	return sortedKeys(v)
+}
+
+func (r *resolver) classArtifact(fqcn string) (string, bool) {
+	if r.snapshot != nil {
+		return r.snapshot.classArtifact(fqcn)
+	}
+	artifact, found := r.classIndex[fqcn]
+	return artifact, found
+}
+
+// annotationProcessorsForPackage returns the processors handling annotations in pkg, sorted.
+func (r *resolver) annotationProcessorsForPackage(pkg string) []string {
+	if r.snapshot != nil {
+		return r.snapshot.annotationProcessors(pkg)
+	}
+	v, _ := r.annotationProcessors.Get(pkg)
+	return sortedKeys(v)
+}
+
+func (r *resolver) isPinned(artifact string) bool {
+	if r.snapshot != nil {
+		return r.snapshot.isPinned(artifact)
+	}
+	_, found := r.dependencies[artifact]
+	return found
+}
+
+func (r *resolver) PinnedArtifacts() []PinnedArtifact {
+	if r.snapshot != nil {
+		return r.snapshot.pinnedArtifacts()
+	}
+	out := make([]PinnedArtifact, 0, len(r.dependencies))
+	for _, artifact := range sortedKeys(r.dependencies) {
+		out = append(out, PinnedArtifact{
+			Artifact:     artifact,
+			Dependencies: sortedKeys(r.dependencies[artifact]),
+		})
+	}
+	return out
+}
+
 func LabelFromArtifact(mavenRepositoryName string, artifact string) label.Label {
 	return label.New(mavenRepositoryName, "", bazel.CleanupLabel(artifact))
 }
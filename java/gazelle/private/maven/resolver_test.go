@@ -2,6 +2,8 @@ package maven
 
 import (
 	"os"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
@@ -83,6 +85,23 @@ func TestResolverClassifier(t *testing.T) {
 		"@maven//:com_example_lib")
 }
 
+func TestResolverClassesWithSimpleName(t *testing.T) {
+	r, err := NewResolver(
+		WithInstallFile("testdata/classifier_maven_install.json"),
+		WithIndexFile("testdata/classifier_maven_index.json"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := r.ClassesWithSimpleName("SharedHelper"), []string{"com.example.fixtures.SharedHelper"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("Incorrect classes; want %v got %v", want, got)
+	}
+	if got := r.ClassesWithSimpleName("Missing"); len(got) != 0 {
+		t.Errorf("Expected no classes, got %v", got)
+	}
+}
+
 func assertResolvesClass(t *testing.T, r Resolver, excludeArtifacts map[string]struct{}, className, wantLabelStr string) {
 	t.Helper()
 	cn, err := types.ParseClassName(className)
@@ -98,3 +117,110 @@ func assertResolvesClass(t *testing.T, r Resolver, excludeArtifacts map[string]s
 		t.Errorf("Incorrect label for class %v; want %v got %v", className, want, got)
 	}
 }
+
+// TestResolverAnnotationProcessors checks that processors recorded in the index's
+// annotation_processors section are discovered from the annotations they handle, and
+// that each processor class resolves to the artifact which ships it.
+func TestResolverAnnotationProcessors(t *testing.T) {
+	r, err := NewResolver(
+		WithInstallFile("testdata/annotation_processor_maven_install.json"),
+		WithIndexFile("testdata/annotation_processor_maven_index.json"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	none := make(map[string]struct{})
+
+	annotation, err := types.ParseClassName("com.google.auto.value.AutoValue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, processor := range r.AnnotationProcessors(*annotation) {
+		got = append(got, processor.FullyQualifiedClassName())
+	}
+	want := []string{
+		"com.google.auto.value.extension.memoized.processor.MemoizedValidator",
+		"com.google.auto.value.processor.AutoAnnotationProcessor",
+		"com.google.auto.value.processor.AutoValueProcessor",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect processors for %v; want %v got %v", annotation.FullyQualifiedClassName(), want, got)
+	}
+
+	unrelated, err := types.ParseClassName("com.example.Unrelated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.AnnotationProcessors(*unrelated); len(got) != 0 {
+		t.Errorf("Want no processors for %v, got %v", unrelated.FullyQualifiedClassName(), got)
+	}
+
+	assertResolvesClass(t, r, none, "com.google.auto.value.processor.AutoValueProcessor", "@maven//:com_google_auto_value_auto_value")
+	assertResolves(t, r, none, "com.google.auto.value", "@maven//:com_google_auto_value_auto_value_annotations")
+}
+
+func TestResolverPinnedArtifacts(t *testing.T) {
+	guavaDeps := []string{
+		"com.google.code.findbugs:jsr305",
+		"com.google.errorprone:error_prone_annotations",
+		"com.google.guava:failureaccess",
+		"com.google.guava:listenablefuture",
+		"com.google.j2objc:j2objc-annotations",
+		"org.checkerframework:checker-qual",
+	}
+
+	for _, installFile := range []string{"testdata/v1_maven_install.json", "testdata/v2_maven_install.json"} {
+		t.Run(installFile, func(t *testing.T) {
+			r, err := NewResolver(WithInstallFile(installFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pinned := r.PinnedArtifacts()
+			var artifacts []string
+			for _, p := range pinned {
+				artifacts = append(artifacts, p.Artifact)
+				want := []string{}
+				if p.Artifact == "com.google.guava:guava" {
+					want = guavaDeps
+				}
+				if !reflect.DeepEqual(want, p.Dependencies) {
+					t.Errorf("Incorrect dependencies for %v; want %v got %v", p.Artifact, want, p.Dependencies)
+				}
+			}
+			want := append([]string{"com.google.guava:guava"}, guavaDeps...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(want, artifacts) {
+				t.Errorf("Incorrect pinned artifacts; want %v got %v", want, artifacts)
+			}
+		})
+	}
+}
+
+func TestResolverResolveArtifact(t *testing.T) {
+	r, err := NewResolver(WithInstallFile("testdata/v2_maven_install.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.ResolveArtifact("com.google.guava:failureaccess", map[string]struct{}{}, "maven")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "@maven//:com_google_guava_failureaccess"; got.String() != want {
+		t.Errorf("Incorrect label; want %v got %v", want, got)
+	}
+
+	excluded := map[string]struct{}{"@maven//:com_google_guava_failureaccess": {}}
+	got, err = r.ResolveArtifact("com.google.guava:failureaccess", excluded, "maven")
+	if err != nil || got != label.NoLabel {
+		t.Errorf("Excluded artifact should resolve to no label; got %v, %v", got, err)
+	}
+
+	_, err = r.ResolveArtifact("org.slf4j:slf4j-simple", map[string]struct{}{}, "maven")
+	if _, ok := err.(*NotPinnedError); !ok {
+		t.Errorf("Expected NotPinnedError, got %v", err)
+	}
+}
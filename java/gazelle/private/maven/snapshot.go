@@ -0,0 +1,364 @@
+package maven
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"unsafe"
+)
+
+// A snapshot is a compact binary encoding of a resolver's lookup tables (the package
+// multiset, the class index, the annotation processors and the dependency graph). Loading one is a single
+// file read: lookups binary-search the encoded tables in place and return strings
+// which alias the file's contents, so no maps are rebuilt and no JSON is decoded.
+//
+// A snapshot records a key derived from the contents of the lock file and index file it
+// was built from. A snapshot whose key does not match the current inputs is stale and is
+// rebuilt from the JSON files.
+//
+// Layout, with every integer a little-endian uint32:
+//
+//	magic          [8]byte
+//	version
+//	key            [32]byte
+//	stringCount, stringsLen
+//	packageCount, classCount, processorCount, dependencyCount, valueCount
+//	string refs    stringCount x (offset, length) into the string data
+//	packages       packageCount x (key string, first value, value count), sorted by key
+//	classes        classCount x (key string, value string), sorted by key
+//	processors     processorCount x (key string, first value, value count), sorted by key
+//	dependencies   dependencyCount x (key string, first value, value count), sorted by key
+//	values         valueCount x string
+//	string data    stringsLen bytes
+//
+// Strings are referenced by their index in the string refs.
+const (
+	snapshotMagic   = "RJVMMVN\x00"
+	snapshotVersion = 2
+
+	snapshotHeaderLen = len(snapshotMagic) + 4 + sha256.Size + 7*4
+)
+
+type snapshotKey [sha256.Size]byte
+
+// computeSnapshotKey hashes the inputs a resolver is built from. The index file is
+// optional; its absence is part of the key.
+func computeSnapshotKey(installFile, indexFile string) (snapshotKey, error) {
+	var key snapshotKey
+	h := sha256.New()
+	fmt.Fprintf(h, "%s%d\x00", snapshotMagic, snapshotVersion)
+	if err := hashFile(h, installFile); err != nil {
+		return key, err
+	}
+	if indexFile != "" {
+		if err := hashFile(h, indexFile); err != nil && !os.IsNotExist(err) {
+			return key, err
+		}
+	}
+	copy(key[:], h.Sum(nil))
+	return key, nil
+}
+
+func hashFile(h io.Writer, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		io.WriteString(h, "absent\x00")
+		return err
+	}
+	defer f.Close()
+	inner := sha256.New()
+	if _, err := io.Copy(inner, f); err != nil {
+		return err
+	}
+	_, err = h.Write(inner.Sum(nil))
+	return err
+}
+
+var errStaleSnapshot = errors.New("snapshot is stale")
+
+type snapshot struct {
+	nPackages     int
+	nClasses      int
+	nProcessors   int
+	nDependencies int
+
+	stringRefs   []byte
+	packages     []byte
+	classes      []byte
+	processors   []byte
+	dependencies []byte
+	values       []byte
+	strings      []byte
+}
+
+// loadSnapshot reads the snapshot at filename, returning errStaleSnapshot if it was not
+// built from inputs matching key.
+func loadSnapshot(filename string, key snapshotKey) (*snapshot, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSnapshot(data, key)
+}
+
+func decodeSnapshot(data []byte, key snapshotKey) (*snapshot, error) {
+	if len(data) < snapshotHeaderLen || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, fmt.Errorf("not a maven resolver snapshot")
+	}
+	pos := len(snapshotMagic)
+	next := func() int {
+		v := binary.LittleEndian.Uint32(data[pos:])
+		pos += 4
+		return int(v)
+	}
+	if next() != snapshotVersion {
+		return nil, errStaleSnapshot
+	}
+	if !bytes.Equal(data[pos:pos+sha256.Size], key[:]) {
+		return nil, errStaleSnapshot
+	}
+	pos += sha256.Size
+
+	nStrings, stringsLen := next(), next()
+	s := &snapshot{nPackages: next(), nClasses: next(), nProcessors: next(), nDependencies: next()}
+	nValues := next()
+
+	var truncated bool
+	section := func(n int) []byte {
+		if truncated || n < 0 || n > len(data)-pos {
+			truncated = true
+			return nil
+		}
+		b := data[pos : pos+n]
+		pos += n
+		return b
+	}
+	s.stringRefs = section(8 * nStrings)
+	s.packages = section(12 * s.nPackages)
+	s.classes = section(8 * s.nClasses)
+	s.processors = section(12 * s.nProcessors)
+	s.dependencies = section(12 * s.nDependencies)
+	s.values = section(4 * nValues)
+	s.strings = section(stringsLen)
+	if truncated || pos != len(data) || !s.valid(nStrings, nValues) {
+		return nil, fmt.Errorf("maven resolver snapshot is corrupt")
+	}
+	return s, nil
+}
+
+// valid checks that every reference in the snapshot is in range, so that lookups
+// cannot panic on a corrupt file.
+func (s *snapshot) valid(nStrings, nValues int) bool {
+	for i := 0; i < nStrings; i++ {
+		offset := uint64(binary.LittleEndian.Uint32(s.stringRefs[8*i:]))
+		length := uint64(binary.LittleEndian.Uint32(s.stringRefs[8*i+4:]))
+		if offset+length > uint64(len(s.strings)) {
+			return false
+		}
+	}
+	for i := 0; i < nValues; i++ {
+		if int(binary.LittleEndian.Uint32(s.values[4*i:])) >= nStrings {
+			return false
+		}
+	}
+	for i := 0; i < s.nClasses; i++ {
+		if int(binary.LittleEndian.Uint32(s.classes[8*i:])) >= nStrings || int(binary.LittleEndian.Uint32(s.classes[8*i+4:])) >= nStrings {
+			return false
+		}
+	}
+	for _, table := range []struct {
+		rows []byte
+		n    int
+	}{{s.packages, s.nPackages}, {s.processors, s.nProcessors}, {s.dependencies, s.nDependencies}} {
+		for i := 0; i < table.n; i++ {
+			row := table.rows[12*i:]
+			first := uint64(binary.LittleEndian.Uint32(row[4:]))
+			count := uint64(binary.LittleEndian.Uint32(row[8:]))
+			if int(binary.LittleEndian.Uint32(row)) >= nStrings || first+count > uint64(nValues) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *snapshot) str(i uint32) string {
+	ref := s.stringRefs[8*i:]
+	offset := binary.LittleEndian.Uint32(ref)
+	length := binary.LittleEndian.Uint32(ref[4:])
+	if length == 0 {
+		return ""
+	}
+	// The snapshot's data is never modified, so strings may alias it.
+	return unsafe.String(&s.strings[offset], length)
+}
+
+// search returns the index of key in a table of n sorted rows of width bytes, each
+// starting with its key's string index.
+func (s *snapshot) search(table []byte, n, width int, key string) (int, bool) {
+	i := sort.Search(n, func(i int) bool {
+		return s.str(binary.LittleEndian.Uint32(table[i*width:])) >= key
+	})
+	if i < n && s.str(binary.LittleEndian.Uint32(table[i*width:])) == key {
+		return i, true
+	}
+	return 0, false
+}
+
+func (s *snapshot) multiValues(table []byte, n int, key string) []string {
+	i, found := s.search(table, n, 12, key)
+	if !found {
+		return nil
+	}
+	return s.rowValues(table, i)
+}
+
+func (s *snapshot) rowValues(table []byte, i int) []string {
+	row := table[i*12:]
+	first := binary.LittleEndian.Uint32(row[4:])
+	count := binary.LittleEndian.Uint32(row[8:])
+	out := make([]string, 0, count)
+	for j := first; j < first+count; j++ {
+		out = append(out, s.str(binary.LittleEndian.Uint32(s.values[4*j:])))
+	}
+	return out
+}
+
+func (s *snapshot) packageArtifacts(pkg string) []string {
+	return s.multiValues(s.packages, s.nPackages, pkg)
+}
+
+func (s *snapshot) classArtifact(fqcn string) (string, bool) {
+	i, found := s.search(s.classes, s.nClasses, 8, fqcn)
+	if !found {
+		return "", false
+	}
+	return s.str(binary.LittleEndian.Uint32(s.classes[i*8+4:])), true
+}
+
+func (s *snapshot) forEachClass(f func(fqcn string)) {
+	for i := 0; i < s.nClasses; i++ {
+		f(s.str(binary.LittleEndian.Uint32(s.classes[i*8:])))
+	}
+}
+
+func (s *snapshot) annotationProcessors(pkg string) []string {
+	return s.multiValues(s.processors, s.nProcessors, pkg)
+}
+
+func (s *snapshot) isPinned(artifact string) bool {
+	_, found := s.search(s.dependencies, s.nDependencies, 12, artifact)
+	return found
+}
+
+func (s *snapshot) pinnedArtifacts() []PinnedArtifact {
+	out := make([]PinnedArtifact, 0, s.nDependencies)
+	for i := 0; i < s.nDependencies; i++ {
+		out = append(out, PinnedArtifact{
+			Artifact:     s.str(binary.LittleEndian.Uint32(s.dependencies[i*12:])),
+			Dependencies: s.rowValues(s.dependencies, i),
+		})
+	}
+	return out
+}
+
+// snapshotEncoder accumulates the tables of a snapshot, interning strings.
+type snapshotEncoder struct {
+	stringIndex map[string]uint32
+	stringRefs  []byte
+	strings     []byte
+	values      []byte
+	nValues     uint32
+}
+
+func (e *snapshotEncoder) intern(s string) uint32 {
+	if i, ok := e.stringIndex[s]; ok {
+		return i
+	}
+	i := uint32(len(e.stringIndex))
+	e.stringIndex[s] = i
+	e.stringRefs = binary.LittleEndian.AppendUint32(e.stringRefs, uint32(len(e.strings)))
+	e.stringRefs = binary.LittleEndian.AppendUint32(e.stringRefs, uint32(len(s)))
+	e.strings = append(e.strings, s...)
+	return i
+}
+
+func (e *snapshotEncoder) multiTable(m map[string]map[string]struct{}) []byte {
+	var table []byte
+	for _, key := range sortedKeys(m) {
+		values := sortedKeys(m[key])
+		table = binary.LittleEndian.AppendUint32(table, e.intern(key))
+		table = binary.LittleEndian.AppendUint32(table, e.nValues)
+		table = binary.LittleEndian.AppendUint32(table, uint32(len(values)))
+		for _, v := range values {
+			e.values = binary.LittleEndian.AppendUint32(e.values, e.intern(v))
+			e.nValues++
+		}
+	}
+	return table
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// encodeSnapshot serializes the lookup tables of a resolver seeded from JSON.
+func encodeSnapshot(key snapshotKey, r *resolver) []byte {
+	e := &snapshotEncoder{stringIndex: make(map[string]uint32)}
+
+	packages := e.multiTable(r.data.Entries())
+	var classes []byte
+	for _, class := range sortedKeys(r.classIndex) {
+		classes = binary.LittleEndian.AppendUint32(classes, e.intern(class))
+		classes = binary.LittleEndian.AppendUint32(classes, e.intern(r.classIndex[class]))
+	}
+	processors := e.multiTable(r.annotationProcessors.Entries())
+	dependencies := e.multiTable(r.dependencies)
+
+	out := make([]byte, 0, snapshotHeaderLen+len(e.stringRefs)+len(packages)+len(classes)+len(processors)+len(dependencies)+len(e.values)+len(e.strings))
+	out = append(out, snapshotMagic...)
+	out = binary.LittleEndian.AppendUint32(out, snapshotVersion)
+	out = append(out, key[:]...)
+	for _, n := range []int{len(e.stringIndex), len(e.strings), len(packages) / 12, len(classes) / 8, len(processors) / 12, len(dependencies) / 12, int(e.nValues)} {
+		out = binary.LittleEndian.AppendUint32(out, uint32(n))
+	}
+	out = append(out, e.stringRefs...)
+	out = append(out, packages...)
+	out = append(out, classes...)
+	out = append(out, processors...)
+	out = append(out, dependencies...)
+	out = append(out, e.values...)
+	out = append(out, e.strings...)
+	return out
+}
+
+// writeSnapshot atomically replaces the snapshot at filename.
+func writeSnapshot(filename string, key snapshotKey, r *resolver) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(encodeSnapshot(key, r)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
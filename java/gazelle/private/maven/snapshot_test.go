@@ -0,0 +1,116 @@
+package maven
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/stretchr/testify/require"
+)
+
+func copyTestdata(t *testing.T, dir, name, dest string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	path := filepath.Join(dir, dest)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	return path
+}
+
+func newSnapshotResolver(t *testing.T, installFile, indexFile, snapshotFile string) *resolver {
+	t.Helper()
+	r, err := NewResolver(
+		WithInstallFile(installFile),
+		WithIndexFile(indexFile),
+		WithSnapshotFile(snapshotFile),
+	)
+	require.NoError(t, err)
+	return r.(*resolver)
+}
+
+func TestResolverSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	installFile := copyTestdata(t, dir, "classifier_maven_install.json", "maven_install.json")
+	indexFile := copyTestdata(t, dir, "classifier_maven_index.json", "maven_index.json")
+	snapshotFile := filepath.Join(dir, "cache", "maven.snapshot")
+
+	fresh := newSnapshotResolver(t, installFile, indexFile, snapshotFile)
+	require.Nil(t, fresh.snapshot, "first run should parse the JSON files")
+	require.FileExists(t, snapshotFile)
+
+	loaded := newSnapshotResolver(t, installFile, indexFile, snapshotFile)
+	require.NotNil(t, loaded.snapshot, "second run should load the snapshot")
+
+	none := make(map[string]struct{})
+	for _, r := range []Resolver{fresh, loaded} {
+		assertResolves(t, r, none, "net.sf.json.jdk15", "@maven//:net_sf_json_lib_json_lib_jdk15")
+		assertResolvesClass(t, r, none, "com.example.fixtures.WidgetFixtures", "@maven//:com_example_lib_test_fixtures")
+		assertResolvesClass(t, r, none, "com.example.fixtures.SharedHelper", "@maven//:com_example_lib")
+		assertResolvesClass(t, r, none, "com.example.fixtures.Widget.Nested", "@maven//:com_example_lib")
+
+		_, err := r.Resolve(types.NewPackageName("com.example.fixtures"), none, "maven")
+		require.IsType(t, &MultipleExternalImportsError{}, err, "split package should stay ambiguous")
+		_, err = r.Resolve(types.NewPackageName("unknown.package"), none, "maven")
+		require.IsType(t, &NoExternalImportsError{}, err)
+		require.Equal(t, []string{"com.example.fixtures.WidgetFixtures"}, r.ClassesWithSimpleName("WidgetFixtures"))
+		_, err = r.ResolveArtifact("com.example:lib", none, "maven")
+		require.NoError(t, err)
+		_, err = r.ResolveArtifact("com.example:missing", none, "maven")
+		require.IsType(t, &NotPinnedError{}, err)
+	}
+	require.Equal(t, fresh.PinnedArtifacts(), loaded.PinnedArtifacts())
+	require.Len(t, loaded.PinnedArtifacts(), 2)
+
+	// Changing an input invalidates the snapshot, which is then rebuilt.
+	copyTestdata(t, dir, "annotation_processor_maven_index.json", "maven_index.json")
+	rebuilt := newSnapshotResolver(t, installFile, indexFile, snapshotFile)
+	require.Nil(t, rebuilt.snapshot, "stale snapshot should not be loaded")
+	reloaded := newSnapshotResolver(t, installFile, indexFile, snapshotFile)
+	require.NotNil(t, reloaded.snapshot)
+	assertResolvesClass(t, reloaded, none, "com.google.auto.value.processor.AutoValueProcessor", "@maven//:com_google_auto_value_auto_value")
+	annotation, err := types.ParseClassName("com.google.auto.value.AutoValue")
+	require.NoError(t, err)
+	require.Len(t, reloaded.AnnotationProcessors(*annotation), 3)
+	_, err = reloaded.Resolve(types.NewPackageName("net.sf.json.jdk15"), none, "maven")
+	require.Error(t, err, "packages from the old index should be gone")
+}
+
+func TestResolverSnapshotCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	installFile := copyTestdata(t, dir, "classifier_maven_install.json", "maven_install.json")
+	indexFile := copyTestdata(t, dir, "classifier_maven_index.json", "maven_index.json")
+	snapshotFile := filepath.Join(dir, "maven.snapshot")
+
+	newSnapshotResolver(t, installFile, indexFile, snapshotFile)
+	data, err := os.ReadFile(snapshotFile)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(snapshotFile, data[:len(data)-1], 0o644))
+
+	r := newSnapshotResolver(t, installFile, indexFile, snapshotFile)
+	require.Nil(t, r.snapshot, "truncated snapshot should be rebuilt")
+	assertResolves(t, r, map[string]struct{}{}, "net.sf.json", "@maven//:net_sf_json_lib_json_lib")
+
+	r = newSnapshotResolver(t, installFile, indexFile, snapshotFile)
+	require.NotNil(t, r.snapshot)
+}
+
+func TestDecodeSnapshotAllocations(t *testing.T) {
+	dir := t.TempDir()
+	installFile := copyTestdata(t, dir, "classifier_maven_install.json", "maven_install.json")
+	indexFile := copyTestdata(t, dir, "classifier_maven_index.json", "maven_index.json")
+	snapshotFile := filepath.Join(dir, "maven.snapshot")
+	newSnapshotResolver(t, installFile, indexFile, snapshotFile)
+
+	key, err := computeSnapshotKey(installFile, indexFile)
+	require.NoError(t, err)
+	data, err := os.ReadFile(snapshotFile)
+	require.NoError(t, err)
+
+	allocs := testing.AllocsPerRun(10, func() {
+		if _, err := decodeSnapshot(data, key); err != nil {
+			t.Fatal(err)
+		}
+	})
+	require.LessOrEqual(t, allocs, 1.0)
+}
@@ -0,0 +1,138 @@
+// Package repository_index writes and loads a portable index of the Java rules of a
+// repository: the packages and classes each rule provides, whether it is testonly, and the
+// java_export publishing it. Another repository loads the index to resolve imports of those
+// packages to labels in the indexed repository.
+package repository_index
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+)
+
+// Version is the version of the format of the index, bumped on incompatible changes.
+const Version = 1
+
+// Rule is a rule of the indexed repository.
+type Rule struct {
+	// Label is the label of the rule, relative to the indexed repository, e.g. "//api:api".
+	Label string `json:"label"`
+	// Packages are the Java packages the rule provides.
+	Packages []string `json:"packages"`
+	// Classes are the fully qualified names of the top-level classes the rule declares.
+	Classes  []string `json:"classes,omitempty"`
+	TestOnly bool     `json:"testonly,omitempty"`
+	// JavaExport is the label of the java_export publishing the rule, if any.
+	JavaExport string `json:"java_export,omitempty"`
+}
+
+type indexFile struct {
+	Version int    `json:"version"`
+	Rules   []Rule `json:"rules"`
+}
+
+// Write writes the index of rules, sorted by label, to path.
+func Write(path string, rules []Rule) error {
+	sorted := append([]Rule{}, rules...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Label < sorted[j].Label })
+	data, err := json.MarshalIndent(indexFile{Version: Version, Rules: sorted}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Provider is a rule of an indexed repository providing a package or a class.
+type Provider struct {
+	// Label is the label of the rule, in the repository it was loaded for.
+	Label label.Label
+	// JavaExport is the label of the java_export publishing the rule, or label.NoLabel.
+	JavaExport label.Label
+	TestOnly   bool
+}
+
+// Index is an index loaded for a repository.
+type Index struct {
+	// Repo is the name of the indexed repository, prefixed to its labels.
+	Repo string
+	// Path is the path the index was loaded from.
+	Path string
+
+	packages map[types.PackageName][]Provider
+	// classes maps the fully qualified names of top-level classes to their providers.
+	classes map[string][]Provider
+}
+
+// Load loads the index at path, written by Write for the repository repo.
+func Load(repo, path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f indexFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if f.Version != Version {
+		return nil, fmt.Errorf("%s has version %d, want %d", path, f.Version, Version)
+	}
+
+	idx := &Index{
+		Repo:     repo,
+		Path:     path,
+		packages: make(map[types.PackageName][]Provider),
+		classes:  make(map[string][]Provider),
+	}
+	for _, r := range f.Rules {
+		l, err := repoLabel(repo, r.Label)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid label %q: %w", path, r.Label, err)
+		}
+		p := Provider{Label: l, JavaExport: label.NoLabel, TestOnly: r.TestOnly}
+		if r.JavaExport != "" {
+			if p.JavaExport, err = repoLabel(repo, r.JavaExport); err != nil {
+				return nil, fmt.Errorf("%s: invalid java_export label %q: %w", path, r.JavaExport, err)
+			}
+		}
+		for _, pkg := range r.Packages {
+			name := types.NewPackageName(pkg)
+			idx.packages[name] = append(idx.packages[name], p)
+		}
+		for _, class := range r.Classes {
+			cn, err := types.ParseClassName(class)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid class %q: %w", path, class, err)
+			}
+			fqn := cn.FullyQualifiedOuterClassName()
+			idx.classes[fqn] = append(idx.classes[fqn], p)
+		}
+	}
+	return idx, nil
+}
+
+// repoLabel parses s, a label of the indexed repository, as a label of repo. Labels already
+// naming a repository are kept as they are.
+func repoLabel(repo, s string) (label.Label, error) {
+	l, err := label.Parse(s)
+	if err != nil {
+		return label.NoLabel, err
+	}
+	if l.Repo == "" {
+		l.Repo = repo
+	}
+	return l, nil
+}
+
+// PackageProviders returns the rules providing pkg.
+func (idx *Index) PackageProviders(pkg types.PackageName) []Provider {
+	return idx.packages[pkg]
+}
+
+// ClassProviders returns the rules declaring the top-level class of className.
+func (idx *Index) ClassProviders(className types.ClassName) []Provider {
+	return idx.classes[className.FullyQualifiedOuterClassName()]
+}
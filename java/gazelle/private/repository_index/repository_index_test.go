@@ -0,0 +1,66 @@
+package repository_index
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+)
+
+func TestWriteAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	err := Write(path, []Rule{
+		{Label: "//testing:testing", Packages: []string{"com.example.api"}, Classes: []string{"com.example.api.FakeClient"}, TestOnly: true},
+		{Label: "//api:api", Packages: []string{"com.example.api"}, Classes: []string{"com.example.api.Client"}, JavaExport: "//api:export"},
+		{Label: "@maven//:guava", Packages: []string{"com.google.common.base"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"version": 1`) || strings.Index(string(data), "//api:api") > strings.Index(string(data), "//testing:testing") {
+		t.Errorf("want a versioned index sorted by label, got:\n%s", data)
+	}
+
+	idx, err := Load("platform", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := Provider{Label: label.New("platform", "api", "api"), JavaExport: label.New("platform", "api", "export")}
+	fake := Provider{Label: label.New("platform", "testing", "testing"), JavaExport: label.NoLabel, TestOnly: true}
+	if got, want := idx.PackageProviders(types.NewPackageName("com.example.api")), []Provider{api, fake}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PackageProviders: want %v, got %v", want, got)
+	}
+	if got, want := idx.PackageProviders(types.NewPackageName("com.google.common.base")), []Provider{{Label: label.New("maven", "", "guava"), JavaExport: label.NoLabel}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PackageProviders of a label with a repository: want %v, got %v", want, got)
+	}
+	if got := idx.PackageProviders(types.NewPackageName("com.example.other")); len(got) != 0 {
+		t.Errorf("PackageProviders of an unknown package: want none, got %v", got)
+	}
+
+	inner, err := types.ParseClassName("com.example.api.Client.Builder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := idx.ClassProviders(*inner), []Provider{api}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ClassProviders: want %v, got %v", want, got)
+	}
+}
+
+func TestLoadRejectsOtherVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	if err := os.WriteFile(path, []byte(`{"version": 2, "rules": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load("platform", path); err == nil {
+		t.Error("want an error for an index of another version")
+	}
+}
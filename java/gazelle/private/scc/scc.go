@@ -176,6 +176,23 @@ func buildGroups(components [][]string, packagesByDir map[string]*java.Package)
 	return groups
 }
 
+// Components returns the strongly-connected components of the directed graph over nodes
+// whose edges are given by adjacency, as lexicographically-sorted slices. A node missing
+// from adjacency has no outgoing edge.
+func Components(nodes []string, adjacency map[string]*sorted_set.SortedSet[string]) [][]string {
+	sorted := append([]string{}, nodes...)
+	sort.Strings(sorted)
+	complete := make(map[string]*sorted_set.SortedSet[string], len(sorted))
+	for _, n := range sorted {
+		if succ, ok := adjacency[n]; ok {
+			complete[n] = succ
+		} else {
+			complete[n] = sorted_set.NewSortedSet([]string{})
+		}
+	}
+	return tarjanSCC(sorted, complete)
+}
+
 // tarjanSCC returns the strongly-connected components as lexicographically-sorted
 // directory slices. Nodes and successors are visited in sorted order so the result
 // is deterministic.
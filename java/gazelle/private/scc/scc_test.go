@@ -199,3 +199,17 @@ func TestAssertAcyclicFiresOnMisclassifiedEdge(t *testing.T) {
 		t.Errorf("error %q does not mention a cycle", err.Error())
 	}
 }
+
+func TestComponents(t *testing.T) {
+	adjacency := map[string]*sorted_set.SortedSet[string]{
+		"a": sorted_set.NewSortedSet([]string{"b"}),
+		"b": sorted_set.NewSortedSet([]string{"c"}),
+		"c": sorted_set.NewSortedSet([]string{"a", "d"}),
+	}
+	components := Components([]string{"d", "c", "b", "a"}, adjacency)
+	sort.Slice(components, func(i, j int) bool { return components[i][0] < components[j][0] })
+	want := [][]string{{"a", "b", "c"}, {"d"}}
+	if !reflect.DeepEqual(want, components) {
+		t.Errorf("want %v, got %v", want, components)
+	}
+}
@@ -114,6 +114,16 @@ type ResolveInput struct {
 	ExportedPackageNames *sorted_set.SortedSet[PackageName]
 	ExportedClassNames   *sorted_set.SortedSet[ClassName]
 	AnnotationProcessors *sorted_set.SortedSet[ClassName]
+	// Annotations are the annotation types used by the sources of the rule, which select the
+	// annotation processors of the repository to run.
+	Annotations *sorted_set.SortedSet[ClassName]
+
+	// RequiredModules are the JPMS modules required by the module-info.java among the sources
+	// of the rule, if any, and TransitiveModules those of them required with `requires transitive`.
+	RequiredModules   *sorted_set.SortedSet[string]
+	TransitiveModules *sorted_set.SortedSet[string]
+	// UsedServices are the services the module of the rule loads with a ServiceLoader.
+	UsedServices *sorted_set.SortedSet[ClassName]
 }
 
 type ResolvableJavaPackage struct {
@@ -134,6 +144,10 @@ func (r *ResolvableJavaPackage) PackageName() PackageName {
 	return r.packageName
 }
 
+func (r *ResolvableJavaPackage) IsTestOnly() bool {
+	return r.isTestOnly
+}
+
 func (r *ResolvableJavaPackage) String() string {
 	s := r.packageName.Name
 	if r.isTestOnly {
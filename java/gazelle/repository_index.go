@@ -0,0 +1,131 @@
+package gazelle
+
+import (
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/repository_index"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// repositoryIndexWriter collects the libraries of this repository, to be written as an index
+// other repositories load with the java_repository_index directive once resolving is done.
+type repositoryIndexWriter struct {
+	path  string
+	rules map[label.Label]*repository_index.Rule
+}
+
+func newRepositoryIndexWriter(path string) *repositoryIndexWriter {
+	return &repositoryIndexWriter{
+		path:  path,
+		rules: make(map[label.Label]*repository_index.Rule),
+	}
+}
+
+// addRule records the library r, labelled lbl, with the packages it provides, other than those
+// its module encapsulates.
+func (w *repositoryIndexWriter) addRule(lbl label.Label, r *rule.Rule) {
+	pkgs, ok := r.PrivateAttr(packagesKey).([]types.ResolvableJavaPackage)
+	if !ok || len(pkgs) == 0 {
+		return
+	}
+	ir := &repository_index.Rule{Label: lbl.String(), Packages: []string{}}
+	for _, pkg := range pkgs {
+		if encapsulatedByModule(r, pkg.PackageName()) {
+			continue
+		}
+		ir.Packages = append(ir.Packages, pkg.PackageName().Name)
+		ir.TestOnly = ir.TestOnly || pkg.IsTestOnly()
+	}
+	w.rules[lbl] = ir
+}
+
+// write writes the index, with the classes each library declares and the java_export
+// publishing it, known once every rule has been generated. In a partial run, only the libraries
+// of the generated packages are known, so the index isn't written rather than truncated.
+func (w *repositoryIndexWriter) write(l javaLang, partial bool) {
+	if partial {
+		l.logger.Error().
+			Str("path", w.path).
+			Msg("Not writing the repository index: some packages of the repository were not generated in this run")
+		return
+	}
+	rules := make([]repository_index.Rule, 0, len(w.rules))
+	for lbl, ir := range w.rules {
+		for _, class := range l.classExportCache[lbl.String()].classes {
+			ir.Classes = append(ir.Classes, class.FullyQualifiedClassName())
+		}
+		if export, ok := l.javaExportIndex.IsExportedByJavaExport(lbl); ok {
+			ir.JavaExport = export.Label.String()
+		}
+		rules = append(rules, *ir)
+	}
+	if err := repository_index.Write(w.path, rules); err != nil {
+		l.logger.Error().Err(err).Str("path", w.path).Msg("failed to write repository index")
+	}
+}
+
+// loadRepositoryIndex loads the index at path for the repository repo, once for every
+// directive naming it.
+func (l javaLang) loadRepositoryIndex(repo, path string) (*repository_index.Index, error) {
+	key := repo + " " + path
+	if idx, ok := l.repositoryIndexes[key]; ok {
+		return idx, nil
+	}
+	idx, err := repository_index.Load(repo, path)
+	if err != nil {
+		return nil, err
+	}
+	l.repositoryIndexes[key] = idx
+	l.logger.Debug().Str("repo", repo).Str("path", path).Msg("loaded repository index")
+	return idx, nil
+}
+
+// resolveFromRepositoryIndexes resolves imp to a library of another repository, from the
+// first index of pc providing it. ambiguous is true when several libraries provide it.
+func (jr *Resolver) resolveFromRepositoryIndexes(pc *javaconfig.Config, imp types.PackageName, isTestRule bool) (out label.Label, ambiguous bool) {
+	for _, idx := range pc.RepositoryIndexes() {
+		candidates := repositoryIndexCandidates(pc, idx.PackageProviders(imp), isTestRule)
+		if len(candidates) == 1 {
+			return candidates[0], false
+		}
+		if len(candidates) > 1 {
+			jr.lang.logger.Debug().
+				Str("package", imp.Name).
+				Str("repo", idx.Repo).
+				Msg("package has multiple providers in repository index")
+			return label.NoLabel, true
+		}
+	}
+	return label.NoLabel, false
+}
+
+// resolveClassFromRepositoryIndexes resolves className to the library of another repository
+// declaring it, from the first index of pc with a single such library.
+func (jr *Resolver) resolveClassFromRepositoryIndexes(pc *javaconfig.Config, className types.ClassName, isTestRule bool) label.Label {
+	for _, idx := range pc.RepositoryIndexes() {
+		if candidates := repositoryIndexCandidates(pc, idx.ClassProviders(className), isTestRule); len(candidates) == 1 {
+			return candidates[0]
+		}
+	}
+	return label.NoLabel
+}
+
+// repositoryIndexCandidates returns the labels to depend on for providers: testonly libraries
+// only for test rules, and the java_export publishing a library rather than the library, as
+// for rules of this repository.
+func repositoryIndexCandidates(pc *javaconfig.Config, providers []repository_index.Provider, isTestRule bool) []label.Label {
+	candidates := sorted_set.NewSortedSetFn([]label.Label{}, sorted_set.LabelLess)
+	for _, p := range providers {
+		if p.TestOnly && !isTestRule {
+			continue
+		}
+		if pc.ResolveToJavaExports() && p.JavaExport != label.NoLabel {
+			candidates.Add(p.JavaExport)
+		} else {
+			candidates.Add(p.Label)
+		}
+	}
+	return candidates.SortedSlice()
+}
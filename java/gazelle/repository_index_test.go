@@ -0,0 +1,125 @@
+package gazelle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/repository_index"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryIndexWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	c, langs, jLang := testJavaLang(t, "-java-repository-index="+path)
+	mrslv, exts := InitTestResolversAndExtensions(langs)
+	ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+	require.NotNil(t, jLang.repositoryIndexWriter)
+	jLang.classExportCache["//:api"] = classExportInfo{classes: []types.ClassName{types.NewClassName(types.NewPackageName("com.example.api"), "Client")}}
+
+	const content = `java_library(
+    name = "api",
+    srcs = ["Client.java"],
+    _packages = ["com.example.api"],
+)
+
+java_library(
+    name = "fixtures",
+    srcs = ["FakeClient.java"],
+    testonly = True,
+)
+
+java_binary(
+    name = "tool",
+    main_class = "com.example.Tool",
+)`
+	f, err := rule.LoadData("BUILD.bazel", "", []byte(content))
+	require.NoError(t, err)
+	setPackagesPrivateAttr(f.Rules[0])
+	f.Rules[1].SetPrivateAttr(packagesKey, []types.ResolvableJavaPackage{*types.NewResolvableJavaPackage(types.NewPackageName("com.example.api"), true, false)})
+	for _, r := range f.Rules {
+		convertImportsAttr(r)
+		ix.AddRule(c, r, f)
+	}
+	ix.Finish()
+	jLang.javaExportIndex.FinalizeIndex()
+	jLang.repositoryIndexWriter.write(*jLang, true)
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err), "no index should be written in a partial run")
+
+	jLang.repositoryIndexWriter.write(*jLang, false)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "version": 1,
+  "rules": [
+    {"label": "//:api", "packages": ["com.example.api"], "classes": ["com.example.api.Client"]},
+    {"label": "//:fixtures", "packages": ["com.example.api"], "testonly": true}
+  ]
+}`, string(data))
+}
+
+func TestResolveFromRepositoryIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	require.NoError(t, repository_index.Write(path, []repository_index.Rule{
+		{Label: "//api:api", Packages: []string{"com.example.api"}, Classes: []string{"com.example.api.Client"}, JavaExport: "//api:export"},
+		{Label: "//split/a:a", Packages: []string{"com.example.split"}, Classes: []string{"com.example.split.A"}},
+		{Label: "//split/b:b", Packages: []string{"com.example.split"}, Classes: []string{"com.example.split.B"}},
+		{Label: "//fixtures:fixtures", Packages: []string{"com.example.fixtures"}, TestOnly: true},
+	}))
+
+	const content = `java_library(
+    name = "lib",
+    srcs = ["Lib.java"],
+    _imported_packages = [
+        "com.example.api",
+        "com.example.split",
+        "com.google.common.primitives",
+    ],
+    _packages = ["com.example"],
+)`
+
+	for name, tc := range map[string]struct {
+		resolveToJavaExports bool
+		want                 []string
+	}{
+		"java_export": {
+			resolveToJavaExports: true,
+			want:                 []string{"@maven//:com_google_guava_guava", "@platform//api:export", "@platform//split/b"},
+		},
+		"library": {
+			resolveToJavaExports: false,
+			want:                 []string{"@maven//:com_google_guava_guava", "@platform//api", "@platform//split/b"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, langs, jLang := testJavaLang(t)
+			mrslv, exts := InitTestResolversAndExtensions(langs)
+			ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+			idx, err := jLang.loadRepositoryIndex("platform", path)
+			require.NoError(t, err)
+			cfg := c.Exts[languageName].(javaconfig.Configs)[""]
+			cfg.SetResolveToJavaExports(tc.resolveToJavaExports)
+			cfg.AddRepositoryIndex(idx)
+
+			f, err := rule.LoadData("BUILD.bazel", "", []byte(content))
+			require.NoError(t, err)
+			r := f.Rules[0]
+			imports := convertImportsAttr(r)
+			imports.ImportedClasses = sorted_set.NewSortedSetFn([]types.ClassName{types.NewClassName(types.NewPackageName("com.example.split"), "B")}, types.ClassNameLess)
+			ix.AddRule(c, r, f)
+			ix.Finish()
+			mrslv.Resolver(r, "").Resolve(c, ix, testRemoteCache(nil), r, imports, label.New("", "", r.Name()))
+
+			require.Equal(t, tc.want, r.AttrStrings("deps"))
+			require.False(t, jLang.hasHadErrors)
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package gazelle
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/rs/zerolog"
+)
+
+// resolutionRoute is how an import was resolved to a dependency or, for an import which
+// adds no dependency, why it was dropped.
+type resolutionRoute string
+
+const (
+	routeOverride        resolutionRoute = "override"
+	routeIndex           resolutionRoute = "index"
+	routeJavaExport      resolutionRoute = "java_export"
+	routeClassIndex      resolutionRoute = "class_index"
+	routeMavenPackage    resolutionRoute = "maven_package"
+	routeMavenClass      resolutionRoute = "maven_class"
+	routeCrossResolver   resolutionRoute = "cross_resolver"
+	routeTestSuiteHelper resolutionRoute = "test_suite_helper"
+	routeRunfiles        resolutionRoute = "runfiles"
+	routePlatformPackage resolutionRoute = "platform_package"
+	routeRepositoryIndex resolutionRoute = "repository_index"
+	routeModule          resolutionRoute = "module"
+	routeService         resolutionRoute = "service"
+
+	dropStdlib         resolutionRoute = "stdlib"
+	dropKotlinStdlib   resolutionRoute = "kotlin_stdlib"
+	dropTestOwnPackage resolutionRoute = "test_own_package"
+	dropAmbiguous      resolutionRoute = "ambiguous"
+	dropUnresolved     resolutionRoute = "unresolved"
+	dropIgnored        resolutionRoute = "ignored"
+	dropDenied         resolutionRoute = "denied"
+)
+
+// resolutionTrace records, for each resolved rule, why each dependency was added and why
+// imports were dropped, to be written as JSON once resolving is done.
+type resolutionTrace struct {
+	path string
+	// targets restricts the traced rules, see matchesTarget. Empty traces every rule.
+	targets []string
+	rules   map[string]*traceRule
+}
+
+type traceRule struct {
+	Rule    string        `json:"rule"`
+	Deps    []traceDep    `json:"deps"`
+	Dropped []traceImport `json:"dropped"`
+}
+
+// traceDep is a dependency added to an attribute of a rule.
+type traceDep struct {
+	Attr  string `json:"attr"`
+	Label string `json:"label"`
+	// Import is the imported package which required the dependency.
+	Import string `json:"import"`
+	// Class is the imported class, when the dependency was resolved for it specifically.
+	Class string          `json:"class,omitempty"`
+	Route resolutionRoute `json:"route"`
+}
+
+// traceImport is an import which added no dependency.
+type traceImport struct {
+	Attr   string          `json:"attr"`
+	Import string          `json:"import"`
+	Reason resolutionRoute `json:"reason"`
+}
+
+func newResolutionTrace(path string, targets []string) *resolutionTrace {
+	return &resolutionTrace{
+		path:    path,
+		targets: targets,
+		rules:   make(map[string]*traceRule),
+	}
+}
+
+// matchesTarget returns whether from is traced, see javaconfig.MatchesLabelPattern.
+func (rt *resolutionTrace) matchesTarget(from label.Label) bool {
+	if len(rt.targets) == 0 {
+		return true
+	}
+	for _, target := range rt.targets {
+		if javaconfig.MatchesLabelPattern(target, from) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rt *resolutionTrace) rule(from label.Label) *traceRule {
+	key := from.String()
+	r, ok := rt.rules[key]
+	if !ok {
+		r = &traceRule{Rule: key, Deps: []traceDep{}, Dropped: []traceImport{}}
+		rt.rules[key] = r
+	}
+	return r
+}
+
+func (rt *resolutionTrace) addDep(from label.Label, attr string, dep label.Label, imp types.PackageName, class *types.ClassName, route resolutionRoute) {
+	if !rt.matchesTarget(from) {
+		return
+	}
+	d := traceDep{Attr: attr, Label: dep.String(), Import: imp.Name, Route: route}
+	if class != nil {
+		d.Class = class.FullyQualifiedClassName()
+	}
+	r := rt.rule(from)
+	r.Deps = append(r.Deps, d)
+}
+
+func (rt *resolutionTrace) addDropped(from label.Label, attr string, imp types.PackageName, reason resolutionRoute) {
+	if !rt.matchesTarget(from) {
+		return
+	}
+	r := rt.rule(from)
+	r.Dropped = append(r.Dropped, traceImport{Attr: attr, Import: imp.Name, Reason: reason})
+}
+
+// write writes the trace, sorted by rule.
+func (rt *resolutionTrace) write(logger zerolog.Logger) {
+	rules := make([]*traceRule, 0, len(rt.rules))
+	for _, r := range rt.rules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Rule < rules[j].Rule })
+
+	data, err := json.MarshalIndent(struct {
+		Rules []*traceRule `json:"rules"`
+	}{rules}, "", "  ")
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to encode resolution trace")
+		return
+	}
+	if err := os.WriteFile(rt.path, append(data, '\n'), 0o644); err != nil {
+		logger.Error().Err(err).Str("path", rt.path).Msg("failed to write resolution trace")
+	}
+}
+
+// traceDep records a dependency added to attr of the rule from, if tracing is enabled.
+func (jr *Resolver) traceDep(from label.Label, attr string, dep label.Label, imp types.PackageName, class *types.ClassName, route resolutionRoute) {
+	if jr.lang.resolutionTrace != nil {
+		jr.lang.resolutionTrace.addDep(from, attr, dep, imp, class, route)
+	}
+}
+
+// traceDropped records an import of the rule from which added no dependency, if tracing is enabled.
+func (jr *Resolver) traceDropped(from label.Label, attr string, imp types.PackageName, reason resolutionRoute) {
+	if jr.lang.resolutionTrace != nil {
+		jr.lang.resolutionTrace.addDropped(from, attr, imp, reason)
+	}
+}
@@ -0,0 +1,96 @@
+package gazelle
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolutionTrace(t *testing.T) {
+	tracePath := filepath.Join(t.TempDir(), "trace.json")
+	c, langs, jLang := testJavaLang(t, "-java-resolution-trace="+tracePath, "-java-resolution-trace-target=//:app")
+	mrslv, exts := InitTestResolversAndExtensions(langs)
+	ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+	require.NotNil(t, jLang.resolutionTrace)
+	c.Exts[languageName].(javaconfig.Configs)[""].AddPlatformPackage(types.NewPackageName("android"), "")
+
+	const content = `java_library(
+    name = "lib",
+    srcs = ["Lib.java"],
+    _imported_packages = ["com.google.common.primitives"],
+    _packages = ["com.example.lib"],
+)
+
+java_library(
+    name = "app",
+    srcs = ["App.java"],
+    _imported_packages = [
+        "android.os",
+        "com.example.lib",
+        "com.google.common.primitives",
+        "java.util",
+    ],
+    _packages = ["com.example.app"],
+)`
+	f, err := rule.LoadData("BUILD.bazel", "", []byte(content))
+	require.NoError(t, err)
+	imports := make([]interface{}, len(f.Rules))
+	for i, r := range f.Rules {
+		setPackagesPrivateAttr(r)
+		imports[i] = convertImportsAttr(r)
+		ix.AddRule(c, r, f)
+	}
+	ix.Finish()
+	for i, r := range f.Rules {
+		mrslv.Resolver(r, "").Resolve(c, ix, testRemoteCache(nil), r, imports[i], label.New("", "", r.Name()))
+	}
+	jLang.resolutionTrace.write(zerolog.Nop())
+
+	data, err := os.ReadFile(tracePath)
+	require.NoError(t, err)
+	var got struct {
+		Rules []traceRule `json:"rules"`
+	}
+	require.NoError(t, json.Unmarshal(data, &got))
+	require.Equal(t, []traceRule{
+		{
+			Rule: "//:app",
+			Deps: []traceDep{
+				{Attr: "deps", Label: ":lib", Import: "com.example.lib", Route: routeIndex},
+				{Attr: "deps", Label: "@maven//:com_google_guava_guava", Import: "com.google.common.primitives", Route: routeMavenPackage},
+			},
+			Dropped: []traceImport{
+				{Attr: "deps", Import: "android.os", Reason: routePlatformPackage},
+				{Attr: "deps", Import: "java.util", Reason: dropStdlib},
+			},
+		},
+	}, got.Rules)
+}
+
+func TestResolutionTraceMatchesTarget(t *testing.T) {
+	for _, tc := range []struct {
+		targets []string
+		from    label.Label
+		want    bool
+	}{
+		{nil, label.New("", "src/app", "app"), true},
+		{[]string{"//src/app"}, label.New("", "src/app", "app"), true},
+		{[]string{"//src/app:app"}, label.New("", "src/app", "test"), false},
+		{[]string{"//src/..."}, label.New("", "src/app", "app"), true},
+		{[]string{"//src/app/..."}, label.New("", "src/application", "app"), false},
+		{[]string{"//..."}, label.New("", "", "root"), true},
+		{[]string{"//other:lib", "//src/app:test"}, label.New("", "src/app", "test"), true},
+	} {
+		rt := newResolutionTrace("", tc.targets)
+		require.Equal(t, tc.want, rt.matchesTarget(tc.from), "%v matching %s", tc.targets, tc.from)
+	}
+}
@@ -33,6 +33,10 @@ type Resolver struct {
 	// classIndex is a lazy per-package index, built only for packages with ambiguous
 	// resolution (split packages). Maintains prod/test distinction.
 	classIndex map[types.PackageName]*packageClassIndex
+	// simpleNameIndex maps the simple names of the classes of the rules of the repository to
+	// those classes. It is built once an import can't be resolved, as it is only used to
+	// report it.
+	simpleNameIndex map[string][]classProvider
 	// configs provides a map from pkg to config. This allows us to use the config in
 	// Embeds.
 	configs map[string]*config.Config
@@ -47,6 +51,20 @@ type packageClassIndex struct {
 	test map[string][]label.Label
 }
 
+// classProvider is a class of a rule of the repository.
+type classProvider struct {
+	className types.ClassName
+	label     string
+	testonly  bool
+}
+
+// cachedResolution is a package resolution kept in internalCache, with the route which
+// found it so the resolution trace stays accurate for cache hits.
+type cachedResolution struct {
+	label label.Label
+	route resolutionRoute
+}
+
 func NewResolver(lang *javaLang) *Resolver {
 	internalCache, err := lru.New(10000)
 	if err != nil {
@@ -77,12 +95,35 @@ func (jr *Resolver) Imports(c *config.Config, r *rule.Rule, f *rule.File) []reso
 
 	lbl := label.New("", f.Pkg, r.Name())
 
+	if pc := c.Exts[languageName].(javaconfig.Configs)[f.Pkg]; pc != nil && pc.InferVisibility() && (isJavaLibrary(c, r.Kind()) || isKotlinLibrary(r.Kind())) {
+		jr.lang.visibility.addLibrary(f.Pkg, r)
+	}
+	if jr.lang.repositoryIndexWriter != nil && isJvmLibrary(c, r.Kind()) {
+		jr.lang.repositoryIndexWriter.addRule(lbl, r)
+	}
+
 	var out []resolve.ImportSpec
 	if pkgs := r.PrivateAttr(packagesKey); pkgs != nil {
 		for _, pkg := range pkgs.([]types.ResolvableJavaPackage) {
+			if encapsulatedByModule(r, pkg.PackageName()) {
+				out = append(out, resolve.ImportSpec{Lang: languageName, Imp: moduleInternalImportPrefix + pkg.PackageName().Name})
+				continue
+			}
 			out = append(out, resolve.ImportSpec{Lang: languageName, Imp: pkg.String()})
 		}
 	}
+	if module := r.PrivateAttr(moduleKey); module != nil {
+		out = append(out, moduleImportSpecs(module.(*java.Module))...)
+	}
+	if services := r.PrivateAttr(servicesKey); services != nil {
+		prefix := serviceImportPrefix
+		if ruleIsTestOnly(r) {
+			prefix = testServiceImportPrefix
+		}
+		for _, service := range services.([]string) {
+			out = append(out, resolve.ImportSpec{Lang: languageName, Imp: prefix + service})
+		}
+	}
 	// NOTE: We intentionally do NOT register classes in Gazelle's global RuleIndex.
 	// Class-level resolution uses a lazy, per-package index built only when needed
 	// (when package-level resolution is ambiguous due to split packages).
@@ -123,9 +164,11 @@ func (jr *Resolver) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.Re
 	}
 
 	// If the current library is exported under a `java_export`, it shouldn't be visible for targets outside the java_export.
+	inferVisibility := packageConfig.InferVisibility()
 	if packageConfig.ResolveToJavaExports() && isJavaLibrary(c, r.Kind()) {
 		visibility := jr.lang.javaExportIndex.VisibilityForLabel(from)
 		if visibility != nil {
+			inferVisibility = false
 			var asStrings []string
 			for _, vis := range visibility.SortedSlice() {
 				asStrings = append(asStrings, vis.String())
@@ -140,9 +183,28 @@ func (jr *Resolver) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.Re
 	jr.populateAttr(c, packageConfig, r, "deps", resolveInput.ImportedPackageNames, resolveInput.ImportedClasses, ix, isTestRule, from, resolveInput.PackageNames)
 	jr.populateAttr(c, packageConfig, r, "exports", resolveInput.ExportedPackageNames, resolveInput.ExportedClassNames, ix, isTestRule, from, resolveInput.PackageNames)
 
+	jr.populateModuleAttrs(c, ix, packageConfig, resolveInput, r, from)
+
 	jr.populateAssociatesAttr(c, ix, resolveInput, r, isTestRule, from)
 
 	jr.populatePluginsAttr(c, ix, resolveInput, packageConfig, from, isTestRule, r)
+
+	if !isJavaProtoLibrary(c, r.Kind()) {
+		jr.populateRuntimeDepsAttr(c, packageConfig, resolveInput, from, r)
+	}
+
+	jr.populateServiceLoaderRuntimeDeps(c, ix, packageConfig, resolveInput, r, from)
+
+	if jr.lang.mavenReport != nil {
+		jr.lang.mavenReport.recordRule(packageConfig.MavenRepositoryName(), from, r)
+	}
+
+	jr.lang.visibility.recordRule(from, r, inferVisibility, packageConfig.ExtraVisibility())
+
+	if jr.lang.dependencyGraph != nil {
+		classes := jr.lang.classExportCache[label.New("", from.Pkg, from.Name).String()].classes
+		jr.lang.dependencyGraph.recordRule(from, r, resolveInput.PackageNames, classes)
+	}
 }
 
 // populateAssociatesAttr makes a Kotlin test target a friend (associate) of the production
@@ -241,10 +303,21 @@ func (jr *Resolver) populateAttr(c *config.Config, pc *javaconfig.Config, r *rul
 		}
 	}
 
+	addLabel := func(l label.Label, imp types.PackageName, className *types.ClassName, route resolutionRoute) {
+		classes := classesByPackage[imp]
+		if className != nil {
+			classes = []types.ClassName{*className}
+		}
+		if jr.recordDependency(c, pc, r, from, ownPackageNames, attrName, l, imp, className, classes, route) {
+			labels.Add(l)
+		}
+	}
+
 	for _, imp := range requiredPackageNames.SortedSlice() {
 		// rules_kotlin supplies the Kotlin standard library implicitly, but Java rules do
 		// not. Only suppress kotlin.* dependencies for targets that contain Kotlin sources.
 		if ruleHasKotlinSources(r) && kotlin.IsStdlib(imp) {
+			jr.traceDropped(from, attrName, imp, dropKotlinStdlib)
 			continue
 		}
 
@@ -281,7 +354,7 @@ func (jr *Resolver) populateAttr(c *config.Config, pc *javaconfig.Config, r *rul
 				// Check for explicit resolve directive for this specific class first
 				classImportSpec := resolve.ImportSpec{Lang: languageName, Imp: className.FullyQualifiedClassName()}
 				if ol, found := resolve.FindRuleWithOverride(c, classImportSpec, languageName); found {
-					labels.Add(simplifyLabel(c.RepoName, ol, from))
+					addLabel(simplifyLabel(c.RepoName, ol, from), imp, &className, routeOverride)
 					continue
 				}
 
@@ -290,20 +363,21 @@ func (jr *Resolver) populateAttr(c *config.Config, pc *javaconfig.Config, r *rul
 					jr.lang.logger.Warn().Err(err).Str("class", className.FullyQualifiedClassName()).Msg("error resolving class")
 					continue
 				}
+				route := routeMavenClass
 				if l == label.NoLabel {
-					l = jr.resolveSingleClass(c, pc, className, ix, from, isTestRule)
+					l, route = jr.resolveSingleClass(c, pc, className, ix, from, isTestRule), routeClassIndex
 				}
 				if l != label.NoLabel {
-					labels.Add(simplifyLabel(c.RepoName, l, from))
+					addLabel(simplifyLabel(c.RepoName, l, from), imp, &className, route)
 				}
 			}
 			continue
 		}
 
 		// Try package-level resolution first (fast path)
-		dep, ambiguous := jr.resolveSinglePackageWithAmbiguity(c, pc, imp, ix, from, isTestRule, ownPackageNames, pkgClasses)
+		dep, route, ambiguous := jr.resolveSinglePackageWithAmbiguity(c, pc, imp, ix, from, isTestRule, ownPackageNames, pkgClasses)
 		if dep != label.NoLabel {
-			labels.Add(simplifyLabel(c.RepoName, dep, from))
+			addLabel(simplifyLabel(c.RepoName, dep, from), imp, nil, route)
 
 			// The package resolved unambiguously to a single target, but an external
 			// gazelle plugin (e.g. a proto/wire generator) may own some classes of the same
@@ -316,7 +390,7 @@ func (jr *Resolver) populateAttr(c *config.Config, pc *javaconfig.Config, r *rul
 					continue
 				}
 				if l := jr.resolveClassFromCrossResolver(c, pc, className, ix, from); l != label.NoLabel {
-					labels.Add(l)
+					addLabel(l, imp, &className, routeCrossResolver)
 					continue
 				}
 				if isTestRule {
@@ -324,7 +398,7 @@ func (jr *Resolver) populateAttr(c *config.Config, pc *javaconfig.Config, r *rul
 					// set) whose package the resolved production target also owns; the in-repo class
 					// index includes testonly providers for test rules.
 					if l := jr.resolveSingleClass(c, pc, className, ix, from, true); l != label.NoLabel {
-						labels.Add(l)
+						addLabel(l, imp, &className, routeClassIndex)
 						continue
 					}
 					// Or the class may be a helper in another package's java_test_suite (its
@@ -332,7 +406,7 @@ func (jr *Resolver) populateAttr(c *config.Config, pc *javaconfig.Config, r *rul
 					// declares the class -- a class the production provider doesn't declare may be a
 					// main top-level function, not a test helper, which must not pull the lib in.
 					if l := jr.resolveTestSuiteHelperClass(c, imp, className, ix, from); l != label.NoLabel {
-						labels.Add(l)
+						addLabel(l, imp, &className, routeTestSuiteHelper)
 						continue
 					}
 				}
@@ -340,6 +414,24 @@ func (jr *Resolver) populateAttr(c *config.Config, pc *javaconfig.Config, r *rul
 			continue
 		}
 
+		// An import of the package without a specific class, such as a wildcard one, can't
+		// choose between its providers by class.
+		if ambiguous && len(classesByPackage[imp]) == 0 {
+			if fileLibraries := jr.fileLibraries(c, ix, imp, from); len(fileLibraries) > 0 {
+				for _, l := range fileLibraries {
+					addLabel(simplifyLabel(c.RepoName, l, from), imp, nil, routeIndex)
+				}
+				continue
+			}
+			jr.traceDropped(from, attrName, imp, dropAmbiguous)
+			jr.recordUnresolved(pc, imp, from, pkgClasses, jr.packageProviders(c, pc, ix, imp), nil)
+			continue
+		}
+
+		if !ambiguous {
+			jr.traceDropped(from, attrName, imp, route)
+		}
+
 		// Only fall back to class-level resolution when package resolution is ambiguous
 		if ambiguous && len(classesByPackage[imp]) > 0 {
 			jr.lang.logger.Debug().
@@ -353,7 +445,7 @@ func (jr *Resolver) populateAttr(c *config.Config, pc *javaconfig.Config, r *rul
 				// Check for explicit resolve directive for this specific class first
 				classImportSpec := resolve.ImportSpec{Lang: languageName, Imp: className.FullyQualifiedClassName()}
 				if ol, found := resolve.FindRuleWithOverride(c, classImportSpec, languageName); found {
-					labels.Add(simplifyLabel(c.RepoName, ol, from))
+					addLabel(simplifyLabel(c.RepoName, ol, from), imp, &className, routeOverride)
 					resolvedAny = true
 					continue
 				}
@@ -363,25 +455,25 @@ func (jr *Resolver) populateAttr(c *config.Config, pc *javaconfig.Config, r *rul
 					jr.lang.logger.Warn().Err(err).Str("class", className.FullyQualifiedClassName()).Msg("error resolving class")
 					continue
 				}
+				route := routeMavenClass
+				if l == label.NoLabel {
+					l, route = jr.resolveSingleClass(c, pc, className, ix, from, isTestRule), routeClassIndex
+				}
 				if l == label.NoLabel {
-					l = jr.resolveSingleClass(c, pc, className, ix, from, isTestRule)
+					l, route = jr.resolveClassFromRepositoryIndexes(pc, className, isTestRule), routeRepositoryIndex
 				}
 				if l == label.NoLabel && isTestRule {
-					l = jr.resolveTestSuiteHelperClass(c, imp, className, ix, from)
+					l, route = jr.resolveTestSuiteHelperClass(c, imp, className, ix, from), routeTestSuiteHelper
 				}
 				if l != label.NoLabel {
-					labels.Add(simplifyLabel(c.RepoName, l, from))
+					addLabel(simplifyLabel(c.RepoName, l, from), imp, &className, route)
 					resolvedAny = true
 				}
 			}
 
 			if !resolvedAny {
-				jr.lang.logger.Error().
-					Str("package", imp.Name).
-					Strs("classes", pkgClasses).
-					Stringer("from", from).
-					Msg("package has multiple providers and class-level resolution failed for all classes")
-				jr.lang.hasHadErrors = true
+				jr.traceDropped(from, attrName, imp, dropAmbiguous)
+				jr.recordUnresolved(pc, imp, from, pkgClasses, jr.packageProviders(c, pc, ix, imp), jr.findSameNamedClasses(pc, imp, pkgClasses, isTestRule))
 			}
 		}
 	}
@@ -398,7 +490,7 @@ func (jr *Resolver) populateAttr(c *config.Config, pc *javaconfig.Config, r *rul
 				continue
 			}
 			if l := jr.resolveClassFromCrossResolver(c, pc, className, ix, from); l != label.NoLabel {
-				labels.Add(l)
+				addLabel(l, className.PackageName(), &className, routeCrossResolver)
 			}
 		}
 	}
@@ -407,6 +499,59 @@ func (jr *Resolver) populateAttr(c *config.Config, pc *javaconfig.Config, r *rul
 
 }
 
+// recordDependency checks the dependency of the rule r, labelled from, on l through attrName,
+// required by an import of imp or, if not nil, of className, against the dependency rules, and
+// records it in the resolution trace, the dependency graph and the package cycles. It returns
+// whether the dependency is allowed.
+func (jr *Resolver) recordDependency(c *config.Config, pc *javaconfig.Config, r *rule.Rule, from label.Label, ownPackageNames *sorted_set.SortedSet[types.PackageName], attrName string, l label.Label, imp types.PackageName, className *types.ClassName, classes []types.ClassName, route resolutionRoute) bool {
+	if !jr.dependencyAllowed(pc, from, ownPackageNames, l, imp, classes) {
+		jr.traceDropped(from, attrName, imp, dropDenied)
+		return false
+	}
+	jr.traceDep(from, attrName, l, imp, className, route)
+	if jr.lang.dependencyGraph != nil {
+		jr.lang.dependencyGraph.addResolved(from, attrName, l, imp, className, route)
+	}
+	if granularity := pc.ModuleGranularity(); (granularity == "package" || granularity == "file") && (l.Repo == "" || l.Repo == c.RepoName) {
+		jr.lang.packageCycles.addEdge(from, r, l, imp, className)
+	}
+	return true
+}
+
+// dependencyAllowed checks the dependency of the rule from on dep, for an import of imp, against
+// the java_dependency_rule directives, logging an error for each package of from which may not
+// depend on it.
+func (jr *Resolver) dependencyAllowed(pc *javaconfig.Config, from label.Label, fromPackages *sorted_set.SortedSet[types.PackageName], dep label.Label, imp types.PackageName, classes []types.ClassName) bool {
+	absDep := dep.Abs(from.Repo, from.Pkg)
+	packages := []types.PackageName{{}}
+	if fromPackages != nil && fromPackages.Len() > 0 {
+		packages = fromPackages.SortedSlice()
+	}
+
+	allowed := true
+	for _, pkg := range packages {
+		rule, found := pc.MatchingDependencyRule(from, pkg, absDep, imp)
+		if !found || rule.Allow {
+			continue
+		}
+		classNames := make([]string, 0, len(classes))
+		for _, className := range classes {
+			classNames = append(classNames, className.FullyQualifiedClassName())
+		}
+		jr.lang.logger.Error().
+			Str("from rule", from.String()).
+			Str("package", pkg.Name).
+			Str("import", imp.Name).
+			Strs("classes", classNames).
+			Str("dependency", absDep.String()).
+			Str("rule", rule.String()).
+			Msgf("Dependency denied by %s directive", javaconfig.JavaDependencyRule)
+		jr.lang.hasHadErrors = true
+		allowed = false
+	}
+	return allowed
+}
+
 func (jr *Resolver) populatePluginsAttr(c *config.Config, ix *resolve.RuleIndex, resolveInput types.ResolveInput, packageConfig *javaconfig.Config, from label.Label, isTestRule bool, r *rule.Rule) {
 	pluginLabels := sorted_set.NewSortedSetFn[label.Label]([]label.Label{}, labelLess)
 	for _, annotationProcessor := range resolveInput.AnnotationProcessors.SortedSlice() {
@@ -415,17 +560,82 @@ func (jr *Resolver) populatePluginsAttr(c *config.Config, ix *resolve.RuleIndex,
 			continue
 		}
 
-		// Use the naming scheme for plugins as per https://github.com/bazelbuild/rules_jvm_external/pull/1102
 		// In the case of overrides (i.e. # gazelle:resolve targets) we require that they follow the same name-mangling scheme for the java_plugin target as rules_jvm_external uses.
 		// Ideally this would be a call to `java_plugin_artifact(dep.String(), annotationProcessor.FullyQualifiedClassName())` but we don't have function calls working in attributes.
-		dep.Name += "__java_plugin__" + strings.NewReplacer(".", "_", "$", "_").Replace(annotationProcessor.FullyQualifiedClassName())
+		dep.Name = javaPluginName(dep.Name, annotationProcessor)
 
 		pluginLabels.Add(simplifyLabel(c.RepoName, dep, from))
 	}
 
+	// The annotation processors of the repository run for the annotation types they support.
+	for _, annotation := range resolveInput.Annotations.SortedSlice() {
+		for _, plugin := range jr.lang.annotationProcessorPlugins.pluginsFor(annotation) {
+			if plugin.library == from || (plugin.testonly && !isTestRule) {
+				continue
+			}
+			pluginLabels.Add(simplifyLabel(c.RepoName, plugin.plugin, from))
+		}
+	}
+
+	// Kotlin compiler plugins only run on Kotlin sources.
+	if ruleHasKotlinSources(r) {
+		for _, annotation := range resolveInput.Annotations.SortedSlice() {
+			if plugins := packageConfig.KotlinCompilerPlugins(annotation); plugins != nil {
+				for _, plugin := range plugins.SortedSlice() {
+					pluginLabels.Add(simplifyLabel(c.RepoName, plugin, from))
+				}
+			}
+		}
+	}
+
 	setLabelAttrIncludingExistingValues(r, "plugins", pluginLabels)
 }
 
+// populateRuntimeDepsAttr adds the runtime dependencies which java_runtime_dep directives
+// associate with the packages the rule imports.
+func (jr *Resolver) populateRuntimeDepsAttr(c *config.Config, pc *javaconfig.Config, resolveInput types.ResolveInput, from label.Label, r *rule.Rule) {
+	deps := sorted_set.NewSortedSet([]string{})
+	for _, imp := range resolveInput.ImportedPackageNames.SortedSlice() {
+		deps.AddAll(pc.RuntimeDeps(imp))
+	}
+	if deps.Len() == 0 {
+		return
+	}
+
+	runtimeDeps := sorted_set.NewSortedSetFn[label.Label]([]label.Label{}, labelLess)
+	for _, dep := range deps.SortedSlice() {
+		var l label.Label
+		if isLabelRuntimeDep(dep) {
+			var err error
+			if l, err = label.Parse(dep); err != nil {
+				jr.lang.logger.Error().Err(err).Str("dep", dep).Msg("Failed to parse runtime dep label")
+				continue
+			}
+			if _, excluded := pc.ExcludedArtifacts()[l.String()]; excluded {
+				continue
+			}
+		} else {
+			var err error
+			if l, err = jr.lang.mavenResolver.ResolveArtifact(dep, pc.ExcludedArtifacts(), pc.MavenRepositoryName()); err != nil {
+				jr.lang.logger.Warn().
+					Err(err).
+					Str("artifact", dep).
+					Str("from rule", from.String()).
+					Msg("Not adding runtime dependency")
+				continue
+			}
+			if l == label.NoLabel {
+				continue
+			}
+		}
+		runtimeDeps.Add(simplifyLabel(c.RepoName, l, from))
+	}
+
+	if runtimeDeps.Len() > 0 {
+		setLabelAttrIncludingExistingValues(r, "runtime_deps", runtimeDeps)
+	}
+}
+
 func labelLess(l, r label.Label) bool {
 	// In UTF-8, / sorts before :
 	// We want relative labels to come before absolute ones, so explicitly sort relative before absolute.
@@ -478,11 +688,13 @@ func setLabelAttrIncludingExistingValues(r *rule.Rule, attrName string, labels *
 
 // resolveSinglePackageWithAmbiguity resolves a package import and returns whether there was ambiguity.
 // When ambiguous is true and out is NoLabel, the caller should attempt class-level resolution.
-func (jr *Resolver) resolveSinglePackageWithAmbiguity(c *config.Config, pc *javaconfig.Config, imp types.PackageName, ix *resolve.RuleIndex, from label.Label, isTestRule bool, ownPackageNames *sorted_set.SortedSet[types.PackageName], pkgClasses []string) (out label.Label, ambiguous bool) {
+// route is how out was found or, when out is NoLabel and there was no ambiguity, why the import
+// was dropped.
+func (jr *Resolver) resolveSinglePackageWithAmbiguity(c *config.Config, pc *javaconfig.Config, imp types.PackageName, ix *resolve.RuleIndex, from label.Label, isTestRule bool, ownPackageNames *sorted_set.SortedSet[types.PackageName], pkgClasses []string) (out label.Label, route resolutionRoute, ambiguous bool) {
 	cacheKey := types.NewResolvableJavaPackage(imp, false, false)
 	importSpec := resolve.ImportSpec{Lang: languageName, Imp: cacheKey.String()}
 	if ol, found := resolve.FindRuleWithOverride(c, importSpec, languageName); found {
-		return ol, false
+		return ol, routeOverride, false
 	}
 
 	matches := ix.FindRulesByImportWithConfig(c, importSpec, languageName)
@@ -500,41 +712,76 @@ func (jr *Resolver) resolveSinglePackageWithAmbiguity(c *config.Config, pc *java
 	}
 
 	if len(matches) == 1 {
-		return matches[0].Label, false
+		if jr.lang.javaExportIndex.IsJavaExport(matches[0].Label) {
+			return matches[0].Label, routeJavaExport, false
+		}
+		return matches[0].Label, routeIndex, false
 	}
 
 	if len(matches) > 1 {
 		// Multiple matches found - signal ambiguity so caller can try class-level resolution
-		return label.NoLabel, true
+		return label.NoLabel, dropAmbiguous, true
+	}
+
+	// Checked before the cache: whether a package is in the standard library, or provided by
+	// the platform, depends on the importing package, but the cache is shared across the
+	// repository.
+	if java.IsStdlibForRelease(imp, pc.Release()) {
+		if pc.Release() != 0 && java.IsJDKInternal(imp) {
+			jr.lang.logger.Warn().
+				Str("package", imp.Name).
+				Str("from rule", from.String()).
+				Int("release", pc.Release()).
+				Msg("Import of a JDK-internal package")
+		}
+		return label.NoLabel, dropStdlib, false
+	}
+
+	if dep, found := pc.PlatformPackage(imp); found {
+		if dep == "" {
+			return label.NoLabel, routePlatformPackage, false
+		}
+		l, err := label.Parse(dep)
+		if err != nil {
+			jr.lang.logger.Error().Err(err).Str("label", dep).Msg("Failed to parse platform package label")
+			return label.NoLabel, routePlatformPackage, false
+		}
+		return simplifyLabel(c.RepoName, l, from), routePlatformPackage, false
+	}
+
+	// Checked before the cache too, as the indexes of other repositories are per-directory.
+	if l, indexAmbiguous := jr.resolveFromRepositoryIndexes(pc, imp, isTestRule); l != label.NoLabel {
+		return l, routeRepositoryIndex, false
+	} else if indexAmbiguous {
+		return label.NoLabel, dropAmbiguous, true
 	}
 
 	if v, ok := jr.internalCache.Get(cacheKey); ok {
-		return simplifyLabel(c.RepoName, v.(label.Label), from), false
+		cached := v.(cachedResolution)
+		return simplifyLabel(c.RepoName, cached.label, from), cached.route, false
 	}
 
 	jr.lang.logger.Debug().Str("parsedImport", imp.Name).Stringer("from", from).Msg("not found yet")
 
 	defer func() {
 		if out != label.NoLabel {
-			jr.internalCache.Add(cacheKey, out)
+			jr.internalCache.Add(cacheKey, cachedResolution{label: out, route: route})
 		}
 	}()
 
-	if java.IsStdlib(imp) {
-		return label.NoLabel, false
-	}
-
 	// As per https://github.com/bazelbuild/bazel/blob/347407a88fd480fc5e0fbd42cc8196e4356a690b/tools/java/runfiles/Runfiles.java#L41
 	if imp.Name == "com.google.devtools.build.runfiles" {
 		runfilesLabel := "@bazel_tools//tools/java/runfiles"
 		l, err := label.Parse(runfilesLabel)
 		if err != nil {
 			jr.lang.logger.Fatal().Str("label", runfilesLabel).Err(err).Msg("failed to parse known-good runfiles label")
-			return label.NoLabel, false
+			return label.NoLabel, dropUnresolved, false
 		}
-		return l, false
+		return l, routeRunfiles, false
 	}
 
+	// The artifacts providing a split package, if class-level resolution can't choose one.
+	var splitProviders []string
 	if l, err := jr.lang.mavenResolver.Resolve(imp, pc.ExcludedArtifacts(), pc.MavenRepositoryName()); err != nil {
 		var noExternal *maven.NoExternalImportsError
 		var multipleExternal *maven.MultipleExternalImportsError
@@ -548,31 +795,35 @@ func (jr *Resolver) resolveSinglePackageWithAmbiguity(c *config.Config, pc *java
 				for _, className := range pkgClasses {
 					cls := types.NewClassName(imp, className)
 					if resolved, _ := jr.lang.mavenResolver.ResolveClass(cls, pc.ExcludedArtifacts(), pc.MavenRepositoryName()); resolved != label.NoLabel {
-						return label.NoLabel, true
+						return label.NoLabel, dropAmbiguous, true
 					}
 				}
 			}
-			// No class-level resolution available, show helpful error with resolution hints
-			jr.lang.logger.Error().Strs("classes", pkgClasses).Msg("Append one of the following to BUILD.bazel:")
-			for _, possible := range multipleExternal.PossiblePackages {
-				jr.lang.logger.Error().Msgf("# gazelle:resolve java %s %s", imp.Name, possible)
-			}
-			// Don't return here - let execution continue to produce the warning about unresolved package
+			// No class-level resolution available: the artifacts are suggested with the
+			// unresolved package.
+			splitProviders = multipleExternal.PossiblePackages
 		} else {
 			jr.lang.logger.Fatal().Err(err).Msg("maven resolver error")
 		}
 	} else {
-		return l, false
+		return l, routeMavenPackage, false
 	}
 
 	if isTestRule {
+		// If there's exactly one library encapsulating the package in its module, use it
+		internalImportSpec := resolve.ImportSpec{Lang: languageName, Imp: moduleInternalImportPrefix + imp.Name}
+		if internalMatches := ix.FindRulesByImportWithConfig(c, internalImportSpec, languageName); len(internalMatches) == 1 {
+			cacheKey = types.NewResolvableJavaPackage(imp, true, false)
+			return simplifyLabel(c.RepoName, internalMatches[0].Label, from), routeIndex, false
+		}
+
 		// If there's exactly one testonly match, use it
 		testonlyCacheKey := types.NewResolvableJavaPackage(imp, true, false)
 		testonlyImportSpec := resolve.ImportSpec{Lang: languageName, Imp: testonlyCacheKey.String()}
 		testonlyMatches := ix.FindRulesByImportWithConfig(c, testonlyImportSpec, languageName)
 		if len(testonlyMatches) == 1 {
 			cacheKey = testonlyCacheKey
-			return simplifyLabel(c.RepoName, testonlyMatches[0].Label, from), false
+			return simplifyLabel(c.RepoName, testonlyMatches[0].Label, from), routeIndex, false
 		}
 
 		// If there's exactly one testsuite match, use it
@@ -584,28 +835,148 @@ func (jr *Resolver) resolveSinglePackageWithAmbiguity(c *config.Config, pc *java
 			l := testsuiteMatches[0].Label
 			if l != from {
 				l.Name += "-test-lib"
-				return simplifyLabel(c.RepoName, l, from), false
+				return simplifyLabel(c.RepoName, l, from), routeTestSuiteHelper, false
 			}
 		}
 	}
 
 	if isTestRule && ownPackageNames.Contains(imp) {
 		// Tests may have unique packages which don't exist outside of those tests - don't treat this as an error.
-		return label.NoLabel, false
+		return label.NoLabel, dropTestOwnPackage, false
 	}
 
-	jr.lang.logger.Error().
-		Str("package", imp.Name).
-		Str("from rule", from.String()).
-		Strs("classes", pkgClasses).
-		Msg("Unable to find package for import in any dependency")
-	jr.lang.hasHadErrors = true
+	if jr.lang.mavenReport != nil {
+		jr.lang.mavenReport.recordUnresolved(imp, from)
+	}
+
+	providers := append(jr.excludedPackageProviders(pc, imp), splitProviders...)
+	return label.NoLabel, jr.recordUnresolved(pc, imp, from, pkgClasses, providers, jr.findSameNamedClasses(pc, imp, pkgClasses, isTestRule)), false
+}
+
+// recordUnresolved records the import of imp by the rule from, which no dependency was found
+// for, to be reported once resolving is done with the severity of the package, along with
+// the labels a resolve directive can select and the same-named classes of other packages. It
+// returns why the import added no dependency.
+func (jr *Resolver) recordUnresolved(pc *javaconfig.Config, imp types.PackageName, from label.Label, pkgClasses []string, providers []string, candidates map[string]string) resolutionRoute {
+	severity := pc.UnresolvedImportSeverity(imp)
+	if severity == javaconfig.SeverityIgnore {
+		jr.lang.logger.Debug().
+			Str("package", imp.Name).
+			Str("from rule", from.String()).
+			Msg("Ignoring unresolved import")
+		return dropIgnored
+	}
+	jr.lang.unresolvedImports.add(severity, imp, from, pkgClasses, providers, candidates)
+	if severity == javaconfig.SeverityError {
+		jr.lang.hasHadErrors = true
+	}
+	return dropUnresolved
+}
+
+// packageProviders returns the labels of the libraries of this repository and of the Maven
+// artifacts which all provide imp, for a package class-level resolution couldn't split.
+func (jr *Resolver) packageProviders(c *config.Config, pc *javaconfig.Config, ix *resolve.RuleIndex, imp types.PackageName) []string {
+	var providers []string
+	spec := resolve.ImportSpec{Lang: languageName, Imp: types.NewResolvableJavaPackage(imp, false, false).String()}
+	for _, match := range ix.FindRulesByImportWithConfig(c, spec, languageName) {
+		providers = append(providers, match.Label.String())
+	}
+	_, err := jr.lang.mavenResolver.Resolve(imp, pc.ExcludedArtifacts(), pc.MavenRepositoryName())
+	var multipleExternal *maven.MultipleExternalImportsError
+	if errors.As(err, &multipleExternal) {
+		providers = append(providers, multipleExternal.PossiblePackages...)
+	}
+	return providers
+}
+
+// fileLibraries returns the libraries providing imp, other than from, if they are the file
+// granularity libraries of a single Bazel package: an import of the package which names no
+// class may use any of them, so it depends on all of them.
+func (jr *Resolver) fileLibraries(c *config.Config, ix *resolve.RuleIndex, imp types.PackageName, from label.Label) []label.Label {
+	spec := resolve.ImportSpec{Lang: languageName, Imp: types.NewResolvableJavaPackage(imp, false, false).String()}
+	var out []label.Label
+	for _, match := range ix.FindRulesByImportWithConfig(c, spec, languageName) {
+		if jr.lang.javaExportIndex.IsJavaExport(match.Label) || match.Label == from {
+			continue
+		}
+		if len(out) > 0 && (match.Label.Repo != out[0].Repo || match.Label.Pkg != out[0].Pkg) {
+			return nil
+		}
+		out = append(out, match.Label)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	if pc := c.Exts[languageName].(javaconfig.Configs)[out[0].Pkg]; pc == nil || pc.ModuleGranularity() != "file" {
+		return nil
+	}
+	return out
+}
+
+// excludedPackageProviders returns the labels of the Maven artifacts providing imp which
+// java_exclude_artifact directives exclude: a # gazelle:resolve directive makes the import
+// resolve to one of them again.
+func (jr *Resolver) excludedPackageProviders(pc *javaconfig.Config, imp types.PackageName) []string {
+	if len(pc.ExcludedArtifacts()) == 0 {
+		return nil
+	}
+	var providers []string
+	l, err := jr.lang.mavenResolver.Resolve(imp, nil, pc.MavenRepositoryName())
+	var multipleExternal *maven.MultipleExternalImportsError
+	if err == nil {
+		providers = []string{l.String()}
+	} else if errors.As(err, &multipleExternal) {
+		providers = multipleExternal.PossiblePackages
+	}
 
-	return label.NoLabel, false
+	var excluded []string
+	for _, provider := range providers {
+		if _, ok := pc.ExcludedArtifacts()[provider]; ok {
+			excluded = append(excluded, provider)
+		}
+	}
+	return excluded
+}
+
+// findSameNamedClasses searches the Maven class index and the classes of the rules in this
+// repository for classes named like pkgClasses in packages other than imp, which usually
+// means the import is misspelled or the package was relocated. It returns the fully
+// qualified names of the classes it finds, mapped to the labels providing them.
+func (jr *Resolver) findSameNamedClasses(pc *javaconfig.Config, imp types.PackageName, pkgClasses []string, isTestRule bool) map[string]string {
+	if jr.simpleNameIndex == nil {
+		jr.simpleNameIndex = make(map[string][]classProvider)
+		for ruleLabel, info := range jr.lang.classExportCache {
+			for _, className := range info.classes {
+				name := className.BareOuterClassName()
+				jr.simpleNameIndex[name] = append(jr.simpleNameIndex[name], classProvider{className: className, label: ruleLabel, testonly: info.testonly})
+			}
+		}
+	}
+
+	candidates := make(map[string]string)
+	for _, simpleName := range pkgClasses {
+		for _, fqcn := range jr.lang.mavenResolver.ClassesWithSimpleName(simpleName) {
+			className, err := types.ParseClassName(fqcn)
+			if err != nil || className.PackageName() == imp {
+				continue
+			}
+			if l, err := jr.lang.mavenResolver.ResolveClass(*className, pc.ExcludedArtifacts(), pc.MavenRepositoryName()); err == nil && l != label.NoLabel {
+				candidates[fqcn] = l.String()
+			}
+		}
+
+		for _, provider := range jr.simpleNameIndex[simpleName] {
+			if (provider.testonly && !isTestRule) || provider.className.PackageName() == imp {
+				continue
+			}
+			candidates[provider.className.FullyQualifiedClassName()] = provider.label
+		}
+	}
+	return candidates
 }
 
 func (jr *Resolver) resolveSinglePackage(c *config.Config, pc *javaconfig.Config, imp types.PackageName, ix *resolve.RuleIndex, from label.Label, isTestRule bool, ownPackageNames *sorted_set.SortedSet[types.PackageName], pkgClasses []string) (out label.Label) {
-	out, _ = jr.resolveSinglePackageWithAmbiguity(c, pc, imp, ix, from, isTestRule, ownPackageNames, pkgClasses)
+	out, _, _ = jr.resolveSinglePackageWithAmbiguity(c, pc, imp, ix, from, isTestRule, ownPackageNames, pkgClasses)
 	return out
 }
 
@@ -21,11 +21,11 @@ import (
 //     on one Kotlin module.
 //
 // rules_kotlin requires all of a target's associates to share one module_name and analyses
-// deps bottom-up, so the leaves' shared module_name propagates to every target. Only scc
-// granularity (many packages, one module) is touched; package granularity is unchanged.
+// deps bottom-up, so the leaves' shared module_name propagates to every target. Only scc and
+// auto granularities (many packages, one module) are touched; package granularity is unchanged.
 func (jr *Resolver) populateProductionAssociatesAttr(c *config.Config, r *rule.Rule, from label.Label) {
 	pc := c.Exts[languageName].(javaconfig.Configs)[from.Pkg]
-	if pc == nil || pc.ModuleGranularity() != "scc" {
+	if pc == nil || (pc.ModuleGranularity() != "scc" && pc.ModuleGranularity() != "auto") {
 		return
 	}
 
@@ -0,0 +1,71 @@
+package gazelle
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+)
+
+// testOnlySuffix, appended to an import asked of CrossResolve, also returns testonly providers.
+const testOnlySuffix = "!testonly"
+
+// CrossResolve implements resolve.CrossResolver, so that the extensions of other languages can
+// resolve Java imports, see Resolver.CrossResolve. Gazelle looks for it on the language.
+func (l javaLang) CrossResolve(c *config.Config, ix *resolve.RuleIndex, imp resolve.ImportSpec, lang string) []resolve.FindResult {
+	return l.Resolver.(*Resolver).CrossResolve(c, ix, imp, lang)
+}
+
+// CrossResolve resolves a Java package, e.g. "com.example.foo", or a fully qualified class,
+// e.g. "com.example.foo.Bar" or "com.example.foo.Bar.Inner", imported by a rule of another
+// language, to the libraries of this repository providing it. A class resolves to the providers
+// of its package declaring it, or to the only provider of its package. testonly libraries are
+// only returned when the import ends in "!testonly", as for the tests of this extension.
+//
+// Imports by Java rules are left to Resolve: Gazelle calls every CrossResolver when the index has
+// no match, which for Java happens on every class-level lookup.
+func (jr *Resolver) CrossResolve(c *config.Config, ix *resolve.RuleIndex, imp resolve.ImportSpec, lang string) []resolve.FindResult {
+	if imp.Lang != languageName || lang == languageName {
+		return nil
+	}
+	name, testOnly := strings.CutSuffix(imp.Imp, testOnlySuffix)
+
+	if results := jr.findPackageProviders(ix, types.NewPackageName(name), testOnly); len(results) > 0 {
+		return results
+	}
+
+	className, err := types.ParseClassName(name)
+	if err != nil || className.PackageName().Name == "" {
+		return nil
+	}
+	// ParseClassName takes the last part of a package for a class, which must be capitalized.
+	if outer := []rune(className.BareOuterClassName()); len(outer) == 0 || !unicode.IsUpper(outer[0]) {
+		return nil
+	}
+	providers := jr.findPackageProviders(ix, className.PackageName(), testOnly)
+	var declaring []resolve.FindResult
+	for _, p := range providers {
+		if jr.ruleDeclaresClass(p.Label, *className) {
+			declaring = append(declaring, p)
+		}
+	}
+	if len(declaring) > 0 {
+		return declaring
+	}
+	if len(providers) == 1 {
+		return providers
+	}
+	return nil
+}
+
+// findPackageProviders returns the libraries of this repository registered as providing pkg,
+// including testonly ones if testOnly. It only consults the index, not the CrossResolvers.
+func (jr *Resolver) findPackageProviders(ix *resolve.RuleIndex, pkg types.PackageName, testOnly bool) []resolve.FindResult {
+	results := ix.FindRulesByImport(resolve.ImportSpec{Lang: languageName, Imp: types.NewResolvableJavaPackage(pkg, false, false).String()}, languageName)
+	if testOnly {
+		results = append(results, ix.FindRulesByImport(resolve.ImportSpec{Lang: languageName, Imp: types.NewResolvableJavaPackage(pkg, true, false).String()}, languageName)...)
+	}
+	return results
+}
@@ -0,0 +1,107 @@
+package gazelle
+
+import (
+	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrossResolve(t *testing.T) {
+	c, langs, jLang := testJavaLang(t)
+	mrslv, exts := InitTestResolversAndExtensions(langs)
+	ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+	foo := types.NewPackageName("com.example.foo")
+	jLang.classExportCache["//:foo"] = classExportInfo{classes: []types.ClassName{types.NewClassName(foo, "Foo")}}
+	jLang.classExportCache["//:foo_gen"] = classExportInfo{classes: []types.ClassName{types.NewClassName(foo, "Generated")}}
+	jLang.classExportCache["//:fixtures"] = classExportInfo{classes: []types.ClassName{types.NewClassName(types.NewPackageName("com.example.testing"), "FakeFoo")}, testonly: true}
+
+	const content = `java_library(
+    name = "foo",
+    _packages = ["com.example.foo"],
+)
+
+java_library(
+    name = "foo_gen",
+    _packages = ["com.example.foo"],
+)
+
+java_library(
+    name = "app",
+    _packages = ["com.example.app"],
+)
+
+java_library(
+    name = "fixtures",
+    testonly = True,
+)`
+	f, err := rule.LoadData("BUILD.bazel", "", []byte(content))
+	require.NoError(t, err)
+	for _, r := range f.Rules[:3] {
+		setPackagesPrivateAttr(r)
+	}
+	f.Rules[3].SetPrivateAttr(packagesKey, []types.ResolvableJavaPackage{*types.NewResolvableJavaPackage(types.NewPackageName("com.example.testing"), true, false)})
+	for _, r := range f.Rules {
+		convertImportsAttr(r)
+		ix.AddRule(c, r, f)
+	}
+	ix.Finish()
+
+	for name, tc := range map[string]struct {
+		imp  resolve.ImportSpec
+		lang string
+		want []label.Label
+	}{
+		"package": {
+			imp:  resolve.ImportSpec{Lang: languageName, Imp: "com.example.foo"},
+			lang: "kotlin",
+			want: []label.Label{label.New("", "", "foo"), label.New("", "", "foo_gen")},
+		},
+		"class of a split package": {
+			imp:  resolve.ImportSpec{Lang: languageName, Imp: "com.example.foo.Generated.Inner"},
+			lang: "kotlin",
+			want: []label.Label{label.New("", "", "foo_gen")},
+		},
+		"class not in the class export cache": {
+			imp:  resolve.ImportSpec{Lang: languageName, Imp: "com.example.app.App"},
+			lang: "kotlin",
+			want: []label.Label{label.New("", "", "app")},
+		},
+		"unknown class of a split package": {
+			imp:  resolve.ImportSpec{Lang: languageName, Imp: "com.example.foo.Unknown"},
+			lang: "kotlin",
+		},
+		"unknown package": {
+			imp:  resolve.ImportSpec{Lang: languageName, Imp: "com.example.app.sub"},
+			lang: "kotlin",
+		},
+		"testonly class": {
+			imp:  resolve.ImportSpec{Lang: languageName, Imp: "com.example.testing.FakeFoo"},
+			lang: "kotlin",
+		},
+		"testonly class from a test": {
+			imp:  resolve.ImportSpec{Lang: languageName, Imp: "com.example.testing.FakeFoo!testonly"},
+			lang: "kotlin",
+			want: []label.Label{label.New("", "", "fixtures")},
+		},
+		"import by a Java rule": {
+			imp:  resolve.ImportSpec{Lang: languageName, Imp: "com.example.app.App"},
+			lang: languageName,
+		},
+		"import of another language": {
+			imp:  resolve.ImportSpec{Lang: "proto", Imp: "com.example.foo"},
+			lang: "kotlin",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got []label.Label
+			for _, result := range ix.FindRulesByImportWithConfig(c, tc.imp, tc.lang) {
+				got = append(got, result.Label)
+			}
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package gazelle
+
+import (
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// moduleImportSpecs returns the import specs of a library declaring module: its name, so
+// that the libraries requiring it resolve to it, and the services it provides, so that those
+// using them get it as a runtime dependency.
+func moduleImportSpecs(module *java.Module) []resolve.ImportSpec {
+	specs := []resolve.ImportSpec{{Lang: languageName, Imp: moduleImportPrefix + module.Name}}
+	for _, service := range module.Provides.Keys() {
+		specs = append(specs, resolve.ImportSpec{Lang: languageName, Imp: serviceImportPrefix + service})
+	}
+	return specs
+}
+
+// encapsulatedByModule returns whether the module declared among the sources of r, if any,
+// doesn't export pkg, which then only the tests of the library may use.
+func encapsulatedByModule(r *rule.Rule, pkg types.PackageName) bool {
+	module, ok := r.PrivateAttr(moduleKey).(*java.Module)
+	return ok && !module.Exports.Contains(pkg)
+}
+
+// populateModuleAttrs adds the dependencies declared by the module-info.java among the sources
+// of r, if any: the libraries of the modules it requires to deps, and to exports for those it
+// requires transitively, and the libraries of this repository providing the services it uses
+// to runtime_deps.
+func (jr *Resolver) populateModuleAttrs(c *config.Config, ix *resolve.RuleIndex, pc *javaconfig.Config, resolveInput types.ResolveInput, r *rule.Rule, from label.Label) {
+	if resolveInput.RequiredModules == nil {
+		return
+	}
+
+	deps := sorted_set.NewSortedSetFn([]label.Label{}, labelLess)
+	exports := sorted_set.NewSortedSetFn([]label.Label{}, labelLess)
+	for _, name := range resolveInput.RequiredModules.SortedSlice() {
+		if java.IsPlatformModule(name) {
+			continue
+		}
+		dep := jr.resolveModule(c, ix, pc, name, from)
+		if dep == label.NoLabel {
+			jr.lang.logger.Warn().
+				Str("module", name).
+				Str("from rule", from.String()).
+				Msgf("Unable to find the library of a required module, add `# gazelle:resolve java %s%s <label>` to resolve it", moduleImportPrefix, name)
+			continue
+		}
+		if dep.Abs(from.Repo, from.Pkg) == from {
+			continue
+		}
+		dep = simplifyLabel(c.RepoName, dep, from)
+		// The module name stands for the import, as module names usually follow package names.
+		imp := types.NewPackageName(name)
+		if jr.recordDependency(c, pc, r, from, resolveInput.PackageNames, "deps", dep, imp, nil, nil, routeModule) {
+			deps.Add(dep)
+		}
+		if resolveInput.TransitiveModules.Contains(name) && jr.recordDependency(c, pc, r, from, resolveInput.PackageNames, "exports", dep, imp, nil, nil, routeModule) {
+			exports.Add(dep)
+		}
+	}
+	if deps.Len() > 0 {
+		setLabelAttrIncludingExistingValues(r, "deps", deps)
+	}
+	if exports.Len() > 0 {
+		setLabelAttrIncludingExistingValues(r, "exports", exports)
+	}
+
+	runtimeDeps := sorted_set.NewSortedSetFn([]label.Label{}, labelLess)
+	for _, service := range resolveInput.UsedServices.SortedSlice() {
+		spec := resolve.ImportSpec{Lang: languageName, Imp: serviceImportPrefix + service.FullyQualifiedClassName()}
+		for _, match := range ix.FindRulesByImportWithConfig(c, spec, languageName) {
+			if match.Label.Abs(from.Repo, from.Pkg) == from {
+				continue
+			}
+			dep := simplifyLabel(c.RepoName, match.Label, from)
+			if jr.recordDependency(c, pc, r, from, resolveInput.PackageNames, "runtime_deps", dep, service.PackageName(), &service, []types.ClassName{service}, routeService) {
+				runtimeDeps.Add(dep)
+			}
+		}
+	}
+	if runtimeDeps.Len() > 0 {
+		setLabelAttrIncludingExistingValues(r, "runtime_deps", runtimeDeps)
+	}
+}
+
+// resolveModule returns the library of the module name: the one given by a
+// `# gazelle:resolve java module:<name>` directive, the library of this repository declaring
+// it, or else the Maven artifact providing the package named like the module, which is the
+// usual naming scheme of modules.
+func (jr *Resolver) resolveModule(c *config.Config, ix *resolve.RuleIndex, pc *javaconfig.Config, name string, from label.Label) label.Label {
+	spec := resolve.ImportSpec{Lang: languageName, Imp: moduleImportPrefix + name}
+	if l, found := resolve.FindRuleWithOverride(c, spec, languageName); found {
+		return l
+	}
+
+	matches := ix.FindRulesByImportWithConfig(c, spec, languageName)
+	if pc.ResolveToJavaExports() {
+		matches = jr.tryResolvingToJavaExport(matches, from)
+	}
+	if len(matches) == 1 {
+		return matches[0].Label
+	}
+	if len(matches) > 1 {
+		var labels []string
+		for _, match := range matches {
+			labels = append(labels, match.Label.String())
+		}
+		jr.lang.logger.Warn().
+			Str("module", name).
+			Strs("labels", labels).
+			Str("from rule", from.String()).
+			Msg("More than one library declares a required module")
+		return label.NoLabel
+	}
+
+	if l, err := jr.lang.mavenResolver.Resolve(types.NewPackageName(name), pc.ExcludedArtifacts(), pc.MavenRepositoryName()); err == nil {
+		return l
+	}
+	return label.NoLabel
+}
@@ -0,0 +1,96 @@
+package gazelle
+
+import (
+	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_multiset"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveModule(t *testing.T) {
+	c, langs, jLang := testJavaLang(t)
+	cfg := c.Exts[languageName].(javaconfig.Configs)[""]
+
+	codec := types.NewClassName(types.NewPackageName("com.example.spi"), "Codec")
+	apiModule := testModule("com.example.api", nil, nil, []string{"com.example.api"})
+	apiModule.Provides.Add(codec.FullyQualifiedClassName(), types.NewClassName(types.NewPackageName("com.example.api.internal"), "JsonCodec"))
+	appModule := testModule("com.example.app", []string{"com.example.api", "com.example.missing", "java.sql"}, []string{"com.example.api"}, nil)
+	appModule.Uses.Add(codec)
+
+	f := rule.EmptyFile("BUILD.bazel", "")
+	var res language.GenerateResult
+	for _, lib := range []struct {
+		name     string
+		packages []string
+		module   *java.Module
+	}{
+		{name: "api", packages: []string{"com.example.api", "com.example.api.internal"}, module: apiModule},
+		{name: "app", packages: []string{"com.example.app"}, module: appModule},
+	} {
+		jLang.generateJavaLibrary(generateJavaLibraryArgs{
+			File:        f,
+			LibraryKind: "java_library",
+			Result:      &res,
+			Config:      cfg,
+			Name:        lib.name,
+			Srcs:        []string{"module-info.java"},
+			Packages:    stringsToPackageNames(lib.packages),
+			Imports:     stringsToPackageNames(nil),
+			Exports:     stringsToPackageNames(nil),
+			Module:      lib.module,
+		})
+	}
+	mrslv, exts := InitTestResolversAndExtensions(langs)
+	ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+	for _, r := range res.Gen {
+		ix.AddRule(c, r, f)
+	}
+	ix.Finish()
+	jLang.mavenResolver = &noExternalMavenResolver{}
+	app := res.Gen[1]
+
+	require.ElementsMatch(t, []resolve.ImportSpec{
+		{Lang: languageName, Imp: "com.example.api"},
+		{Lang: languageName, Imp: "module-internal:com.example.api.internal"},
+		{Lang: languageName, Imp: "module:com.example.api"},
+		{Lang: languageName, Imp: "service:com.example.spi.Codec"},
+	}, mrslv.Resolver(res.Gen[0], "").Imports(c, res.Gen[0], f), "the unexported package is for tests only")
+	require.False(t, ruleIsTestOnly(res.Gen[0]), "the library is still usable outside of tests")
+
+	jr := jLang.Resolver.(*Resolver)
+	internal := types.NewPackageName("com.example.api.internal")
+	require.Equal(t, label.NoLabel, jr.resolveSinglePackage(c, cfg, internal, ix, label.New("", "", "app"), false, nil, nil))
+	require.Equal(t, ":api", jr.resolveSinglePackage(c, cfg, internal, ix, label.New("", "", "app_test"), true, nil, nil).String())
+
+	jLang.dependencyGraph = newDependencyGraph("", "")
+	mrslv.Resolver(app, "").Resolve(c, ix, testRemoteCache(nil), app, res.Imports[1], label.New("", "", "app"))
+	require.Equal(t, []string{":api"}, app.AttrStrings("deps"))
+	require.Equal(t, []string{":api"}, app.AttrStrings("exports"), "the api module is required transitively")
+	require.Equal(t, []string{":api"}, app.AttrStrings("runtime_deps"), "the api module provides the used service")
+	var attrs []string
+	for _, e := range jLang.dependencyGraph.resolved {
+		attrs = append(attrs, e.Attr+" "+e.Reason)
+	}
+	require.ElementsMatch(t, []string{"deps module", "exports module", "runtime_deps service"}, attrs)
+}
+
+func testModule(name string, requires, transitive, exports []string) *java.Module {
+	return &java.Module{
+		Name:               name,
+		Requires:           sorted_set.NewSortedSet(requires),
+		RequiresTransitive: sorted_set.NewSortedSet(transitive),
+		RequiresStatic:     sorted_set.NewSortedSet([]string{}),
+		Exports:            stringsToPackageNames(exports),
+		Opens:              stringsToPackageNames(nil),
+		Uses:               sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess),
+		Provides:           sorted_multiset.NewSortedMultiSetFn[string, types.ClassName](types.ClassNameLess),
+	}
+}
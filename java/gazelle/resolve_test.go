@@ -1,6 +1,7 @@
 package gazelle
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"path/filepath"
@@ -9,6 +10,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/maven"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
 	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
@@ -23,6 +25,7 @@ import (
 	"github.com/bazelbuild/bazel-gazelle/testtools"
 	"github.com/bazelbuild/bazel-gazelle/walk"
 	bzl "github.com/bazelbuild/buildtools/build"
+	"github.com/rs/zerolog"
 	"github.com/sergi/go-diff/diffmatchpatch"
 	"golang.org/x/tools/go/vcs"
 )
@@ -400,6 +403,19 @@ func testConfig(t *testing.T, args ...string) (*config.Config, []language.Langua
 	return c, langs, cexts
 }
 
+// testJavaLang returns the configuration and languages of testConfig, with the Java language
+// among them.
+func testJavaLang(t *testing.T, args ...string) (*config.Config, []language.Language, *javaLang) {
+	c, langs, _ := testConfig(t, args...)
+	for _, lang := range langs {
+		if jl, ok := lang.(*javaLang); ok {
+			return c, langs, jl
+		}
+	}
+	t.Fatal("javaLang not found in test config")
+	return nil, nil, nil
+}
+
 type testResolver struct{}
 
 func (*testResolver) Resolve(pkg types.PackageName, excludedArtifacts map[string]struct{}, mavenRepositoryName string) (label.Label, error) {
@@ -410,6 +426,22 @@ func (*testResolver) ResolveClass(className types.ClassName, excludedArtifacts m
 	return label.NoLabel, errors.New("not implemented")
 }
 
+func (*testResolver) AnnotationProcessors(annotationClass types.ClassName) []types.ClassName {
+	return nil
+}
+
+func (*testResolver) ClassesWithSimpleName(simpleName string) []string {
+	return nil
+}
+
+func (*testResolver) ResolveArtifact(artifact string, excludedArtifacts map[string]struct{}, mavenRepositoryName string) (label.Label, error) {
+	return label.NoLabel, errors.New("not implemented")
+}
+
+func (*testResolver) PinnedArtifacts() []maven.PinnedArtifact {
+	return nil
+}
+
 type mapResolver map[string]resolve.Resolver
 
 func (mr mapResolver) Resolver(r *rule.Rule, f string) resolve.Resolver {
@@ -442,6 +474,7 @@ func NewTestMavenResolver() *TestMavenResolver {
 	return &TestMavenResolver{
 		data: map[types.PackageName]label.Label{
 			types.NewPackageName("com.google.common.primitives"): label.New("maven", "", "com_google_guava_guava"),
+			types.NewPackageName("javax.xml.bind"):               label.New("maven", "", "javax_xml_bind_jaxb_api"),
 			types.NewPackageName("kotlin.random"):                label.New("maven", "", "org_jetbrains_kotlin_kotlin_stdlib"),
 			types.NewPackageName("org.junit"):                    label.New("maven", "", "junit_junit"),
 		},
@@ -460,6 +493,26 @@ func (r *TestMavenResolver) ResolveClass(className types.ClassName, excludedArti
 	return label.NoLabel, nil
 }
 
+func (r *TestMavenResolver) AnnotationProcessors(annotationClass types.ClassName) []types.ClassName {
+	return nil
+}
+
+func (r *TestMavenResolver) ClassesWithSimpleName(simpleName string) []string {
+	return nil
+}
+
+func (r *TestMavenResolver) ResolveArtifact(artifact string, excludedArtifacts map[string]struct{}, mavenRepositoryName string) (label.Label, error) {
+	l := maven.LabelFromArtifact(mavenRepositoryName, artifact)
+	if _, excluded := excludedArtifacts[l.String()]; excluded {
+		return label.NoLabel, nil
+	}
+	return l, nil
+}
+
+func (r *TestMavenResolver) PinnedArtifacts() []maven.PinnedArtifact {
+	return nil
+}
+
 func TestProtoSplitPackageClassResolution(t *testing.T) {
 	c, langs, _ := testConfig(t)
 
@@ -803,3 +856,652 @@ func (r *noExternalMavenResolver) Resolve(pkg types.PackageName, excludedArtifac
 func (r *noExternalMavenResolver) ResolveClass(className types.ClassName, excludedArtifacts map[string]struct{}, mavenRepositoryName string) (label.Label, error) {
 	return label.NoLabel, nil
 }
+
+func (r *noExternalMavenResolver) AnnotationProcessors(annotationClass types.ClassName) []types.ClassName {
+	return nil
+}
+
+func (r *noExternalMavenResolver) ClassesWithSimpleName(simpleName string) []string {
+	return nil
+}
+
+func (r *noExternalMavenResolver) ResolveArtifact(artifact string, excludedArtifacts map[string]struct{}, mavenRepositoryName string) (label.Label, error) {
+	return label.NoLabel, &maven.NotPinnedError{Artifact: artifact}
+}
+
+func (r *noExternalMavenResolver) PinnedArtifacts() []maven.PinnedArtifact {
+	return nil
+}
+
+func TestResolveRuntimeDeps(t *testing.T) {
+	c, langs, _ := testConfig(t)
+	mrslv, exts := InitTestResolversAndExtensions(langs)
+	ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+	rc := testRemoteCache(nil)
+
+	cfg := c.Exts[languageName].(javaconfig.Configs)[""]
+	cfg.AddRuntimeDep(types.NewPackageName("org.junit"), "//third_party:junit_engine")
+	cfg.AddRuntimeDep(types.NewPackageName("com.google.common"), "com.google.guava:failureaccess")
+	cfg.AddRuntimeDep(types.NewPackageName("com.google.common"), "com.google.j2objc:j2objc-annotations")
+	cfg.AddRuntimeDep(types.NewPackageName("com.google.common.primitive"), "com.example:unused")
+	cfg.AddExcludedArtifact("@maven//:com_google_j2objc_j2objc_annotations")
+
+	const content = `load("@rules_java//java:defs.bzl", "java_binary", "java_library", "java_test")
+
+java_library(
+    name = "lib",
+    srcs = ["Lib.java"],
+    _imported_packages = ["com.google.common.primitives"],
+    _packages = ["com.example"],
+)
+
+java_binary(
+    name = "bin",
+    main_class = "com.example.Main",
+    _imported_packages = ["com.google.common.primitives"],
+    _packages = ["com.example.main"],
+    runtime_deps = [":lib"],
+)
+
+java_test(
+    name = "LibTest",
+    srcs = ["LibTest.java"],
+    _imported_packages = ["org.junit"],
+    _packages = ["com.example.test"],
+)`
+
+	want := `load("@rules_java//java:defs.bzl", "java_binary", "java_library", "java_test")
+
+java_library(
+    name = "lib",
+    srcs = ["Lib.java"],
+    runtime_deps = ["@maven//:com_google_guava_failureaccess"],
+    deps = ["@maven//:com_google_guava_guava"],
+)
+
+java_binary(
+    name = "bin",
+    main_class = "com.example.Main",
+    runtime_deps = [
+        ":lib",
+        "@maven//:com_google_guava_failureaccess",
+    ],
+    deps = ["@maven//:com_google_guava_guava"],
+)
+
+java_test(
+    name = "LibTest",
+    srcs = ["LibTest.java"],
+    runtime_deps = ["//third_party:junit_engine"],
+    deps = ["@maven//:junit_junit"],
+)`
+
+	f, err := rule.LoadData("BUILD.bazel", "", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imports := make([]interface{}, len(f.Rules))
+	for i, r := range f.Rules {
+		imports[i] = convertImportsAttr(r)
+		ix.AddRule(c, r, f)
+	}
+	ix.Finish()
+	for i, r := range f.Rules {
+		mrslv.Resolver(r, "").Resolve(c, ix, rc, r, imports[i], label.New("", "", r.Name()))
+	}
+	f.Sync()
+
+	got := strings.TrimSpace(string(bzl.Format(f.File)))
+	if got != strings.TrimSpace(want) {
+		dmp := diffmatchpatch.New()
+		diffs := dmp.DiffMain(want, got, true)
+		t.Errorf("Resolve:\n%s", dmp.DiffPrettyText(diffs))
+	}
+}
+
+func TestResolveKotlinCompilerPlugins(t *testing.T) {
+	c, langs, _ := testConfig(t)
+	mrslv, exts := InitTestResolversAndExtensions(langs)
+	ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+
+	serializable := types.NewClassName(types.NewPackageName("kotlinx.serialization"), "Serializable")
+	component := types.NewClassName(types.NewPackageName("org.springframework.stereotype"), "Component")
+	cfg := c.Exts[languageName].(javaconfig.Configs)[""]
+	cfg.AddKotlinCompilerPlugin(serializable, label.New("", "tools", "serialization_plugin"))
+	cfg.AddKotlinCompilerPlugin(component, label.New("", "", "allopen_plugin"))
+
+	const content = `load("@rules_java//java:defs.bzl", "java_library")
+load("@contrib_rules_jvm//java:defs.bzl", "java_test_suite")
+load("@rules_kotlin//kotlin:jvm.bzl", "kt_jvm_library")
+
+kt_jvm_library(
+    name = "model",
+    srcs = ["Model.kt"],
+    plugins = ["//tools:parcelize_plugin"],
+)
+
+java_library(
+    name = "legacy",
+    srcs = ["Legacy.java"],
+)
+
+java_test_suite(
+    name = "model-tests",
+    srcs = ["ModelTest.kt"],
+)`
+
+	f, err := rule.LoadData("BUILD.bazel", "", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotations := map[string][]types.ClassName{
+		"model":       {serializable, component},
+		"legacy":      {serializable},
+		"model-tests": {serializable},
+	}
+	for _, r := range f.Rules {
+		ix.AddRule(c, r, f)
+	}
+	ix.Finish()
+	for _, r := range f.Rules {
+		mrslv.Resolver(r, "").Resolve(c, ix, testRemoteCache(nil), r, types.ResolveInput{
+			PackageNames: sorted_set.NewSortedSetFn([]types.PackageName{}, types.PackageNameLess),
+			Annotations:  sorted_set.NewSortedSetFn(annotations[r.Name()], types.ClassNameLess),
+		}, label.New("", "", r.Name()))
+	}
+
+	for i, want := range [][]string{
+		{":allopen_plugin", "//tools:parcelize_plugin", "//tools:serialization_plugin"},
+		// Kotlin compiler plugins don't run on Java sources.
+		nil,
+		{"//tools:serialization_plugin"},
+	} {
+		if got := f.Rules[i].AttrStrings("plugins"); !reflect.DeepEqual(want, got) {
+			t.Errorf("plugins of %s: want %v, got %v", f.Rules[i].Name(), want, got)
+		}
+	}
+}
+
+func TestResolveJavaRelease(t *testing.T) {
+	const content = `load("@rules_java//java:defs.bzl", "java_library")
+
+java_library(
+    name = "lib",
+    srcs = ["Lib.java"],
+    _imported_packages = [
+        "java.util",
+        "javax.xml.bind",
+        "sun.misc",
+    ],
+    _packages = ["com.example"],
+)`
+
+	for release, want := range map[int][]string{
+		0:  nil,
+		8:  nil,
+		11: {"@maven//:javax_xml_bind_jaxb_api"},
+	} {
+		t.Run(fmt.Sprint(release), func(t *testing.T) {
+			c, langs, _ := testConfig(t)
+			mrslv, exts := InitTestResolversAndExtensions(langs)
+			ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+			c.Exts[languageName].(javaconfig.Configs)[""].SetRelease(release)
+
+			f, err := rule.LoadData("BUILD.bazel", "", []byte(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := f.Rules[0]
+			imports := convertImportsAttr(r)
+			ix.AddRule(c, r, f)
+			ix.Finish()
+			mrslv.Resolver(r, "").Resolve(c, ix, testRemoteCache(nil), r, imports, label.New("", "", r.Name()))
+
+			if got := r.AttrStrings("deps"); !reflect.DeepEqual(want, got) {
+				t.Errorf("deps: want %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestResolvePlatformPackages(t *testing.T) {
+	c, langs, _ := testConfig(t)
+	mrslv, exts := InitTestResolversAndExtensions(langs)
+	ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+
+	cfg := c.Exts[languageName].(javaconfig.Configs)[""]
+	cfg.AddPlatformPackage(types.NewPackageName("android"), "")
+	cfg.AddPlatformPackage(types.NewPackageName("jakarta.servlet"), "//third_party:servlet_api_neverlink")
+
+	const content = `load("@rules_java//java:defs.bzl", "java_library")
+
+java_library(
+    name = "lib",
+    srcs = ["Lib.java"],
+    _imported_packages = [
+        "android.os",
+        "com.google.common.primitives",
+        "jakarta.servlet.http",
+    ],
+    _packages = ["com.example"],
+)`
+
+	f, err := rule.LoadData("BUILD.bazel", "", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := f.Rules[0]
+	imports := convertImportsAttr(r)
+	ix.AddRule(c, r, f)
+	ix.Finish()
+	mrslv.Resolver(r, "").Resolve(c, ix, testRemoteCache(nil), r, imports, label.New("", "", r.Name()))
+
+	want := []string{"//third_party:servlet_api_neverlink", "@maven//:com_google_guava_guava"}
+	if got := r.AttrStrings("deps"); !reflect.DeepEqual(want, got) {
+		t.Errorf("deps: want %v, got %v", want, got)
+	}
+}
+
+func TestResolveUnresolvedImportSeverity(t *testing.T) {
+	const content = `load("@rules_java//java:defs.bzl", "java_library")
+
+java_library(
+    name = "lib",
+    srcs = ["Lib.java"],
+    _imported_packages = [
+        "com.legacy.gone",
+        "com.legacy.util",
+    ],
+    _packages = ["com.example"],
+)`
+
+	for name, tc := range map[string]struct {
+		severities     [][2]string
+		wantErrors     bool
+		wantUnresolved map[string][]string
+	}{
+		"default": {
+			wantErrors: true,
+			wantUnresolved: map[string][]string{
+				javaconfig.SeverityError: {"com.legacy.gone", "com.legacy.util"},
+			},
+		},
+		"warn and ignore": {
+			severities: [][2]string{
+				{"com.legacy.*", javaconfig.SeverityWarn},
+				{"com.legacy.gone", javaconfig.SeverityIgnore},
+			},
+			wantUnresolved: map[string][]string{
+				javaconfig.SeverityWarn: {"com.legacy.util"},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, langs, jLang := testJavaLang(t)
+			mrslv, exts := InitTestResolversAndExtensions(langs)
+			ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+			jLang.mavenResolver = &noExternalMavenResolver{}
+
+			cfg := c.Exts[languageName].(javaconfig.Configs)[""]
+			for _, severity := range tc.severities {
+				if err := cfg.AddUnresolvedImportSeverity(severity[0], severity[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			f, err := rule.LoadData("BUILD.bazel", "", []byte(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := f.Rules[0]
+			imports := convertImportsAttr(r)
+			ix.AddRule(c, r, f)
+			ix.Finish()
+			mrslv.Resolver(r, "").Resolve(c, ix, testRemoteCache(nil), r, imports, label.New("", "", r.Name()))
+
+			if jLang.hasHadErrors != tc.wantErrors {
+				t.Errorf("hasHadErrors: want %v, got %v", tc.wantErrors, jLang.hasHadErrors)
+			}
+			got := make(map[string][]string)
+			for severity, packages := range jLang.unresolvedImports.bySeverity {
+				for pkg, imp := range packages {
+					got[severity] = append(got[severity], pkg)
+					if want := []string{"//:lib"}; !reflect.DeepEqual(want, imp.rules.SortedSlice()) {
+						t.Errorf("rules importing %s: want %v, got %v", pkg, want, imp.rules.SortedSlice())
+					}
+				}
+				sort.Strings(got[severity])
+			}
+			if !reflect.DeepEqual(tc.wantUnresolved, got) {
+				t.Errorf("unresolved imports: want %v, got %v", tc.wantUnresolved, got)
+			}
+		})
+	}
+}
+
+// splitMavenResolver provides com.legacy.split from two artifacts, declaring none of its classes.
+type splitMavenResolver struct {
+	noExternalMavenResolver
+}
+
+func (r *splitMavenResolver) Resolve(pkg types.PackageName, excludedArtifacts map[string]struct{}, mavenRepositoryName string) (label.Label, error) {
+	if pkg.Name == "com.legacy.split" {
+		return label.NoLabel, &maven.MultipleExternalImportsError{PackageName: pkg.Name, PossiblePackages: []string{"@maven//:legacy_a", "@maven//:legacy_b"}}
+	}
+	return r.noExternalMavenResolver.Resolve(pkg, excludedArtifacts, mavenRepositoryName)
+}
+
+func TestResolveAmbiguousImportSeverity(t *testing.T) {
+	const content = `load("@rules_java//java:defs.bzl", "java_library")
+
+java_library(
+    name = "a",
+    srcs = ["A.java"],
+    _packages = [
+        "com.example.dup",
+        "com.example.wild",
+    ],
+)
+
+java_library(
+    name = "b",
+    srcs = ["B.java"],
+    _packages = [
+        "com.example.dup",
+        "com.example.wild",
+    ],
+)
+
+java_library(
+    name = "lib",
+    srcs = ["Lib.java"],
+    _imported_packages = [
+        "com.example.dup",
+        "com.example.wild",
+        "com.legacy.split",
+    ],
+    _packages = ["com.example"],
+)`
+
+	for name, tc := range map[string]struct {
+		severity   string
+		wantErrors bool
+		want       map[string][]string
+	}{
+		"error": {severity: javaconfig.SeverityError, wantErrors: true, want: map[string][]string{
+			javaconfig.SeverityError: {"com.example.dup", "com.example.wild", "com.legacy.split"},
+		}},
+		"warn": {severity: javaconfig.SeverityWarn, want: map[string][]string{
+			javaconfig.SeverityWarn: {"com.example.dup", "com.example.wild", "com.legacy.split"},
+		}},
+		"ignore": {severity: javaconfig.SeverityIgnore, want: map[string][]string{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, langs, jLang := testJavaLang(t)
+			mrslv, exts := InitTestResolversAndExtensions(langs)
+			ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+			jLang.mavenResolver = &splitMavenResolver{}
+			if err := c.Exts[languageName].(javaconfig.Configs)[""].AddUnresolvedImportSeverity("*", tc.severity); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := rule.LoadData("BUILD.bazel", "", []byte(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range f.Rules {
+				setPackagesPrivateAttr(r)
+				ix.AddRule(c, r, f)
+			}
+			ix.Finish()
+			r := f.Rules[2]
+			imports := convertImportsAttr(r)
+			imports.ImportedClasses = sorted_set.NewSortedSetFn([]types.ClassName{types.NewClassName(types.NewPackageName("com.example.dup"), "Thing")}, types.ClassNameLess)
+			mrslv.Resolver(r, "").Resolve(c, ix, testRemoteCache(nil), r, imports, label.New("", "", r.Name()))
+
+			if jLang.hasHadErrors != tc.wantErrors {
+				t.Errorf("hasHadErrors: want %v, got %v", tc.wantErrors, jLang.hasHadErrors)
+			}
+			got := make(map[string][]string)
+			for severity, packages := range jLang.unresolvedImports.bySeverity {
+				for pkg := range packages {
+					got[severity] = append(got[severity], pkg)
+				}
+				sort.Strings(got[severity])
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("unresolved imports: want %v, got %v", tc.want, got)
+			}
+			if imp, ok := jLang.unresolvedImports.bySeverity[tc.severity]["com.legacy.split"]; ok {
+				if want := []string{"@maven//:legacy_a", "@maven//:legacy_b"}; !reflect.DeepEqual(want, imp.providers.SortedSlice()) {
+					t.Errorf("providers: want %v, got %v", want, imp.providers.SortedSlice())
+				}
+			}
+			for _, pkg := range []string{"com.example.dup", "com.example.wild"} {
+				if imp, ok := jLang.unresolvedImports.bySeverity[tc.severity][pkg]; ok {
+					if want := []string{"//:a", "//:b"}; !reflect.DeepEqual(want, imp.providers.SortedSlice()) {
+						t.Errorf("%s providers: want %v, got %v", pkg, want, imp.providers.SortedSlice())
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestResolveFileLibrariesPackageImport(t *testing.T) {
+	c, langs, _ := testJavaLang(t)
+	mrslv, exts := InitTestResolversAndExtensions(langs)
+	ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+	cfgs := c.Exts[languageName].(javaconfig.Configs)
+	cfgs["app"] = cfgs[""].NewChild()
+	cfgs["src"] = cfgs[""].NewChild()
+	if err := cfgs["src"].SetModuleGranularity("file"); err != nil {
+		t.Fatal(err)
+	}
+
+	const libraries = `load("@rules_java//java:defs.bzl", "java_library")
+
+java_library(
+    name = "Api-lib",
+    srcs = ["Api.java"],
+    _packages = ["com.example"],
+)
+
+java_library(
+    name = "Client-lib",
+    srcs = ["Client.java"],
+    _packages = ["com.example"],
+)`
+	const app = `load("@rules_java//java:defs.bzl", "java_library")
+
+java_library(
+    name = "app",
+    srcs = ["App.java"],
+    _imported_packages = ["com.example"],
+    _packages = ["com.example.app"],
+)`
+
+	var appRule *rule.Rule
+	for pkg, content := range map[string]string{"src": libraries, "app": app} {
+		f, err := rule.LoadData(pkg+"/BUILD.bazel", pkg, []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range f.Rules {
+			setPackagesPrivateAttr(r)
+			ix.AddRule(c, r, f)
+		}
+		if pkg == "app" {
+			appRule = f.Rules[0]
+		}
+	}
+	ix.Finish()
+	imports := convertImportsAttr(appRule)
+	mrslv.Resolver(appRule, "").Resolve(c, ix, testRemoteCache(nil), appRule, imports, label.New("", "app", appRule.Name()))
+
+	// The wildcard import may use any library of the package.
+	want := []string{"//src:Api-lib", "//src:Client-lib"}
+	if got := appRule.AttrStrings("deps"); !reflect.DeepEqual(want, got) {
+		t.Errorf("deps: want %v, got %v", want, got)
+	}
+}
+
+// candidateMavenResolver indexes a single class, in a package no import uses, and provides
+// the com.example.old package from an artifact the test excludes.
+type candidateMavenResolver struct {
+	noExternalMavenResolver
+}
+
+func (r *candidateMavenResolver) Resolve(pkg types.PackageName, excludedArtifacts map[string]struct{}, mavenRepositoryName string) (label.Label, error) {
+	l := label.New(mavenRepositoryName, "", "com_example_legacy")
+	if _, excluded := excludedArtifacts[l.String()]; pkg.Name != "com.example.old" || excluded {
+		return label.NoLabel, &maven.NoExternalImportsError{PackageName: pkg.Name}
+	}
+	return l, nil
+}
+
+func (r *candidateMavenResolver) ClassesWithSimpleName(simpleName string) []string {
+	if simpleName == "Helper" {
+		return []string{"com.vendor.util.Helper"}
+	}
+	return nil
+}
+
+func (r *candidateMavenResolver) ResolveClass(className types.ClassName, excludedArtifacts map[string]struct{}, mavenRepositoryName string) (label.Label, error) {
+	if className.FullyQualifiedClassName() == "com.vendor.util.Helper" {
+		return label.New(mavenRepositoryName, "", "com_vendor_util"), nil
+	}
+	return label.NoLabel, nil
+}
+
+func TestResolveSuggestsCandidateProviders(t *testing.T) {
+	c, langs, jLang := testJavaLang(t)
+	mrslv, exts := InitTestResolversAndExtensions(langs)
+	ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+	jLang.mavenResolver = &candidateMavenResolver{}
+	relocated, err := types.ParseClassName("com.example.relocated.Helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := types.ParseClassName("com.example.fixtures.Helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jLang.classExportCache["//relocated"] = classExportInfo{classes: []types.ClassName{*relocated}}
+	jLang.classExportCache["//fixtures"] = classExportInfo{classes: []types.ClassName{*fixture}, testonly: true}
+	c.Exts[languageName].(javaconfig.Configs)[""].AddExcludedArtifact("@maven//:com_example_legacy")
+
+	const content = `load("@rules_java//java:defs.bzl", "java_library")
+
+java_library(
+    name = "lib",
+    srcs = ["Lib.java"],
+    _imported_packages = ["com.example.old"],
+    _packages = ["com.example"],
+)`
+	f, err := rule.LoadData("BUILD.bazel", "", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := f.Rules[0]
+	imports := convertImportsAttr(r)
+	helper, err := types.ParseClassName("com.example.old.Helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imports.ImportedClasses = sorted_set.NewSortedSetFn([]types.ClassName{*helper}, types.ClassNameLess)
+	ix.AddRule(c, r, f)
+	ix.Finish()
+	mrslv.Resolver(r, "").Resolve(c, ix, testRemoteCache(nil), r, imports, label.New("", "", r.Name()))
+
+	var out bytes.Buffer
+	jLang.unresolvedImports.log(zerolog.New(&out))
+	want := `{"level":"error","package":"com.example.old","from rules":["//:lib"],"classes":["Helper"],"message":"Unable to find package for import in any dependency"}
+{"level":"error","package":"com.example.old","message":"The package is provided by excluded artifacts, or by more than one dependency. If the import is correct, append one of the following to BUILD.bazel:"}
+{"level":"error","message":"# gazelle:resolve java com.example.old @maven//:com_example_legacy"}
+{"level":"error","package":"com.example.old","candidates":["com.example.relocated.Helper (//relocated)","com.vendor.util.Helper (@maven//:com_vendor_util)"],"message":"Classes with the same name exist in other packages. Check the import."}
+`
+	if got := out.String(); got != want {
+		t.Errorf("log: want\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestResolveDependencyRules(t *testing.T) {
+	c, langs, jLang := testJavaLang(t)
+	mrslv, exts := InitTestResolversAndExtensions(langs)
+	ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+	var out bytes.Buffer
+	jLang.logger = zerolog.New(&out).Level(zerolog.ErrorLevel)
+
+	cfg := c.Exts[languageName].(javaconfig.Configs)[""]
+	if err := cfg.AddDependencyRule("deny", "..domain..", "..infra.."); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.AddDependencyRule("allow", "..domain..", "com.example.infra.api"); err != nil {
+		t.Fatal(err)
+	}
+
+	const content = `load("@rules_java//java:defs.bzl", "java_library")
+
+java_library(
+    name = "infra",
+    srcs = ["Infra.java"],
+    _imported_packages = ["com.google.common.primitives"],
+    _packages = ["com.example.infra.api"],
+)
+
+java_library(
+    name = "db",
+    srcs = ["Db.java"],
+    _packages = ["com.example.infra.db"],
+)
+
+java_library(
+    name = "domain",
+    srcs = ["Domain.java"],
+    _imported_packages = [
+        "com.example.infra.api",
+        "com.example.infra.db",
+        "com.google.common.primitives",
+    ],
+    _packages = ["com.example.domain"],
+)`
+	f, err := rule.LoadData("BUILD.bazel", "", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imports := make([]interface{}, len(f.Rules))
+	for i, r := range f.Rules {
+		setPackagesPrivateAttr(r)
+		resolveInput := convertImportsAttr(r)
+		if r.Name() == "domain" {
+			var classes []types.ClassName
+			for _, name := range []string{"com.example.infra.db.Repository", "com.example.infra.db.Schema"} {
+				className, err := types.ParseClassName(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				classes = append(classes, *className)
+			}
+			resolveInput.ImportedClasses = sorted_set.NewSortedSetFn(classes, types.ClassNameLess)
+		}
+		imports[i] = resolveInput
+		ix.AddRule(c, r, f)
+	}
+	ix.Finish()
+	for i, r := range f.Rules {
+		mrslv.Resolver(r, "").Resolve(c, ix, testRemoteCache(nil), r, imports[i], label.New("", "", r.Name()))
+	}
+
+	if want, got := []string{":infra", "@maven//:com_google_guava_guava"}, f.Rules[2].AttrStrings("deps"); !reflect.DeepEqual(want, got) {
+		t.Errorf("deps: want %v, got %v", want, got)
+	}
+	if !jLang.hasHadErrors {
+		t.Errorf("expected the denied dependency to be an error")
+	}
+	want := `{"level":"error","from rule":"//:domain","package":"com.example.domain","import":"com.example.infra.db","classes":["com.example.infra.db.Repository","com.example.infra.db.Schema"],"dependency":"//:db","rule":"deny ..domain.. ..infra..","message":"Dependency denied by java_dependency_rule directive"}
+`
+	if got := out.String(); got != want {
+		t.Errorf("log: want\n%s\ngot\n%s", want, got)
+	}
+}
@@ -0,0 +1,151 @@
+package gazelle
+
+import (
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// providedServices returns the services which registrations register an implementation of
+// among classes.
+func providedServices(registrations map[string][]string, classes *sorted_set.SortedSet[types.ClassName]) []string {
+	var services []string
+	for service, implementations := range registrations {
+		for _, implementation := range implementations {
+			className, err := types.ParseClassName(implementation)
+			if err != nil {
+				continue
+			}
+			if classes.Contains(*className) || classes.Contains(types.NewClassName(className.PackageName(), className.BareOuterClassName())) {
+				services = append(services, service)
+				break
+			}
+		}
+	}
+	return sorted_set.NewSortedSet(services).SortedSlice()
+}
+
+// serviceLoaders records the services each package loads with a ServiceLoader and the packages
+// it imports, to find the services loaded by the transitive imports of binaries and tests.
+type serviceLoaders struct {
+	// production and test map the packages of the production and test sources to their services.
+	production map[types.PackageName]*serviceLoaderPackage
+	test       map[types.PackageName]*serviceLoaderPackage
+}
+
+type serviceLoaderPackage struct {
+	loaded  *sorted_set.SortedSet[types.ClassName]
+	imports *sorted_set.SortedSet[types.PackageName]
+}
+
+func newServiceLoaders() *serviceLoaders {
+	return &serviceLoaders{
+		production: make(map[types.PackageName]*serviceLoaderPackage),
+		test:       make(map[types.PackageName]*serviceLoaderPackage),
+	}
+}
+
+// addPackage records the services pkg loads and the packages it imports. The directories of a
+// split package are merged.
+func (s *serviceLoaders) addPackage(pkg *java.Package) {
+	packages := s.production
+	if pkg.TestPackage {
+		packages = s.test
+	}
+	p, ok := packages[pkg.Name]
+	if !ok {
+		p = &serviceLoaderPackage{
+			loaded:  sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess),
+			imports: sorted_set.NewSortedSetFn([]types.PackageName{}, types.PackageNameLess),
+		}
+		packages[pkg.Name] = p
+	}
+	p.loaded.AddAll(pkg.LoadedServices)
+	p.imports.AddAll(pkg.ImportedPackagesWithoutSpecificClasses)
+	for _, className := range pkg.ImportedClasses.SortedSlice() {
+		p.imports.Add(className.PackageName())
+	}
+}
+
+// loadedServices returns the services loaded by pkgs and the production packages they import,
+// transitively. The test sources of pkgs are included for tests.
+//
+// Only the packages recorded in this run are followed: when Gazelle runs on some directories of
+// the repository, the services loaded by the packages of the others are missed.
+func (s *serviceLoaders) loadedServices(pkgs *sorted_set.SortedSet[types.PackageName], test bool) *sorted_set.SortedSet[types.ClassName] {
+	services := sorted_set.NewSortedSetFn([]types.ClassName{}, types.ClassNameLess)
+	visited := make(map[types.PackageName]bool)
+	var queue []*serviceLoaderPackage
+	for _, pkg := range pkgs.SortedSlice() {
+		if p, ok := s.test[pkg]; ok && test {
+			queue = append(queue, p)
+		}
+	}
+	visit := func(pkg types.PackageName) {
+		if p, ok := s.production[pkg]; ok && !visited[pkg] {
+			visited[pkg] = true
+			queue = append(queue, p)
+		}
+	}
+	for _, pkg := range pkgs.SortedSlice() {
+		visit(pkg)
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		services.AddAll(p.loaded)
+		for _, imp := range p.imports.SortedSlice() {
+			visit(imp)
+		}
+	}
+	return services
+}
+
+// populateServiceLoaderRuntimeDeps adds to the runtime_deps of a binary or test the libraries of
+// the repository providing the services loaded by its packages or the packages they import,
+// transitively, if java_service_loader_runtime_deps is enabled. Tests also get the test
+// libraries providing them.
+func (jr *Resolver) populateServiceLoaderRuntimeDeps(c *config.Config, ix *resolve.RuleIndex, pc *javaconfig.Config, resolveInput types.ResolveInput, r *rule.Rule, from label.Label) {
+	if !pc.ServiceLoaderRuntimeDeps() {
+		return
+	}
+	isTest := pc.IsTestRule(r.Kind())
+	if r.Kind() != "java_binary" && !isTest {
+		return
+	}
+	// A testonly binary runs a main class of the test sources.
+	includeTestSources := isTest || ruleIsTestOnly(r)
+
+	pkgs := sorted_set.NewSortedSetFn([]types.PackageName{}, types.PackageNameLess)
+	pkgs.AddAll(resolveInput.PackageNames)
+	pkgs.AddAll(resolveInput.ImportedPackageNames)
+
+	prefixes := []string{serviceImportPrefix}
+	if includeTestSources {
+		prefixes = append(prefixes, testServiceImportPrefix)
+	}
+
+	runtimeDeps := sorted_set.NewSortedSetFn([]label.Label{}, labelLess)
+	for _, service := range jr.lang.serviceLoaders.loadedServices(pkgs, includeTestSources).SortedSlice() {
+		for _, prefix := range prefixes {
+			spec := resolve.ImportSpec{Lang: languageName, Imp: prefix + service.FullyQualifiedClassName()}
+			for _, match := range ix.FindRulesByImportWithConfig(c, spec, languageName) {
+				if match.Label.Abs(from.Repo, from.Pkg) == from {
+					continue
+				}
+				dep := simplifyLabel(c.RepoName, match.Label, from)
+				if jr.recordDependency(c, pc, r, from, resolveInput.PackageNames, "runtime_deps", dep, service.PackageName(), &service, []types.ClassName{service}, routeService) {
+					runtimeDeps.Add(dep)
+				}
+			}
+		}
+	}
+	if runtimeDeps.Len() > 0 {
+		setLabelAttrIncludingExistingValues(r, "runtime_deps", runtimeDeps)
+	}
+}
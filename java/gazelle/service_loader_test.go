@@ -0,0 +1,101 @@
+package gazelle
+
+import (
+	"testing"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/java"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvidedServices(t *testing.T) {
+	pkg := types.NewPackageName("com.example.codecs")
+	registrations := map[string][]string{
+		"com.example.spi.Codec":     {"com.example.codecs.JsonCodec", "com.example.other.YamlCodec"},
+		"com.example.spi.Transport": {"com.example.codecs.Transports.Http"},
+		"com.example.spi.Unused":    {"com.example.other.Unused"},
+	}
+	classes := sorted_set.NewSortedSetFn([]types.ClassName{types.NewClassName(pkg, "JsonCodec"), types.NewClassName(pkg, "Transports")}, types.ClassNameLess)
+
+	require.Equal(t, []string{"com.example.spi.Codec", "com.example.spi.Transport"}, providedServices(registrations, classes))
+}
+
+func TestServiceLoaderRuntimeDeps(t *testing.T) {
+	c, langs, jLang := testJavaLang(t)
+	cfg := c.Exts[languageName].(javaconfig.Configs)[""]
+
+	codec := types.NewClassName(types.NewPackageName("com.example.spi"), "Codec")
+	transport := types.NewClassName(types.NewPackageName("com.example.spi"), "Transport")
+	for _, pkg := range []struct {
+		name    string
+		test    bool
+		imports []string
+		loaded  []types.ClassName
+	}{
+		{name: "com.example.app", imports: []string{"com.example.core"}},
+		{name: "com.example.core", imports: []string{"com.example.spi"}, loaded: []types.ClassName{codec}},
+		{name: "com.example.app", test: true, imports: []string{"com.example.app"}, loaded: []types.ClassName{transport}},
+	} {
+		jLang.serviceLoaders.addPackage(&java.Package{
+			Name:                                   types.NewPackageName(pkg.name),
+			TestPackage:                            pkg.test,
+			ImportedPackagesWithoutSpecificClasses: stringsToPackageNames(pkg.imports),
+			LoadedServices:                         sorted_set.NewSortedSetFn(pkg.loaded, types.ClassNameLess),
+		})
+	}
+
+	f := rule.EmptyFile("BUILD.bazel", "")
+	var res language.GenerateResult
+	for _, lib := range []struct {
+		name     string
+		pkg      string
+		testOnly bool
+		services []string
+	}{
+		{name: "app", pkg: "com.example.app"},
+		{name: "codecs", pkg: "com.example.codecs", services: []string{codec.FullyQualifiedClassName()}},
+		{name: "transports", pkg: "com.example.transports", services: []string{transport.FullyQualifiedClassName()}},
+		{name: "fakes", pkg: "com.example.fakes", testOnly: true, services: []string{codec.FullyQualifiedClassName()}},
+	} {
+		r := rule.NewRule("java_library", lib.name)
+		r.SetPrivateAttr(packagesKey, []types.ResolvableJavaPackage{*types.NewResolvableJavaPackage(types.NewPackageName(lib.pkg), lib.testOnly, false)})
+		if lib.testOnly {
+			r.SetAttr("testonly", true)
+		}
+		if lib.services != nil {
+			r.SetPrivateAttr(servicesKey, lib.services)
+		}
+		res.Gen = append(res.Gen, r)
+		res.Imports = append(res.Imports, types.ResolveInput{})
+	}
+	jLang.generateJavaBinary(f, types.NewClassName(types.NewPackageName("com.example.app"), "Main"), "app", false, nil, &res)
+	jLang.generateJavaTestSuite(f, "app-tests", []string{"AppTest.java"}, stringsToPackageNames([]string{"com.example.app"}), "maven", stringsToPackageNames([]string{"com.example.app"}), nil, nil, nil, nil, false, nil, &res)
+
+	mrslv, exts := InitTestResolversAndExtensions(langs)
+	ix := resolve.NewRuleIndex(mrslv.Resolver, exts...)
+	for _, r := range res.Gen {
+		ix.AddRule(c, r, f)
+	}
+	ix.Finish()
+
+	resolveAll := func() {
+		for i, r := range res.Gen {
+			mrslv.Resolver(r, "").Resolve(c, ix, testRemoteCache(nil), r, res.Imports[i], label.New("", "", r.Name()))
+		}
+	}
+
+	resolveAll()
+	require.Equal(t, []string{":app"}, res.Gen[4].AttrStrings("runtime_deps"), "the directive is disabled by default")
+
+	cfg.SetServiceLoaderRuntimeDeps(true)
+	resolveAll()
+	require.Equal(t, []string{":app", ":codecs"}, res.Gen[4].AttrStrings("runtime_deps"), "the binary loads codecs through its imports, but not the test providers")
+	require.Equal(t, []string{":codecs", ":fakes", ":transports"}, res.Gen[5].AttrStrings("runtime_deps"), "the tests also load transports, and get the test providers")
+	require.Empty(t, res.Gen[0].AttrStrings("runtime_deps"), "libraries aren't wired")
+}
@@ -0,0 +1,59 @@
+package gazelle
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+)
+
+// serviceRegistrations returns the implementations registered for each service by the
+// META-INF/services files of the resources of the sourceset of cfg, with nested classes named
+// like in the source.
+func (l javaLang) serviceRegistrations(cfg *javaconfig.Config) map[string][]string {
+	if cfg.SourcesetRoot() == "" {
+		return nil
+	}
+	servicesDir := filepath.Join(cfg.RepoRoot(), filepath.FromSlash(path.Join(cfg.SourcesetRoot(), "resources", "META-INF", "services")))
+	if registrations, ok := l.serviceRegistrationsCache[servicesDir]; ok {
+		return registrations
+	}
+	registrations := readServiceRegistrations(servicesDir)
+	l.serviceRegistrationsCache[servicesDir] = registrations
+	return registrations
+}
+
+// readServiceRegistrations reads the service provider configuration files of servicesDir.
+func readServiceRegistrations(servicesDir string) map[string][]string {
+	entries, err := os.ReadDir(servicesDir)
+	if err != nil {
+		return nil
+	}
+
+	registrations := make(map[string][]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(servicesDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			line := scanner.Text()
+			if i := strings.Index(line, "#"); i >= 0 {
+				line = line[:i]
+			}
+			if line = strings.TrimSpace(line); line != "" {
+				service := strings.ReplaceAll(entry.Name(), "$", ".")
+				registrations[service] = append(registrations[service], strings.ReplaceAll(line, "$", "."))
+			}
+		}
+	}
+	return registrations
+}
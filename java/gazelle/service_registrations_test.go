@@ -0,0 +1,26 @@
+package gazelle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadServiceRegistrations(t *testing.T) {
+	servicesDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(servicesDir, "com.example.spi.Codec"), []byte(`# Codecs of the library
+com.example.codecs.JsonCodec
+com.example.codecs.Codecs$Xml # a nested codec
+
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(servicesDir, "com.example.spi.Outer$Service"), []byte("com.example.codecs.Impl\n"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(servicesDir, "nested"), 0o755))
+
+	require.Equal(t, map[string][]string{
+		"com.example.spi.Codec":         {"com.example.codecs.JsonCodec", "com.example.codecs.Codecs.Xml"},
+		"com.example.spi.Outer.Service": {"com.example.codecs.Impl"},
+	}, readServiceRegistrations(servicesDir))
+	require.Nil(t, readServiceRegistrations(filepath.Join(servicesDir, "missing")))
+}
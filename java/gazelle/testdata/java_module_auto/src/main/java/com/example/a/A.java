@@ -0,0 +1,9 @@
+package com.example.a;
+
+import com.example.b.B;
+
+public class A {
+  public B makeB() {
+    return new B();
+  }
+}
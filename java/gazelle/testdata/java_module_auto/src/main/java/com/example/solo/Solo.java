@@ -0,0 +1,11 @@
+package com.example.solo;
+
+import com.example.a.A;
+import com.example.util.Util;
+
+public class Solo {
+  public A useA() {
+    Util.log("useA");
+    return new A();
+  }
+}
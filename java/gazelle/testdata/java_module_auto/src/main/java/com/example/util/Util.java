@@ -0,0 +1,7 @@
+package com.example.util;
+
+public class Util {
+  public static void log(String message) {
+    System.out.println(message);
+  }
+}
@@ -0,0 +1,9 @@
+package com.example.app;
+
+public interface Api {
+  String greet();
+
+  static Api create() {
+    return new Impl();
+  }
+}
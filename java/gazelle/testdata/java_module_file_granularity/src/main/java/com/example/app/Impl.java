@@ -0,0 +1,8 @@
+package com.example.app;
+
+final class Impl implements Api {
+  @Override
+  public String greet() {
+    return "hello";
+  }
+}
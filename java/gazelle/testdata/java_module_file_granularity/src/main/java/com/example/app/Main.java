@@ -0,0 +1,11 @@
+package com.example.app;
+
+import com.example.marker.Marker;
+
+@Marker
+public class Main {
+  public static void main(String[] args) {
+    Api api = Api.create();
+    System.out.println(api.greet());
+  }
+}
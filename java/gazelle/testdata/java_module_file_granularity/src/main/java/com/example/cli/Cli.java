@@ -0,0 +1,10 @@
+package com.example.cli;
+
+import com.example.app.*;
+
+public class Cli {
+  public String run() {
+    Api api = Api.create();
+    return api.greet();
+  }
+}
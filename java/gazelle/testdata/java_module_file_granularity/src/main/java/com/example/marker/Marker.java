@@ -0,0 +1,3 @@
+package com.example.marker;
+
+public @interface Marker {}
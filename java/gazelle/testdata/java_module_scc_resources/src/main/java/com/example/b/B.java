@@ -0,0 +1,9 @@
+package com.example.b;
+
+import com.example.a.A;
+
+public class B {
+  public A makeA() {
+    return new A();
+  }
+}
@@ -0,0 +1,10 @@
+package com.example.hello;
+
+import com.legacy.gone.Removed;
+import com.legacy.util.Helper;
+
+public class Hello {
+    public static void sayHi() {
+        System.out.println("Hi!");
+    }
+}
@@ -0,0 +1,117 @@
+package gazelle
+
+import (
+	"sort"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/javaconfig"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/types"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/rs/zerolog"
+)
+
+// unresolvedImports collects the imports no dependency provides, so they can be reported
+// together, sorted by package, once every rule has been resolved.
+type unresolvedImports struct {
+	// bySeverity maps a severity, then an imported package, to the rules importing it.
+	bySeverity map[string]map[string]*unresolvedImport
+}
+
+type unresolvedImport struct {
+	rules   *sorted_set.SortedSet[string]
+	classes *sorted_set.SortedSet[string]
+	// providers are the labels a resolve directive can select for the package: the excluded
+	// Maven artifacts providing it, or the several dependencies providing a split package.
+	providers *sorted_set.SortedSet[string]
+	// candidates maps classes with the same simple name as an imported class, but in
+	// another package, to the label providing them.
+	candidates map[string]string
+}
+
+func newUnresolvedImports() *unresolvedImports {
+	return &unresolvedImports{bySeverity: make(map[string]map[string]*unresolvedImport)}
+}
+
+func (u *unresolvedImports) add(severity string, pkg types.PackageName, from label.Label, classes []string, providers []string, candidates map[string]string) {
+	packages, ok := u.bySeverity[severity]
+	if !ok {
+		packages = make(map[string]*unresolvedImport)
+		u.bySeverity[severity] = packages
+	}
+	imp, ok := packages[pkg.Name]
+	if !ok {
+		imp = &unresolvedImport{
+			rules:      sorted_set.NewSortedSet([]string{}),
+			classes:    sorted_set.NewSortedSet([]string{}),
+			providers:  sorted_set.NewSortedSet([]string{}),
+			candidates: make(map[string]string),
+		}
+		packages[pkg.Name] = imp
+	}
+	imp.rules.Add(from.String())
+	for _, class := range classes {
+		imp.classes.Add(class)
+	}
+	for _, provider := range providers {
+		imp.providers.Add(provider)
+	}
+	for class, l := range candidates {
+		imp.candidates[class] = l
+	}
+}
+
+// log logs one line per unresolved package, warnings first, each group sorted by package.
+func (u *unresolvedImports) log(logger zerolog.Logger) {
+	for _, severity := range []string{javaconfig.SeverityWarn, javaconfig.SeverityError} {
+		packages := u.bySeverity[severity]
+		names := make([]string, 0, len(packages))
+		for name := range packages {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		level := zerolog.WarnLevel
+		if severity == javaconfig.SeverityError {
+			level = zerolog.ErrorLevel
+		}
+		for _, name := range names {
+			imp := packages[name]
+			logger.WithLevel(level).
+				Str("package", name).
+				Strs("from rules", imp.rules.SortedSlice()).
+				Strs("classes", imp.classes.SortedSlice()).
+				Msg("Unable to find package for import in any dependency")
+			imp.logCandidates(logger, level, name)
+		}
+	}
+}
+
+// logCandidates suggests the providers of pkg which a resolve directive can select, and lists the classes with the same simple names in other packages, which usually
+// means the import is misspelled or the package was relocated.
+func (imp *unresolvedImport) logCandidates(logger zerolog.Logger, level zerolog.Level, pkg string) {
+	if imp.providers.Len() > 0 {
+		logger.WithLevel(level).
+			Str("package", pkg).
+			Msg("The package is provided by excluded artifacts, or by more than one dependency. If the import is correct, append one of the following to BUILD.bazel:")
+		for _, l := range imp.providers.SortedSlice() {
+			logger.WithLevel(level).Msgf("# gazelle:resolve java %s %s", pkg, l)
+		}
+	}
+
+	if len(imp.candidates) == 0 {
+		return
+	}
+	classes := make([]string, 0, len(imp.candidates))
+	for class := range imp.candidates {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	candidates := make([]string, 0, len(classes))
+	for _, class := range classes {
+		candidates = append(candidates, class+" ("+imp.candidates[class]+")")
+	}
+	logger.WithLevel(level).
+		Str("package", pkg).
+		Strs("candidates", candidates).
+		Msg("Classes with the same name exist in other packages. Check the import.")
+}
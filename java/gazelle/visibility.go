@@ -0,0 +1,278 @@
+package gazelle
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bazel-contrib/rules_jvm/java/gazelle/private/sorted_set"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
+	"github.com/rs/zerolog"
+)
+
+// visibilityInference collects the packages depending on each generated library, to set its
+// visibility to the smallest set of entries covering them once every rule has been resolved.
+type visibilityInference struct {
+	// packages are the Bazel packages of the repository.
+	packages map[string]bool
+	// generated are the packages rules were generated for in this run. Unless every package
+	// is, some consumers may not have been resolved, so visibility is only widened.
+	generated map[string]bool
+	// existing maps the label of each library of a generated package to its visibility in
+	// the build file before this run, if it had one.
+	existing map[string][]string
+	// fileRules maps the label of each library whose visibility may be inferred to the rule of
+	// its build file, which is the one written out.
+	fileRules map[string]*rule.Rule
+	// targets maps the label of each generated library whose visibility is inferred to the
+	// visibility labels added to the inferred ones.
+	targets map[string][]string
+	// consumers maps the label of a library to the packages depending on it.
+	consumers map[string]*sorted_set.SortedSet[string]
+}
+
+func newVisibilityInference() *visibilityInference {
+	return &visibilityInference{
+		packages:  make(map[string]bool),
+		generated: make(map[string]bool),
+		existing:  make(map[string][]string),
+		fileRules: make(map[string]*rule.Rule),
+		targets:   make(map[string][]string),
+		consumers: make(map[string]*sorted_set.SortedSet[string]),
+	}
+}
+
+func (vi *visibilityInference) addPackage(pkg string) {
+	vi.packages[pkg] = true
+}
+
+// addGeneratedPackage records that rules are generated for pkg, whose build file before this
+// run is f, or nil if it has none.
+func (vi *visibilityInference) addGeneratedPackage(pkg string, f *rule.File) {
+	vi.generated[pkg] = true
+	if f == nil {
+		return
+	}
+	for _, r := range f.Rules {
+		if r.Attr("visibility") != nil {
+			vi.existing[label.New("", pkg, r.Name()).String()] = r.AttrStrings("visibility")
+		}
+	}
+}
+
+// partialRun returns whether some packages of the repository weren't generated in this run,
+// such as when Gazelle is run on a sub-directory.
+func (vi *visibilityInference) partialRun() bool {
+	for pkg := range vi.packages {
+		if !vi.generated[pkg] {
+			return true
+		}
+	}
+	return false
+}
+
+// addLibrary records the rule of the build file for a library whose visibility may be inferred.
+func (vi *visibilityInference) addLibrary(pkg string, r *rule.Rule) {
+	vi.packages[pkg] = true
+	vi.fileRules[label.New("", pkg, r.Name()).String()] = r
+}
+
+// recordRule records the libraries a resolved rule depends on. If the rule is itself a
+// library whose visibility is inferred, extra are the visibility labels to add to it.
+func (vi *visibilityInference) recordRule(from label.Label, r *rule.Rule, infer bool, extra []string) {
+	if len(vi.fileRules) == 0 {
+		return
+	}
+	fromKey := label.New("", from.Pkg, from.Name).String()
+	if _, ok := vi.fileRules[fromKey]; ok && infer {
+		vi.targets[fromKey] = extra
+	}
+	for _, attr := range dependencyAttrs {
+		for _, s := range r.AttrStrings(attr) {
+			l, err := label.Parse(s)
+			if err != nil || (l.Repo != "" && l.Repo != from.Repo) {
+				continue
+			}
+			l = l.Abs(from.Repo, from.Pkg)
+			key := label.New("", l.Pkg, l.Name).String()
+			if _, ok := vi.fileRules[key]; ok {
+				addToSetMap(vi.consumers, key, from.Pkg)
+			}
+		}
+	}
+}
+
+// apply sets the visibility of every library whose visibility is inferred. The consumers of
+// other repositories, and those which aren't resolved by this extension or are outside of the
+// packages generated in a partial run, aren't known: the visibility a library had is kept
+// if this is a partial run, or if no consumer was found, rather than made private.
+func (vi *visibilityInference) apply(logger zerolog.Logger) {
+	if len(vi.targets) == 0 {
+		return
+	}
+	packagesUnder := countPackagesUnder(vi.packages)
+	partial := vi.partialRun()
+	for key, extra := range vi.targets {
+		r := vi.fileRules[key]
+		attr := r.Attr("visibility")
+		if attr != nil && (rule.ShouldKeep(attr) || isKeepAttr(r.AttrComments("visibility"))) {
+			continue
+		}
+
+		l, _ := label.Parse(key)
+		var consumers []string
+		if set, ok := vi.consumers[key]; ok {
+			consumers = set.SortedSlice()
+		}
+
+		var kept []bzl.Expr
+		values := sorted_set.NewSortedSet([]string{})
+		if list, ok := attr.(*bzl.ListExpr); ok {
+			for _, e := range list.List {
+				if str, ok := e.(*bzl.StringExpr); ok && rule.ShouldKeep(e) {
+					kept = append(kept, e)
+					values.Add(str.Value)
+				}
+			}
+		}
+		minimal := minimalVisibility(l.Pkg, consumers, packagesUnder)
+		inferred := append(append([]string{}, extra...), minimal...)
+		if existing, ok := vi.existing[key]; ok && (partial || !canReplaceVisibility(minimal, existing)) {
+			inferred = append(inferred, existing...)
+		}
+		var added []string
+		for _, v := range inferred {
+			if !values.Contains(v) {
+				values.Add(v)
+				added = append(added, v)
+			}
+		}
+		if values.Len() > 1 && values.Contains("//visibility:private") {
+			// Bazel does not allow private to be combined with other entries.
+			added = removeString(added, "//visibility:private")
+		}
+		sort.Strings(added)
+
+		list := &bzl.ListExpr{List: kept}
+		for _, v := range added {
+			list.List = append(list.List, &bzl.StringExpr{Value: v})
+		}
+		logger.Debug().Str("rule", key).Strs("consumers", consumers).Msg("inferred visibility")
+		// See Resolve for why the attribute is deleted first.
+		r.DelAttr("visibility")
+		r.SetAttr("visibility", list)
+	}
+}
+
+// canReplaceVisibility returns whether the minimal visibility can replace existing, which it
+// can unless it would make private a library which wasn't.
+func canReplaceVisibility(minimal, existing []string) bool {
+	if len(minimal) != 1 || minimal[0] != "//visibility:private" {
+		return true
+	}
+	return len(existing) == 0 || (len(existing) == 1 && existing[0] == "//visibility:private")
+}
+
+// isKeepAttr returns whether the comments of an attribute, such as `visibility = [...],  # keep`,
+// mark it to be kept.
+func isKeepAttr(comments *bzl.Comments) bool {
+	for _, c := range append(comments.Before, comments.Suffix...) {
+		if text := strings.TrimSpace(strings.TrimPrefix(c.Token, "#")); text == "keep" || strings.HasPrefix(text, "keep: ") {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(values []string, value string) []string {
+	out := values[:0]
+	for _, v := range values {
+		if v != value {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// countPackagesUnder maps each directory containing packages to the number of packages in it
+// and below.
+func countPackagesUnder(packages map[string]bool) map[string]int {
+	counts := make(map[string]int)
+	for pkg := range packages {
+		for _, dir := range enclosingDirs(pkg) {
+			counts[dir]++
+		}
+	}
+	return counts
+}
+
+// enclosingDirs returns pkg followed by the directories containing it, up to the root "".
+func enclosingDirs(pkg string) []string {
+	dirs := []string{pkg}
+	for dir := pkg; dir != ""; {
+		dir = path.Dir(dir)
+		if dir == "." {
+			dir = ""
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// minimalVisibility returns the smallest set of visibility labels allowing the packages
+// consumers, and no other package, to depend on a library in the package own. packagesUnder
+// is the number of packages in and below each directory, see countPackagesUnder.
+// Consumers in a directory are grouped into a "__subpackages__" entry when it covers at least
+// two of them and no package outside of them and own; the others get a "__pkg__" entry each.
+// A library only used in its own package is private.
+func minimalVisibility(own string, consumers []string, packagesUnder map[string]int) []string {
+	allowed := []string{own}
+	var others []string
+	for _, pkg := range consumers {
+		if pkg != own {
+			allowed = append(allowed, pkg)
+			others = append(others, pkg)
+		}
+	}
+	if len(others) == 0 {
+		return []string{"//visibility:private"}
+	}
+
+	// covers returns whether every package under dir is allowed, and how many consumers are.
+	covers := func(dir string) (bool, int) {
+		allowedUnder, consumersUnder := 0, 0
+		for _, pkg := range allowed {
+			if isUnderDir(pkg, dir) && packagesUnder[pkg] > 0 {
+				allowedUnder++
+			}
+		}
+		for _, pkg := range others {
+			if isUnderDir(pkg, dir) {
+				consumersUnder++
+			}
+		}
+		return allowedUnder == packagesUnder[dir], consumersUnder
+	}
+
+	entries := sorted_set.NewSortedSet([]string{})
+	for _, pkg := range others {
+		// Try the directories containing pkg from the outermost one, so that the largest
+		// group is found.
+		dirs := enclosingDirs(pkg)
+		entry := "//" + pkg + ":__pkg__"
+		for i := len(dirs) - 1; i >= 0; i-- {
+			if ok, count := covers(dirs[i]); ok && count >= 2 {
+				entry = "//" + dirs[i] + ":__subpackages__"
+				break
+			}
+		}
+		entries.Add(entry)
+	}
+	return entries.SortedSlice()
+}
+
+func isUnderDir(pkg, dir string) bool {
+	return dir == "" || pkg == dir || strings.HasPrefix(pkg, dir+"/")
+}
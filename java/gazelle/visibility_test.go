@@ -0,0 +1,192 @@
+package gazelle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinimalVisibility(t *testing.T) {
+	packages := map[string]bool{
+		"":                true,
+		"app":             true,
+		"app/admin":       true,
+		"app/admin/audit": true,
+		"lib":             true,
+		"lib/util":        true,
+		"tools":           true,
+	}
+	for name, tc := range map[string]struct {
+		own       string
+		consumers []string
+		want      []string
+	}{
+		"unused": {
+			own:  "lib",
+			want: []string{"//visibility:private"},
+		},
+		"own package only": {
+			own:       "lib",
+			consumers: []string{"lib"},
+			want:      []string{"//visibility:private"},
+		},
+		"single consumer": {
+			own:       "lib",
+			consumers: []string{"app"},
+			want:      []string{"//app:__pkg__"},
+		},
+		"whole subtree": {
+			own:       "lib",
+			consumers: []string{"app", "app/admin", "app/admin/audit"},
+			want:      []string{"//app:__subpackages__"},
+		},
+		"partial subtree": {
+			own:       "lib",
+			consumers: []string{"app", "app/admin"},
+			want:      []string{"//app/admin:__pkg__", "//app:__pkg__"},
+		},
+		"subtree including own package": {
+			own:       "lib",
+			consumers: []string{"lib/util", "app"},
+			want:      []string{"//app:__pkg__", "//lib/util:__pkg__"},
+		},
+		"root": {
+			own:       "lib",
+			consumers: []string{"", "app", "app/admin", "app/admin/audit", "lib/util", "tools"},
+			want:      []string{"//:__subpackages__"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, minimalVisibility(tc.own, tc.consumers, countPackagesUnder(packages)))
+		})
+	}
+}
+
+func TestVisibilityInference(t *testing.T) {
+	const content = `java_library(
+    name = "lib",
+    visibility = [
+        "//tools:__pkg__",  # keep
+        "//:__subpackages__",
+    ],
+)
+
+java_library(
+    name = "kept",
+    visibility = ["//:__subpackages__"],  # keep
+)
+
+java_library(
+    name = "unused",
+    visibility = ["//:__subpackages__"],
+)
+
+java_library(
+    name = "new",
+)
+`
+	for name, tc := range map[string]struct {
+		generated []string
+		want      string
+	}{
+		"full run": {
+			generated: []string{"", "app", "app/admin", "lib", "tools"},
+			want: `java_library(
+    name = "lib",
+    visibility = [
+        "//app:__subpackages__",
+        "//extra:__pkg__",
+        "//tools:__pkg__",  # keep
+    ],
+)
+
+java_library(
+    name = "kept",
+    visibility = ["//:__subpackages__"],  # keep
+)
+
+java_library(
+    name = "unused",
+    visibility = [
+        "//:__subpackages__",
+        "//extra:__pkg__",
+    ],
+)
+
+java_library(
+    name = "new",
+    visibility = ["//extra:__pkg__"],
+)`,
+		},
+		"partial run": {
+			generated: []string{"lib"},
+			want: `java_library(
+    name = "lib",
+    visibility = [
+        "//:__subpackages__",
+        "//app:__subpackages__",
+        "//extra:__pkg__",
+        "//tools:__pkg__",  # keep
+    ],
+)
+
+java_library(
+    name = "kept",
+    visibility = ["//:__subpackages__"],  # keep
+)
+
+java_library(
+    name = "unused",
+    visibility = [
+        "//:__subpackages__",
+        "//extra:__pkg__",
+    ],
+)
+
+java_library(
+    name = "new",
+    visibility = ["//extra:__pkg__"],
+)`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f, err := rule.LoadData("lib/BUILD.bazel", "lib", []byte(content))
+			require.NoError(t, err)
+
+			vi := newVisibilityInference()
+			for _, pkg := range []string{"", "app", "app/admin", "tools"} {
+				vi.addPackage(pkg)
+			}
+			for _, pkg := range tc.generated {
+				if pkg == "lib" {
+					vi.addGeneratedPackage(pkg, f)
+				} else {
+					vi.addGeneratedPackage(pkg, nil)
+				}
+			}
+			for _, r := range f.Rules {
+				vi.addLibrary("lib", r)
+			}
+
+			for _, r := range f.Rules {
+				vi.recordRule(label.New("", "lib", r.Name()), r, true, []string{"//extra:__pkg__"})
+			}
+			app := rule.NewRule("java_library", "app")
+			app.SetAttr("deps", []string{"//lib", "//lib:kept", "@maven//:com_google_guava_guava"})
+			vi.recordRule(label.New("", "app", "app"), app, false, nil)
+			admin := rule.NewRule("java_binary", "admin")
+			admin.SetAttr("runtime_deps", []string{"//lib:lib"})
+			vi.recordRule(label.New("", "app/admin", "admin"), admin, false, nil)
+
+			vi.apply(zerolog.Nop())
+			f.Sync()
+
+			require.Equal(t, tc.want, strings.TrimSpace(string(bzl.Format(f.File))))
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package com.github.bazel_contrib.contrib_rules_jvm.javaparser.generators;
 
 import static com.github.bazel_contrib.contrib_rules_jvm.javaparser.generators.ClassNames.isLikelyClassName;
+import static javax.lang.model.element.Modifier.ABSTRACT;
 import static javax.lang.model.element.Modifier.PRIVATE;
 import static javax.lang.model.element.Modifier.PUBLIC;
 import static javax.lang.model.element.Modifier.STATIC;
@@ -10,18 +11,28 @@ import com.google.common.base.Joiner;
 import com.google.common.collect.Lists;
 import com.sun.source.tree.AnnotationTree;
 import com.sun.source.tree.ArrayTypeTree;
+import com.sun.source.tree.AssignmentTree;
 import com.sun.source.tree.ClassTree;
 import com.sun.source.tree.CompilationUnitTree;
+import com.sun.source.tree.DirectiveTree;
+import com.sun.source.tree.ExportsTree;
 import com.sun.source.tree.ExpressionTree;
 import com.sun.source.tree.ImportTree;
+import com.sun.source.tree.LiteralTree;
 import com.sun.source.tree.MemberSelectTree;
 import com.sun.source.tree.MethodInvocationTree;
 import com.sun.source.tree.MethodTree;
+import com.sun.source.tree.ModuleTree;
+import com.sun.source.tree.NewArrayTree;
 import com.sun.source.tree.NewClassTree;
+import com.sun.source.tree.OpensTree;
 import com.sun.source.tree.PackageTree;
 import com.sun.source.tree.ParameterizedTypeTree;
 import com.sun.source.tree.PrimitiveTypeTree;
+import com.sun.source.tree.ProvidesTree;
+import com.sun.source.tree.RequiresTree;
 import com.sun.source.tree.Tree;
+import com.sun.source.tree.UsesTree;
 import com.sun.source.tree.VariableTree;
 import com.sun.source.util.JavacTask;
 import com.sun.source.util.TreeScanner;
@@ -150,6 +161,17 @@ public class ClasspathParser {
           "SafeVarargs",
           "SuppressWarnings");
 
+  private static final String ANNOTATION_PROCESSING_PACKAGE = "javax.annotation.processing";
+  private static final String PROCESSOR = ANNOTATION_PROCESSING_PACKAGE + ".Processor";
+  private static final String ABSTRACT_PROCESSOR =
+      ANNOTATION_PROCESSING_PACKAGE + ".AbstractProcessor";
+  private static final String SUPPORTED_ANNOTATION_TYPES =
+      ANNOTATION_PROCESSING_PACKAGE + ".SupportedAnnotationTypes";
+
+  private static final String SERVICE_LOADER_PACKAGE = "java.util";
+  private static final String SERVICE_LOADER = SERVICE_LOADER_PACKAGE + ".ServiceLoader";
+  private static final Set<String> SERVICE_LOADER_METHODS = Set.of("load", "loadInstalled");
+
   // get the system java compiler instance
   private static final JavaCompiler compiler = ToolProvider.getSystemJavaCompiler();
   private static final List<String> OPTIONS =
@@ -212,6 +234,7 @@ public class ClasspathParser {
   class ClassScanner extends TreeScanner<Void, Void> {
     private final ParsedPackageData data;
     private CompilationUnitTree compileUnit;
+    private Path filePath;
     private String fileName;
     @Nullable private String currentPackage;
 
@@ -240,7 +263,8 @@ public class ClasspathParser {
     @Override
     public Void visitCompilationUnit(CompilationUnitTree t, Void v) {
       compileUnit = t;
-      fileName = Paths.get(compileUnit.getSourceFile().toUri()).getFileName().toString();
+      filePath = Paths.get(compileUnit.getSourceFile().toUri());
+      fileName = filePath.getFileName().toString();
       currentFileImports = new HashMap<>();
       locallyDefinedClassNames = new TreeSet<>();
       typeParameterNames = new TreeSet<>();
@@ -290,7 +314,7 @@ public class ClasspathParser {
       String name = i.getQualifiedIdentifier().toString();
       if (i.isStatic()) {
         String staticPackage = name.substring(0, name.lastIndexOf('.'));
-        data.usedTypes.add(staticPackage);
+        data.addUsedType(filePath, staticPackage);
         // Static imports of nested classes (e.g., `import static Outer.Inner`) make the
         // inner class available as a bare type. Register it in currentFileImports so
         // that later type references resolve to the import rather than falling through
@@ -298,23 +322,171 @@ public class ClasspathParser {
         String lastComponent = name.substring(name.lastIndexOf('.') + 1);
         if (isLikelyClassName(lastComponent)) {
           currentFileImports.put(lastComponent, name);
-          data.usedTypes.add(name);
+          data.addUsedType(filePath, name);
         }
       } else if (name.endsWith(".*")) {
         String wildcardPackage = name.substring(0, name.lastIndexOf('.'));
-        data.usedPackagesWithoutSpecificTypes.add(wildcardPackage);
+        data.addUsedPackageWithoutSpecificTypes(filePath, wildcardPackage);
       } else {
         String[] parts = i.getQualifiedIdentifier().toString().split("\\.");
         currentFileImports.put(parts[parts.length - 1], i.getQualifiedIdentifier().toString());
-        data.usedTypes.add(name);
+        data.addUsedType(filePath, name);
       }
       return super.visitImport(i, v);
     }
 
+    @Override
+    public Void visitModule(ModuleTree m, Void v) {
+      ModuleData module =
+          new ModuleData(m.getName().toString(), m.getModuleType() == ModuleTree.ModuleKind.OPEN);
+      for (DirectiveTree directive : m.getDirectives()) {
+        if (directive instanceof RequiresTree) {
+          RequiresTree requires = (RequiresTree) directive;
+          String name = requires.getModuleName().toString();
+          module.requires.add(name);
+          if (requires.isTransitive()) {
+            module.requiresTransitive.add(name);
+          }
+          if (requires.isStatic()) {
+            module.requiresStatic.add(name);
+          }
+        } else if (directive instanceof ExportsTree) {
+          module.exports.add(((ExportsTree) directive).getPackageName().toString());
+        } else if (directive instanceof OpensTree) {
+          module.opens.add(((OpensTree) directive).getPackageName().toString());
+        } else if (directive instanceof UsesTree) {
+          String service = moduleTypeName(((UsesTree) directive).getServiceName());
+          module.uses.add(service);
+          data.addUsedType(filePath, service);
+        } else if (directive instanceof ProvidesTree) {
+          ProvidesTree provides = (ProvidesTree) directive;
+          String service = moduleTypeName(provides.getServiceName());
+          data.addUsedType(filePath, service);
+          for (ExpressionTree implementation : provides.getImplementationNames()) {
+            String implementationName = moduleTypeName(implementation);
+            module.provides.computeIfAbsent(service, k -> new TreeSet<>()).add(implementationName);
+            data.addUsedType(filePath, implementationName);
+          }
+        }
+      }
+      data.module = module;
+      // The directives only name modules, packages and types, all handled above.
+      return null;
+    }
+
+    /**
+     * Returns whether c is a concrete class extending {@code AbstractProcessor} or implementing
+     * {@code Processor}.
+     */
+    private boolean isAnnotationProcessor(ClassTree c) {
+      if (!isConcreteClass(c)) {
+        return false;
+      }
+      if (c.getExtendsClause() != null
+          && ABSTRACT_PROCESSOR.equals(
+              importedTypeName(c.getExtendsClause().toString(), ANNOTATION_PROCESSING_PACKAGE))) {
+        return true;
+      }
+      for (Tree implement : c.getImplementsClause()) {
+        if (PROCESSOR.equals(
+            importedTypeName(implement.toString(), ANNOTATION_PROCESSING_PACKAGE))) {
+          return true;
+        }
+      }
+      return false;
+    }
+
+    /** Returns whether c is a class which can be instantiated, as processors are by javac. */
+    private boolean isConcreteClass(ClassTree c) {
+      return c.getKind() == Tree.Kind.CLASS && !c.getModifiers().getFlags().contains(ABSTRACT);
+    }
+
+    /**
+     * Returns the fully qualified name of the type named name, if it's imported by name, or is a
+     * type of wildcardPackage imported with a wildcard, or else name.
+     */
+    private String importedTypeName(String name, String wildcardPackage) {
+      String imported = currentFileImports.get(name);
+      if (imported != null) {
+        return imported;
+      }
+      if (!name.contains(".") && data.usedPackagesWithoutSpecificTypes.contains(wildcardPackage)) {
+        return wildcardPackage + "." + name;
+      }
+      return name;
+    }
+
+    /**
+     * Returns the fully qualified name of the type named name in the current file: imported, fully
+     * qualified already, or else of the current package.
+     */
+    private String typeName(String name) {
+      int dot = name.indexOf('.');
+      String first = dot < 0 ? name : name.substring(0, dot);
+      String imported = currentFileImports.get(first);
+      if (imported != null) {
+        return imported + name.substring(first.length());
+      }
+      if (dot >= 0 && Character.isLowerCase(first.charAt(0))) {
+        return name;
+      }
+      return currentPackage == null ? name : currentPackage + "." + name;
+    }
+
+    /** Records the service a {@code ServiceLoader.load(Service.class)} call loads. */
+    private void maybeRecordLoadedService(
+        MemberSelectTree method, List<? extends ExpressionTree> arguments) {
+      if (!SERVICE_LOADER_METHODS.contains(method.getIdentifier().toString())
+          || arguments.isEmpty()
+          || !SERVICE_LOADER.equals(
+              importedTypeName(method.getExpression().toString(), SERVICE_LOADER_PACKAGE))) {
+        return;
+      }
+      ExpressionTree service = arguments.get(0);
+      if (service instanceof MemberSelectTree
+          && ((MemberSelectTree) service).getIdentifier().contentEquals("class")) {
+        data.loadedServices.add(typeName(((MemberSelectTree) service).getExpression().toString()));
+      }
+    }
+
+    /** Returns the string literals given as the value of annotation. */
+    private Set<String> stringValues(AnnotationTree annotation) {
+      Set<String> values = new TreeSet<>();
+      for (ExpressionTree argument : annotation.getArguments()) {
+        ExpressionTree value =
+            argument instanceof AssignmentTree
+                ? ((AssignmentTree) argument).getExpression()
+                : argument;
+        List<? extends ExpressionTree> elements =
+            value instanceof NewArrayTree
+                ? ((NewArrayTree) value).getInitializers()
+                : List.of(value);
+        if (elements == null) {
+          continue;
+        }
+        for (ExpressionTree element : elements) {
+          if (element instanceof LiteralTree) {
+            Object literal = ((LiteralTree) element).getValue();
+            if (literal instanceof String) {
+              values.add((String) literal);
+            }
+          }
+        }
+      }
+      return values;
+    }
+
+    /** Returns the fully qualified name of a type named in a module directive. */
+    private String moduleTypeName(ExpressionTree name) {
+      String typeName = name.toString();
+      String imported = currentFileImports.get(typeName);
+      return imported != null ? imported : typeName;
+    }
+
     @Override
     public Void visitClass(ClassTree t, Void v) {
       if (stack.isEmpty() && !t.getSimpleName().toString().isEmpty()) {
-        data.declaredTypes.add(qualifiedTopLevelName(t.getSimpleName().toString()));
+        data.addDeclaredType(filePath, qualifiedTopLevelName(t.getSimpleName().toString()));
       }
       stack.addLast(t);
       for (com.sun.source.tree.TypeParameterTree typeParam : t.getTypeParameters()) {
@@ -337,6 +509,17 @@ public class ClasspathParser {
         } else {
           noteAnnotatedClass(currentFullyQualifiedClass, annotationClassName);
         }
+        if (isConcreteClass(t)
+            && SUPPORTED_ANNOTATION_TYPES.equals(
+                importedTypeName(annotationClassName, ANNOTATION_PROCESSING_PACKAGE))) {
+          data.annotationProcessors
+              .computeIfAbsent(currentFullyQualifiedClass, k -> new TreeSet<>())
+              .addAll(stringValues(annotation));
+        }
+      }
+      if (isAnnotationProcessor(t)) {
+        data.annotationProcessors.computeIfAbsent(
+            currentFullyQualifiedClassName(), k -> new TreeSet<>());
       }
       Void ret = super.visitClass(t, v);
       popOrThrow(t);
@@ -365,7 +548,7 @@ public class ClasspathParser {
       } else if (m.getReturnType() != null) {
         Set<String> types = checkFullyQualifiedType(m.getReturnType());
         if (!m.getModifiers().getFlags().contains(PRIVATE)) {
-          data.exportedTypes.addAll(types);
+          data.addExportedTypes(filePath, types);
         }
       }
 
@@ -417,8 +600,9 @@ public class ClasspathParser {
     @Override
     public Void visitMethodInvocation(MethodInvocationTree node, Void v) {
       if (node.getMethodSelect() instanceof MemberSelectTree) {
-        ExpressionTree container = ((MemberSelectTree) node.getMethodSelect()).getExpression();
-        maybeRecordMethodReceiverType(container);
+        MemberSelectTree method = (MemberSelectTree) node.getMethodSelect();
+        maybeRecordMethodReceiverType(method.getExpression());
+        maybeRecordLoadedService(method, node.getArguments());
       }
       return super.visitMethodInvocation(node, v);
     }
@@ -515,7 +699,7 @@ public class ClasspathParser {
                 currentPackage,
                 excludedSamePackageNames());
         if (resolved.isPresent()) {
-          data.usedTypes.add(resolved.get());
+          data.addUsedType(filePath, resolved.get());
           types.add(resolved.get());
         }
       } else if (identifier.getKind() == Tree.Kind.PARAMETERIZED_TYPE) {
@@ -3,6 +3,11 @@ package com.github.bazel_contrib.contrib_rules_jvm.javaparser.generators;
 import static java.nio.file.StandardCopyOption.ATOMIC_MOVE;
 
 import com.gazelle.java.javaparser.v0.JavaParserGrpc;
+import com.gazelle.java.javaparser.v0.AnnotationProcessor;
+import com.gazelle.java.javaparser.v0.FileData;
+import com.gazelle.java.javaparser.v0.ModuleDeclaration;
+import com.gazelle.java.javaparser.v0.ModuleProvides;
+import com.gazelle.java.javaparser.v0.ModuleRequires;
 import com.gazelle.java.javaparser.v0.Package;
 import com.gazelle.java.javaparser.v0.Package.Builder;
 import com.gazelle.java.javaparser.v0.ParsePackageRequest;
@@ -28,6 +33,7 @@ import java.util.List;
 import java.util.Map;
 import java.util.Set;
 import java.util.SortedSet;
+import java.util.TreeMap;
 import java.util.concurrent.TimeUnit;
 import java.util.stream.Collectors;
 import org.slf4j.Logger;
@@ -192,7 +198,8 @@ public class GrpcServer {
               .addAllInternalClasses(data.internalTypes)
               .addAllDeclaredClasses(data.declaredTypes)
               .addAllImportedPackagesWithoutSpecificClasses(data.usedPackagesWithoutSpecificTypes)
-              .addAllMains(data.mainClasses);
+              .addAllMains(data.mainClasses)
+              .addAllLoadedServices(data.loadedServices);
       for (Map.Entry<String, PerClassData> classEntry : data.perClassData.entrySet()) {
         PerClassMetadata.Builder perClassMetadata =
             PerClassMetadata.newBuilder()
@@ -215,8 +222,68 @@ public class GrpcServer {
         }
         packageBuilder.putPerClassMetadata(classEntry.getKey(), perClassMetadata.build());
       }
+      for (Map.Entry<String, SortedSet<String>> processor : data.annotationProcessors.entrySet()) {
+        packageBuilder.putAnnotationProcessors(
+            processor.getKey(),
+            AnnotationProcessor.newBuilder()
+                .addAllSupportedAnnotationTypes(processor.getValue())
+                .build());
+      }
+      if (data.module != null) {
+        packageBuilder.setModule(moduleDeclaration(data.module));
+      }
+      packageBuilder.putAllPerFileData(perFileData(directory, data));
 
       return packageBuilder.build();
     }
+
+    private Map<String, FileData> perFileData(Path directory, ParsedPackageData data) {
+      Path base = directory.toAbsolutePath().normalize();
+      Map<String, FileData> perFileData = new TreeMap<>();
+      for (Map.Entry<Path, ParsedPackageData> file : data.perFileData.entrySet()) {
+        ParsedPackageData fileData = file.getValue();
+        perFileData.put(
+            relativeFile(base, file.getKey()),
+            FileData.newBuilder()
+                .addAllImportedClasses(fileData.usedTypes)
+                .addAllImportedPackagesWithoutSpecificClasses(
+                    fileData.usedPackagesWithoutSpecificTypes)
+                .addAllExportedClasses(fileData.exportedTypes)
+                .addAllInternalClasses(fileData.internalTypes)
+                .addAllDeclaredClasses(fileData.declaredTypes)
+                .build());
+      }
+      return perFileData;
+    }
+
+    private String relativeFile(Path base, Path file) {
+      return base.relativize(file.toAbsolutePath().normalize()).toString();
+    }
+
+    private ModuleDeclaration moduleDeclaration(ModuleData module) {
+      ModuleDeclaration.Builder builder =
+          ModuleDeclaration.newBuilder()
+              .setName(module.name)
+              .setOpen(module.open)
+              .addAllExports(module.exports)
+              .addAllOpens(module.opens)
+              .addAllUses(module.uses);
+      for (String required : module.requires) {
+        builder.addRequires(
+            ModuleRequires.newBuilder()
+                .setName(required)
+                .setIsTransitive(module.requiresTransitive.contains(required))
+                .setIsStatic(module.requiresStatic.contains(required))
+                .build());
+      }
+      for (Map.Entry<String, SortedSet<String>> provides : module.provides.entrySet()) {
+        builder.addProvides(
+            ModuleProvides.newBuilder()
+                .setService(provides.getKey())
+                .addAllImplementations(provides.getValue())
+                .build());
+      }
+      return builder.build();
+    }
   }
 }
@@ -79,7 +79,8 @@ public class KtParser {
     List<VirtualFile> virtualFiles =
         files.stream().map(f -> vfm.findFileByNioPath(f)).collect(Collectors.toUnmodifiableList());
 
-    for (VirtualFile virtualFile : virtualFiles) {
+    for (int i = 0; i < virtualFiles.size(); i++) {
+      VirtualFile virtualFile = virtualFiles.get(i);
       if (virtualFile == null) {
         throw new IllegalArgumentException("File not found: " + files.get(0));
       }
@@ -97,6 +98,7 @@ public class KtParser {
       logger.debug("import directives: {}", ktFile.getImportDirectives());
       logger.debug("import list: {}", ktFile.getImportList());
 
+      visitor.currentFile = files.get(i);
       ktFile.accept(visitor);
     }
 
@@ -180,6 +182,9 @@ public class KtParser {
   public static class KtFileVisitor extends KtTreeVisitorVoid {
     final ParsedPackageData packageData = new ParsedPackageData();
 
+    // The file being visited, which the used types and packages are recorded against.
+    Path currentFile;
+
     private Stack<Visibility> visibilityStack = new Stack<>();
     private HashMap<String, String> fqImportByNameOrAlias = new HashMap<>();
 
@@ -254,21 +259,22 @@ public class KtParser {
         if (localName == null) {
           localName = className.shortName().toString();
         }
-        packageData.usedTypes.add(className.toString());
+        packageData.addUsedType(currentFile, className.toString());
         fqImportByNameOrAlias.put(localName, className.toString());
       } else {
         if (importDirective.isAllUnder()) {
           // If it's a wildcard import with no obvious class name, assume it's a package.
-          packageData.usedPackagesWithoutSpecificTypes.add(importName.asString());
+          packageData.addUsedPackageWithoutSpecificTypes(currentFile, importName.asString());
         } else {
           // If it's not a wildcard import and lacks an obvious class name, assume it's a function
           // in a package.
-          packageData.usedPackagesWithoutSpecificTypes.add(importName.parent().asString());
+          packageData.addUsedPackageWithoutSpecificTypes(
+              currentFile, importName.parent().asString());
           // Also record the full import (e.g. misk.logging.getLogger) as a used type. For a split
           // package, class-level resolution can then map the symbol to a single artifact via the
           // class index, which lists top-level functions under their package. The parent package
           // above remains the fallback for wholly-owned packages.
-          packageData.usedTypes.add(importName.asString());
+          packageData.addUsedType(currentFile, importName.asString());
         }
       }
       super.visitImportDirective(importDirective);
@@ -347,7 +353,7 @@ public class KtParser {
 
       // If this was a property delegate, add its dependencies to exported types
       if (property.hasDelegate() && currentlyInPropertyDelegate) {
-        packageData.exportedTypes.addAll(currentPropertyDelegateDeps);
+        packageData.addExportedTypes(currentFile, currentPropertyDelegateDeps);
         logger.debug(
             "Property delegate "
                 + getPropertyFqName(property)
@@ -454,7 +460,7 @@ public class KtParser {
 
       // If this was an inline function, add its dependencies to exported types
       if (isInline) {
-        packageData.exportedTypes.addAll(currentInlineFunctionDeps);
+        packageData.addExportedTypes(currentFile, currentInlineFunctionDeps);
         logger.debug(
             "Inline function "
                 + currentInlineFunction
@@ -467,7 +473,7 @@ public class KtParser {
       }
       // If this was an extension function, add its dependencies to exported types
       if (isExtension) {
-        packageData.exportedTypes.addAll(currentExtensionFunctionDeps);
+        packageData.addExportedTypes(currentFile, currentExtensionFunctionDeps);
         logger.debug(
             "Extension function "
                 + currentExtensionFunction
@@ -487,7 +493,7 @@ public class KtParser {
         componentFunctionDeps.put(currentComponentFunction, currentComponentFunctionDeps);
         // Also add these dependencies to the exported types since they'll be needed for
         // destructuring
-        packageData.exportedTypes.addAll(currentComponentFunctionDeps);
+        packageData.addExportedTypes(currentFile, currentComponentFunctionDeps);
         logger.debug(
             "ComponentN function "
                 + currentComponentFunction
@@ -533,7 +539,7 @@ public class KtParser {
           String currentPackage = filePackage.isRoot() ? null : filePackage.asString();
           TypeNameResolver.resolve(
                   name, fqImportByNameOrAlias, currentPackage, KOTLIN_WELL_KNOWN_TYPES)
-              .ifPresent(packageData.usedTypes::add);
+              .ifPresent(type -> packageData.addUsedType(currentFile, type));
         }
       }
 
@@ -648,7 +654,7 @@ public class KtParser {
           // identifier and the receiver is a dotted identifier chain (not an arbitrary
           // call chain like `foo(x).bar(y)`), record the full qualified type.
           if (isLikelyClassName(functionName) && isQualifiedName(receiverExpression.getText())) {
-            packageData.usedTypes.add(receiverExpression.getText() + "." + functionName);
+            packageData.addUsedType(currentFile, receiverExpression.getText() + "." + functionName);
           }
         }
 
@@ -665,7 +671,7 @@ public class KtParser {
         if (isLikelyClassName(selectorName)
             && receiverExpression != null
             && isQualifiedName(receiverExpression.getText())) {
-          packageData.usedTypes.add(receiverExpression.getText() + "." + selectorName);
+          packageData.addUsedType(currentFile, receiverExpression.getText() + "." + selectorName);
         }
       }
 
@@ -959,7 +965,7 @@ public class KtParser {
      */
     private void recordInternalType(KtElement declaration, FqName fqName) {
       if (fqName != null && isInternal() && isTopLevel(declaration)) {
-        packageData.internalTypes.add(fqName.toString());
+        packageData.addInternalType(currentFile, fqName.toString());
       }
     }
 
@@ -976,7 +982,7 @@ public class KtParser {
 
     private void recordDeclaredType(KtElement declaration, FqName fqName) {
       if (fqName != null && isTopLevel(declaration)) {
-        packageData.declaredTypes.add(fqName.toString());
+        packageData.addDeclaredType(currentFile, fqName.toString());
       }
     }
 
@@ -996,7 +1002,8 @@ public class KtParser {
       KtTypeElement typeElement = getRootType(theType);
       Optional<String> maybeQualifiedType = tryGetFullyQualifiedName(typeElement);
       // TODO: Check for java and Kotlin standard library types.
-      maybeQualifiedType.ifPresent(packageData.exportedTypes::add);
+      maybeQualifiedType.ifPresent(
+          type -> packageData.addExportedTypes(currentFile, List.of(type)));
     }
 
     private KtTypeElement getRootType(KtTypeReference typeReference) {
@@ -1038,7 +1045,7 @@ public class KtParser {
       FqName filePackage = contextElement.getContainingKtFile().getPackageFqName();
       String currentPackage = filePackage.isRoot() ? null : filePackage.asString();
       TypeNameResolver.resolve(name, fqImportByNameOrAlias, currentPackage, KOTLIN_WELL_KNOWN_TYPES)
-          .ifPresent(packageData.usedTypes::add);
+          .ifPresent(type -> packageData.addUsedType(currentFile, type));
     }
 
     private String reconstructQualifiedName(KtUserType userType) {
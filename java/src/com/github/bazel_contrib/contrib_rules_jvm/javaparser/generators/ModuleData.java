@@ -0,0 +1,64 @@
+package com.github.bazel_contrib.contrib_rules_jvm.javaparser.generators;
+
+import java.util.SortedMap;
+import java.util.SortedSet;
+import java.util.TreeMap;
+import java.util.TreeSet;
+
+/** The module declared by a {@code module-info.java} file. */
+class ModuleData {
+  final String name;
+
+  /** Whether the module is declared {@code open}, opening all its packages to reflection. */
+  final boolean open;
+
+  /** The names of the required modules, including the transitive and static ones. */
+  final SortedSet<String> requires = new TreeSet<>();
+
+  /** The names of the modules required with {@code requires transitive}. */
+  final SortedSet<String> requiresTransitive = new TreeSet<>();
+
+  /** The names of the modules required with {@code requires static}. */
+  final SortedSet<String> requiresStatic = new TreeSet<>();
+
+  /** The exported packages, whether to all modules or to some. */
+  final SortedSet<String> exports = new TreeSet<>();
+
+  /** The packages opened to reflection, whether to all modules or to some. */
+  final SortedSet<String> opens = new TreeSet<>();
+
+  /** The fully qualified names of the services the module loads with a ServiceLoader. */
+  final SortedSet<String> uses = new TreeSet<>();
+
+  /** Maps the fully qualified names of the provided services to their implementations. */
+  final SortedMap<String, SortedSet<String>> provides = new TreeMap<>();
+
+  ModuleData(String name, boolean open) {
+    this.name = name;
+    this.open = open;
+  }
+
+  @Override
+  public String toString() {
+    return "ModuleData{"
+        + "name="
+        + name
+        + ", open="
+        + open
+        + ", requires="
+        + requires
+        + ", requiresTransitive="
+        + requiresTransitive
+        + ", requiresStatic="
+        + requiresStatic
+        + ", exports="
+        + exports
+        + ", opens="
+        + opens
+        + ", uses="
+        + uses
+        + ", provides="
+        + provides
+        + '}';
+  }
+}
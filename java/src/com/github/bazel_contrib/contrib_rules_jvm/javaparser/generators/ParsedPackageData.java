@@ -1,9 +1,14 @@
 package com.github.bazel_contrib.contrib_rules_jvm.javaparser.generators;
 
+import java.nio.file.Path;
+import java.util.Collection;
 import java.util.Map;
 import java.util.Set;
+import java.util.SortedMap;
+import java.util.SortedSet;
 import java.util.TreeMap;
 import java.util.TreeSet;
+import javax.annotation.Nullable;
 
 class ParsedPackageData {
   /** Packages defined. */
@@ -40,8 +45,56 @@ class ParsedPackageData {
    */
   final Map<String, PerClassData> perClassData = new TreeMap<>();
 
+  /**
+   * Maps the fully-qualified names of the annotation processors, the classes implementing {@code
+   * javax.annotation.processing.Processor} or carrying {@code @SupportedAnnotationTypes}, to the
+   * annotation types they declare supporting there.
+   */
+  final SortedMap<String, SortedSet<String>> annotationProcessors = new TreeMap<>();
+
+  /** The fully qualified names of the services loaded with {@code ServiceLoader.load}. */
+  final Set<String> loadedServices = new TreeSet<>();
+
+  /** The module declared by a {@code module-info.java} file among the parsed files, if any. */
+  @Nullable ModuleData module;
+
+  /**
+   * The used, exported, internal and declared types, and the used packages, of each parsed file,
+   * keyed by its path.
+   */
+  final SortedMap<Path, ParsedPackageData> perFileData = new TreeMap<>();
+
   ParsedPackageData() {}
 
+  private ParsedPackageData forFile(Path file) {
+    return perFileData.computeIfAbsent(file, k -> new ParsedPackageData());
+  }
+
+  void addUsedType(Path file, String type) {
+    usedTypes.add(type);
+    forFile(file).usedTypes.add(type);
+  }
+
+  void addUsedPackageWithoutSpecificTypes(Path file, String pkg) {
+    usedPackagesWithoutSpecificTypes.add(pkg);
+    forFile(file).usedPackagesWithoutSpecificTypes.add(pkg);
+  }
+
+  void addExportedTypes(Path file, Collection<String> types) {
+    exportedTypes.addAll(types);
+    forFile(file).exportedTypes.addAll(types);
+  }
+
+  void addInternalType(Path file, String type) {
+    internalTypes.add(type);
+    forFile(file).internalTypes.add(type);
+  }
+
+  void addDeclaredType(Path file, String type) {
+    declaredTypes.add(type);
+    forFile(file).declaredTypes.add(type);
+  }
+
   void merge(ParsedPackageData other) {
     packages.addAll(other.packages);
     usedTypes.addAll(other.usedTypes);
@@ -50,6 +103,18 @@ class ParsedPackageData {
     internalTypes.addAll(other.internalTypes);
     declaredTypes.addAll(other.declaredTypes);
     mainClasses.addAll(other.mainClasses);
+    loadedServices.addAll(other.loadedServices);
+    for (Map.Entry<String, SortedSet<String>> processor : other.annotationProcessors.entrySet()) {
+      annotationProcessors
+          .computeIfAbsent(processor.getKey(), k -> new TreeSet<>())
+          .addAll(processor.getValue());
+    }
+    for (Map.Entry<Path, ParsedPackageData> file : other.perFileData.entrySet()) {
+      forFile(file.getKey()).merge(file.getValue());
+    }
+    if (other.module != null) {
+      module = other.module;
+    }
     for (Map.Entry<String, PerClassData> classData : other.perClassData.entrySet()) {
       PerClassData existing = perClassData.get(classData.getKey());
       if (existing == null) {
@@ -533,6 +533,183 @@ public class ClasspathParserTest {
     assertEquals(Set.of("demo.Greeter"), data.declaredTypes);
   }
 
+  @Test
+  public void parseModuleDeclaration(@TempDir Path tempDir) throws IOException {
+    Path src = tempDir.resolve("module-info.java");
+    Files.writeString(
+        src,
+        String.join(
+            "\n",
+            "import com.example.spi.Codec;",
+            "",
+            "module com.example.app {",
+            "  requires java.sql;",
+            "  requires transitive com.example.api;",
+            "  requires static com.example.annotations;",
+            "  exports com.example.app.api;",
+            "  exports com.example.app.internal to com.example.friend;",
+            "  opens com.example.app.model;",
+            "  uses Codec;",
+            "  provides com.example.spi.Codec with com.example.app.JsonCodec,",
+            "      com.example.app.XmlCodec;",
+            "}"));
+
+    ParsedPackageData data = parser.parseClasses(tempDir, List.of("module-info.java"));
+
+    ModuleData module = data.module;
+    assertEquals("com.example.app", module.name);
+    assertEquals(false, module.open);
+    assertEquals(
+        Set.of("java.sql", "com.example.api", "com.example.annotations"), module.requires);
+    assertEquals(Set.of("com.example.api"), module.requiresTransitive);
+    assertEquals(Set.of("com.example.annotations"), module.requiresStatic);
+    assertEquals(Set.of("com.example.app.api", "com.example.app.internal"), module.exports);
+    assertEquals(Set.of("com.example.app.model"), module.opens);
+    assertEquals(Set.of("com.example.spi.Codec"), module.uses);
+    assertEquals(
+        Map.of(
+            "com.example.spi.Codec",
+            new TreeSet<>(Set.of("com.example.app.JsonCodec", "com.example.app.XmlCodec"))),
+        module.provides);
+    assertEquals(
+        Set.of("com.example.spi.Codec", "com.example.app.JsonCodec", "com.example.app.XmlCodec"),
+        data.usedTypes);
+  }
+
+  @Test
+  public void parseAnnotationProcessors(@TempDir Path tempDir) throws IOException {
+    Files.writeString(
+        tempDir.resolve("BuilderProcessor.java"),
+        String.join(
+            "\n",
+            "package com.example.processor;",
+            "",
+            "import javax.annotation.processing.AbstractProcessor;",
+            "import javax.annotation.processing.SupportedAnnotationTypes;",
+            "",
+            "@SupportedAnnotationTypes({\"com.example.Builder\", \"com.example.annotations.*\"})",
+            "public abstract class BuilderProcessor extends AbstractProcessor {}"));
+    Files.writeString(
+        tempDir.resolve("RawProcessor.java"),
+        String.join(
+            "\n",
+            "package com.example.processor;",
+            "",
+            "import javax.annotation.processing.*;",
+            "",
+            "public class RawProcessor implements Processor {}"));
+    Files.writeString(
+        tempDir.resolve("ValueProcessor.java"),
+        String.join(
+            "\n",
+            "package com.example.processor;",
+            "",
+            "import javax.annotation.processing.SupportedAnnotationTypes;",
+            "",
+            "@SupportedAnnotationTypes(\"com.example.Value\")",
+            "public final class ValueProcessor extends BuilderProcessor {}"));
+    Files.writeString(
+        tempDir.resolve("Helper.java"),
+        "package com.example.processor; public class Helper {}");
+
+    ParsedPackageData data =
+        parser.parseClasses(
+            tempDir,
+            List.of(
+                "BuilderProcessor.java",
+                "Helper.java",
+                "RawProcessor.java",
+                "ValueProcessor.java"));
+
+    // BuilderProcessor is abstract, so it can't be run as a processor.
+    assertEquals(
+        Map.of(
+            "com.example.processor.RawProcessor",
+            new TreeSet<>(),
+            "com.example.processor.ValueProcessor",
+            new TreeSet<>(Set.of("com.example.Value"))),
+        data.annotationProcessors);
+  }
+
+  @Test
+  public void parseLoadedServices(@TempDir Path tempDir) throws IOException {
+    Files.writeString(
+        tempDir.resolve("Plugins.java"),
+        String.join(
+            "\n",
+            "package com.example.app;",
+            "",
+            "import com.example.spi.Codec;",
+            "import java.util.ServiceLoader;",
+            "",
+            "public class Plugins {",
+            "  void load() {",
+            "    ServiceLoader.load(Codec.class);",
+            "    ServiceLoader.load(Codec.Factory.class, getClass().getClassLoader());",
+            "    java.util.ServiceLoader.loadInstalled(com.example.spi.Transport.class);",
+            "    ServiceLoader.load(Plugin.class);",
+            "    Codec.load(Ignored.class);",
+            "  }",
+            "}"));
+
+    ParsedPackageData data = parser.parseClasses(tempDir, List.of("Plugins.java"));
+
+    assertEquals(
+        Set.of(
+            "com.example.app.Plugin",
+            "com.example.spi.Codec",
+            "com.example.spi.Codec.Factory",
+            "com.example.spi.Transport"),
+        data.loadedServices);
+  }
+
+  @Test
+  public void parsePerFileData(@TempDir Path tempDir) throws IOException {
+    Files.writeString(
+        tempDir.resolve("A.java"),
+        String.join(
+            "\n",
+            "package com.example.app;",
+            "",
+            "import com.example.b.B;",
+            "",
+            "public class A {",
+            "  public B get() {",
+            "    return null;",
+            "  }",
+            "}"));
+    Files.writeString(
+        tempDir.resolve("AUtil.java"),
+        String.join(
+            "\n",
+            "package com.example.app;",
+            "",
+            "import com.example.c.*;",
+            "import static com.example.d.D.create;",
+            "",
+            "public class AUtil {}",
+            "",
+            "class Helper {}"));
+
+    ParsedPackageData data = parser.parseClasses(tempDir, List.of("A.java", "AUtil.java"));
+
+    Map<String, ParsedPackageData> perFileData = new TreeMap<>();
+    data.perFileData.forEach((file, d) -> perFileData.put(file.getFileName().toString(), d));
+    assertEquals(Set.of("A.java", "AUtil.java"), perFileData.keySet());
+
+    ParsedPackageData a = perFileData.get("A.java");
+    assertEquals(Set.of("com.example.b.B"), a.usedTypes);
+    assertEquals(Set.of(), a.usedPackagesWithoutSpecificTypes);
+    assertEquals(Set.of("com.example.b.B"), a.exportedTypes);
+    assertEquals(Set.of("com.example.app.A"), a.declaredTypes);
+
+    ParsedPackageData aUtil = perFileData.get("AUtil.java");
+    assertEquals(Set.of("com.example.d.D"), aUtil.usedTypes);
+    assertEquals(Set.of("com.example.c"), aUtil.usedPackagesWithoutSpecificTypes);
+    assertEquals(Set.of(), aUtil.exportedTypes);
+    assertEquals(Set.of("com.example.app.AUtil", "com.example.app.Helper"), aUtil.declaredTypes);
+  }
+
   @Test
   public void parseClassesByPathClosesFileManager(@TempDir Path tempDir) throws IOException {
     Path src = tempDir.resolve("Greeter.java");